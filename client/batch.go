@@ -0,0 +1,226 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrBatcherClosed is returned by Batcher.Add after Close.
+	ErrBatcherClosed = errors.New("batcher closed")
+	// ErrBatcherFull is returned by Batcher.Add when MaxPending messages are
+	// buffered.
+	ErrBatcherFull = errors.New("batcher full")
+)
+
+// BatchOptions configures a Batcher.
+type BatchOptions struct {
+	// Size is the number of buffered messages that triggers a flush.
+	Size int
+	// Interval is the longest a message waits in the buffer.
+	Interval time.Duration
+	// Concurrency is the number of channels sent at once during a flush.
+	Concurrency int
+	// MaxPending is the most messages buffered at once, including those of
+	// a flush in progress that failed to keep up. At least Size.
+	MaxPending int
+}
+
+func DefaultBatchOptions() BatchOptions {
+	return BatchOptions{
+		Size:        100,
+		Interval:    time.Second,
+		Concurrency: 8,
+		MaxPending:  10000,
+	}
+}
+
+// Batcher buffers messages and sends them in the background, when Size of
+// them are buffered or Interval has passed. A flush sends several channels at
+// once, but the messages of one channel one at a time and in the order they
+// were added, so that the server never sees them reordered.
+//
+// Errors of background flushes are kept and returned by the next Flush or
+// Close. Add fails with ErrBatcherFull instead of buffering without bound
+// when the server is slower than the messages come in.
+type Batcher struct {
+	client *Client
+	opts   BatchOptions
+
+	mutex   sync.Mutex
+	pending []Message
+	errs    []error
+	closed  bool
+
+	// flushing serializes flushes, so that a channel's messages from two
+	// flushes never overtake each other.
+	flushing sync.Mutex
+	full     chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	// cancel aborts a background flush in progress when Close gives up.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewBatcher starts a batcher sending through c. Close it to send the last
+// messages and stop it.
+func (c *Client) NewBatcher(opts BatchOptions) *Batcher {
+	defaults := DefaultBatchOptions()
+	if opts.Size <= 0 {
+		opts.Size = defaults.Size
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = defaults.Interval
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaults.Concurrency
+	}
+	if opts.MaxPending <= 0 {
+		opts.MaxPending = defaults.MaxPending
+	}
+	opts.MaxPending = max(opts.MaxPending, opts.Size)
+	b := &Batcher{
+		client: c,
+		opts:   opts,
+		full:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	b.ctx, b.cancel = context.WithCancel(context.Background())
+	go b.run()
+	return b
+}
+
+// Add buffers msg for sending. It never blocks on the network, and fails
+// with ErrBatcherFull when MaxPending messages are waiting.
+func (b *Batcher) Add(msg Message) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.closed {
+		return ErrBatcherClosed
+	}
+	if len(b.pending) >= b.opts.MaxPending {
+		return ErrBatcherFull
+	}
+	b.pending = append(b.pending, msg)
+	if len(b.pending) >= b.opts.Size {
+		select {
+		case b.full <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Pending returns the number of buffered messages.
+func (b *Batcher) Pending() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return len(b.pending)
+}
+
+// Flush sends the buffered messages now. It returns the errors of every
+// message that failed since the last Flush, joined.
+func (b *Batcher) Flush(ctx context.Context) error {
+	b.flush(ctx)
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	err := errors.Join(b.errs...)
+	b.errs = nil
+	return err
+}
+
+// Close stops the background flushes, sends the buffered messages and
+// returns like Flush. When ctx is done first, a background flush in progress
+// is aborted, and the messages it had not sent fail with ctx's error.
+func (b *Batcher) Close(ctx context.Context) error {
+	b.mutex.Lock()
+	if b.closed {
+		b.mutex.Unlock()
+		return ErrBatcherClosed
+	}
+	b.closed = true
+	b.mutex.Unlock()
+
+	close(b.stop)
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		b.cancel()
+		<-b.done
+	}
+	b.cancel()
+	return b.Flush(ctx)
+}
+
+func (b *Batcher) run() {
+	defer close(b.done)
+	ticker := time.NewTicker(b.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+		case <-b.full:
+		}
+		// Leave what is left to Close, which flushes with its own ctx.
+		select {
+		case <-b.stop:
+			return
+		default:
+		}
+		b.flush(b.ctx)
+	}
+}
+
+// flush sends the buffered messages and records their errors.
+func (b *Batcher) flush(ctx context.Context) {
+	b.flushing.Lock()
+	defer b.flushing.Unlock()
+
+	b.mutex.Lock()
+	messages := b.pending
+	b.pending = nil
+	b.mutex.Unlock()
+	if len(messages) == 0 {
+		return
+	}
+
+	var order []string
+	byChannel := make(map[string][]Message)
+	for _, msg := range messages {
+		channel := msg.Metadata.Channel
+		if _, seen := byChannel[channel]; !seen {
+			order = append(order, channel)
+		}
+		byChannel[channel] = append(byChannel[channel], msg)
+	}
+
+	slots := make(chan struct{}, b.opts.Concurrency)
+	var wg sync.WaitGroup
+	for _, channel := range order {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(messages []Message) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			for _, msg := range messages {
+				if _, err := b.client.Send(ctx, msg); err != nil {
+					b.fail(fmt.Errorf("channel %s (msg #%d): %w", msg.Metadata.Channel, msg.Metadata.MessageNumber, err))
+				}
+			}
+		}(byChannel[channel])
+	}
+	wg.Wait()
+}
+
+func (b *Batcher) fail(err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.errs = append(b.errs, err)
+}
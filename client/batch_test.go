@@ -0,0 +1,120 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/seansa/rocket-challenge/internal/rockettest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBatcher tests that a flush sends every message, in order per channel.
+func TestBatcher(t *testing.T) {
+	stack := rockettest.New(t, rockettest.Options{})
+	var mutex sync.Mutex
+	received := make(map[string][]int)
+	c := newTestClient(t, stack, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			var msg Message
+			if json.Unmarshal(body, &msg) == nil {
+				mutex.Lock()
+				received[msg.Metadata.Channel] = append(received[msg.Metadata.Channel], msg.Metadata.MessageNumber)
+				mutex.Unlock()
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	batcher := c.NewBatcher(BatchOptions{Size: 1000, Interval: time.Hour, Concurrency: 3})
+	channels := []string{"rocket-1", "rocket-2", "rocket-3", "rocket-4", "rocket-5"}
+	want := make(map[string][]int)
+	for _, channel := range channels {
+		require.NoError(t, batcher.Add(Launched(channel, 1, "Falcon-9", 0, "ARTEMIS")))
+		want[channel] = append(want[channel], 1)
+	}
+	for number := 2; number <= 10; number++ {
+		for _, channel := range channels {
+			require.NoError(t, batcher.Add(SpeedIncreased(channel, number, 10)))
+			want[channel] = append(want[channel], number)
+		}
+	}
+	assert.Equal(t, 50, batcher.Pending())
+	assert.Empty(t, received, "nothing is sent before the flush")
+
+	require.NoError(t, batcher.Flush(context.Background()))
+	assert.Equal(t, 0, batcher.Pending())
+	assert.Equal(t, want, received)
+	stack.Drain()
+	for _, channel := range channels {
+		stack.AssertRocket(model.Rocket{Channel: channel, Type: "Falcon-9", Speed: 90, Mission: "ARTEMIS"})
+	}
+
+	require.NoError(t, batcher.Close(context.Background()))
+	assert.ErrorIs(t, batcher.Add(Launched("rocket-6", 1, "Falcon-9", 0, "ARTEMIS")), ErrBatcherClosed)
+}
+
+// TestBatcher_Triggers tests the size and interval flushes, and that their
+// errors are returned by Close.
+func TestBatcher_Triggers(t *testing.T) {
+	stack := rockettest.New(t, rockettest.Options{})
+	c := newTestClient(t, stack, nil)
+
+	bySize := c.NewBatcher(BatchOptions{Size: 2, Interval: time.Hour})
+	require.NoError(t, bySize.Add(Launched("rocket-1", 1, "Falcon-9", 500, "ARTEMIS")))
+	require.NoError(t, bySize.Add(Message{}))
+	assert.Eventually(t, func() bool { return stack.Queue.Len() == 1 }, 2*time.Second, time.Millisecond)
+	err := bySize.Close(context.Background())
+	assert.ErrorIs(t, err, ErrInvalidPayload)
+	assert.Contains(t, err.Error(), "msg #0")
+
+	byInterval := c.NewBatcher(BatchOptions{Size: 1000, Interval: 10 * time.Millisecond})
+	require.NoError(t, byInterval.Add(Launched("rocket-2", 1, "Falcon-9", 500, "ARTEMIS")))
+	assert.Eventually(t, func() bool { return stack.Queue.Len() == 2 }, 2*time.Second, time.Millisecond)
+	require.NoError(t, byInterval.Close(context.Background()))
+	assert.ErrorIs(t, byInterval.Close(context.Background()), ErrBatcherClosed)
+
+	stack.Drain()
+	assert.Len(t, stack.Rockets(), 2)
+}
+
+// TestBatcher_Bounds tests that Add refuses messages past MaxPending and that
+// Close aborts a background flush that outlives its ctx.
+func TestBatcher_Bounds(t *testing.T) {
+	stack := rockettest.New(t, rockettest.Options{})
+	sending := make(chan struct{}, 1)
+	c := newTestClient(t, stack, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// The server only notices the client going away once the body
+			// is read.
+			_, _ = io.Copy(io.Discard, r.Body)
+			sending <- struct{}{}
+			<-r.Context().Done()
+		})
+	})
+
+	full := c.NewBatcher(BatchOptions{Size: 2, Interval: time.Hour, MaxPending: 1})
+	require.NoError(t, full.Add(Launched("rocket-1", 1, "Falcon-9", 500, "ARTEMIS")))
+	require.NoError(t, full.Add(Launched("rocket-1", 2, "Falcon-9", 500, "ARTEMIS")), "MaxPending is at least Size")
+	<-sending
+	require.NoError(t, full.Add(Launched("rocket-1", 3, "Falcon-9", 500, "ARTEMIS")))
+	require.NoError(t, full.Add(Launched("rocket-1", 4, "Falcon-9", 500, "ARTEMIS")))
+	assert.ErrorIs(t, full.Add(Launched("rocket-1", 5, "Falcon-9", 500, "ARTEMIS")), ErrBatcherFull)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := full.Close(ctx)
+	assert.Less(t, time.Since(start), time.Second, "Close does not wait for the stuck flush")
+	assert.ErrorIs(t, err, context.Canceled, "the background flush is aborted")
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "the buffered messages fail with ctx's error")
+}
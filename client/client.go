@@ -0,0 +1,219 @@
+// Package client is a typed Go client for the rocket API. It posts messages
+// to POST /messages, retrying with jittered backoff while the server sheds
+// load, and reads rocket states back from GET /rockets:
+//
+//	c, err := client.New("http://localhost:8088", client.DefaultOptions())
+//	...
+//	accepted, err := c.Send(ctx, client.Launched("rocket-1", 1, "Falcon-9", 500, "ARTEMIS"))
+//	rocket, err := c.Rocket(ctx, "rocket-1")
+//
+// Failed requests return an *Error, which matches the sentinel errors of this
+// package with errors.Is.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/seansa/rocket-challenge/internal/signature"
+)
+
+// The API's types, so that callers outside this module can name them.
+type (
+	Message     = model.IncomingMessage
+	Metadata    = model.Metadata
+	MessageType = model.MessageType
+	Rocket      = model.Rocket
+	Problem     = model.Problem
+)
+
+// APIKeyHeader identifies the client for the server's per-client rate limit.
+const APIKeyHeader = "X-API-Key"
+
+// Options configures a Client.
+type Options struct {
+	// HTTPClient sends the requests. Its Timeout bounds each attempt.
+	HTTPClient *http.Client
+	// APIKey is sent in the APIKeyHeader header when set.
+	APIKey string
+	// Secret returns the signing secret of a channel, for servers with
+	// signed ingestion. Messages of channels without one are sent unsigned.
+	Secret func(channel string) ([]byte, bool)
+	// MaxAttempts is the number of tries per request while the server
+	// answers 429 or 503.
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry; it doubles on every
+	// following retry up to MaxBackoff. Each wait is jittered, and never
+	// shorter than the server's Retry-After.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+func DefaultOptions() Options {
+	return Options{
+		HTTPClient:     &http.Client{Timeout: 10 * time.Second},
+		MaxAttempts:    5,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     10 * time.Second,
+	}
+}
+
+// Client calls the rocket API. It is safe for concurrent use.
+type Client struct {
+	baseURL string
+	opts    Options
+}
+
+// New returns a client for the server at baseURL, such as
+// "http://localhost:8088".
+func New(baseURL string, opts Options) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL %q: %w", baseURL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q: want http(s)://host[:port]", baseURL)
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	opts.MaxAttempts = max(opts.MaxAttempts, 1)
+	return &Client{baseURL: strings.TrimSuffix(baseURL, "/"), opts: opts}, nil
+}
+
+// Accepted is the response to a message accepted for processing.
+type Accepted struct {
+	Status    string `json:"status"`
+	Channel   string `json:"channel"`
+	RequestID string `json:"requestId"`
+	// ReceiptID identifies the message's receipt, served at
+	// /messages/{receiptId}, when the server issued one.
+	ReceiptID string `json:"receiptId,omitempty"`
+}
+
+// Send posts msg to POST /messages. A zero message time is set to now. The
+// message is processed asynchronously after Send returns.
+func (c *Client) Send(ctx context.Context, msg Message) (Accepted, error) {
+	if msg.Metadata.MessageTime.IsZero() {
+		msg.Metadata.MessageTime = time.Now().UTC()
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return Accepted{}, fmt.Errorf("encoding message: %w", err)
+	}
+
+	var accepted Accepted
+	err = c.do(ctx, http.MethodPost, "/messages", body, func(req *http.Request) {
+		req.Header.Set("Content-Type", "application/json")
+		if c.opts.Secret != nil {
+			if secret, ok := c.opts.Secret(msg.Metadata.Channel); ok {
+				req.Header.Set(signature.Header, signature.Sign(secret, time.Now(), body))
+			}
+		}
+	}, &accepted)
+	return accepted, err
+}
+
+// Rockets returns the state of every rocket, sorted by channel.
+func (c *Client) Rockets(ctx context.Context) ([]Rocket, error) {
+	rockets := []Rocket{}
+	if err := c.do(ctx, http.MethodGet, "/rockets", nil, nil, &rockets); err != nil {
+		return nil, err
+	}
+	return rockets, nil
+}
+
+// Rocket returns the state of the rocket on channel. It returns an error
+// matching ErrNotFound if there is none.
+func (c *Client) Rocket(ctx context.Context, channel string) (Rocket, error) {
+	var rocket Rocket
+	err := c.do(ctx, http.MethodGet, "/rockets/"+url.PathEscape(channel), nil, nil, &rocket)
+	return rocket, err
+}
+
+// do sends a request, retrying it while the server sheds load, and decodes
+// a successful JSON response into out.
+func (c *Client) do(ctx context.Context, method, path string, body []byte, prepare func(*http.Request), out any) error {
+	backoff := c.opts.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := c.attempt(ctx, method, path, body, prepare, out)
+		var apiErr *Error
+		if !errors.As(err, &apiErr) || !apiErr.Temporary() || attempt >= c.opts.MaxAttempts {
+			return err
+		}
+
+		timer := time.NewTimer(wait(backoff, apiErr.RetryAfter))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w (after %d attempts: %w)", ctx.Err(), attempt, err)
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, c.opts.MaxBackoff)
+	}
+}
+
+func (c *Client) attempt(ctx context.Context, method, path string, body []byte, prepare func(*http.Request), out any) error {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.opts.APIKey != "" {
+		req.Header.Set(APIKeyHeader, c.opts.APIKey)
+	}
+	if prepare != nil {
+		prepare(req)
+	}
+
+	resp, err := c.opts.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return newError(method, path, resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s %s: decoding response: %w", method, path, err)
+	}
+	return nil
+}
+
+// wait returns how long to wait before the next attempt: backoff with equal
+// jitter, so that clients throttled together do not retry together, but no
+// less than the server asked for.
+func wait(backoff, retryAfter time.Duration) time.Duration {
+	jittered := backoff/2 + rand.N(backoff/2+1)
+	return max(jittered, retryAfter)
+}
+
+// parseRetryAfter reads a Retry-After header, in seconds or as an HTTP date.
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		return max(at.Sub(now), 0)
+	}
+	return 0
+}
@@ -0,0 +1,163 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/seansa/rocket-challenge/internal/ratelimit"
+	"github.com/seansa/rocket-challenge/internal/rockettest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestClient starts a server running the real router of stack, wrapped in
+// middleware if it is not nil, and returns a client for it with short
+// backoffs.
+func newTestClient(t *testing.T, stack *rockettest.Stack, middleware func(http.Handler) http.Handler) *Client {
+	t.Helper()
+	handler := stack.Handler
+	if middleware != nil {
+		handler = middleware(handler)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	opts := DefaultOptions()
+	opts.InitialBackoff = time.Millisecond
+	opts.MaxBackoff = 4 * time.Millisecond
+	c, err := New(server.URL+"/", opts)
+	require.NoError(t, err)
+	return c
+}
+
+// TestClient tests sending messages and reading rocket states back.
+func TestClient(t *testing.T) {
+	stack := rockettest.New(t, rockettest.Options{})
+	c := newTestClient(t, stack, nil)
+	ctx := context.Background()
+
+	accepted, err := c.Send(ctx, Launched("rocket-1", 1, "Falcon-9", 500, "ARTEMIS"))
+	require.NoError(t, err)
+	assert.Equal(t, "accepted_for_processing", accepted.Status)
+	assert.Equal(t, "rocket-1", accepted.Channel)
+	assert.NotEmpty(t, accepted.RequestID)
+	assert.NotEmpty(t, accepted.ReceiptID)
+	for _, msg := range []Message{
+		MissionChanged("rocket-1", 3, "SHUTTLE_MIR"),
+		SpeedIncreased("rocket-1", 2, 300),
+		Launched("rocket-2", 1, "Saturn-V", 1000, "APOLLO"),
+		SpeedDecreased("rocket-2", 2, 100),
+		Exploded("rocket-2", 3, "ENGINE_FAILURE"),
+	} {
+		_, err := c.Send(ctx, msg)
+		require.NoError(t, err)
+	}
+	stack.Drain()
+
+	rocket, err := c.Rocket(ctx, "rocket-1")
+	require.NoError(t, err)
+	assert.Equal(t, model.Rocket{Channel: "rocket-1", Type: "Falcon-9", Speed: 500, Mission: "SHUTTLE_MIR"}, rocket)
+
+	rockets, err := c.Rockets(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []Rocket{
+		rocket,
+		{Channel: "rocket-2", Type: "Saturn-V", Mission: model.Aborted, Exploded: true, ExplosionReason: "ENGINE_FAILURE"},
+	}, rockets)
+}
+
+// TestClient_Errors tests that failed requests return a typed error matching
+// the sentinel of their problem code.
+func TestClient_Errors(t *testing.T) {
+	stack := rockettest.New(t, rockettest.Options{ChannelLimit: ratelimit.Limit{Rate: 1, Burst: 1}})
+	c := newTestClient(t, stack, nil)
+	c.opts.MaxAttempts = 1
+	ctx := context.Background()
+
+	_, err := c.Rocket(ctx, "missing")
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.Equal(t, "NOT_FOUND", apiErr.Problem.Code)
+	assert.False(t, apiErr.Temporary())
+
+	_, err = c.Send(ctx, Message{})
+	assert.ErrorIs(t, err, ErrInvalidPayload)
+
+	_, err = c.Send(ctx, Launched("rocket-1", 1, "Falcon-9", 500, "ARTEMIS"))
+	require.NoError(t, err)
+	_, err = c.Send(ctx, SpeedIncreased("rocket-1", 2, 100))
+	require.ErrorAs(t, err, &apiErr)
+	assert.ErrorIs(t, err, ErrRateLimited)
+	assert.True(t, apiErr.Temporary())
+	assert.Equal(t, time.Second, apiErr.RetryAfter)
+}
+
+// TestClient_Retries tests that 503 responses are retried until the server
+// accepts the message, and that retries give up with the context.
+func TestClient_Retries(t *testing.T) {
+	stack := rockettest.New(t, rockettest.Options{})
+	var rejections, attempts atomic.Int32
+	rejections.Store(2)
+	c := newTestClient(t, stack, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts.Add(1)
+			if rejections.Add(-1) >= 0 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	_, err := c.Send(context.Background(), Launched("rocket-1", 1, "Falcon-9", 500, "ARTEMIS"))
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), attempts.Load())
+	stack.Drain()
+	stack.AssertRocket(model.Rocket{Channel: "rocket-1", Type: "Falcon-9", Speed: 500, Mission: "ARTEMIS"})
+
+	// Without a problem body, the status tells what went wrong.
+	rejections.Store(1 << 20)
+	attempts.Store(0)
+	_, err = c.Send(context.Background(), SpeedIncreased("rocket-1", 2, 100))
+	assert.ErrorIs(t, err, ErrQueueFull)
+	assert.Equal(t, int32(c.opts.MaxAttempts), attempts.Load())
+
+	c.opts.InitialBackoff, c.opts.MaxBackoff = time.Minute, time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = c.Send(ctx, SpeedIncreased("rocket-1", 2, 100))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorIs(t, err, ErrQueueFull)
+}
+
+func TestNew(t *testing.T) {
+	for _, baseURL := range []string{"", "localhost:8088", "ftp://localhost", "http://"} {
+		_, err := New(baseURL, DefaultOptions())
+		assert.Error(t, err, baseURL)
+	}
+}
+
+func TestWait(t *testing.T) {
+	for range 100 {
+		d := wait(100*time.Millisecond, 0)
+		assert.GreaterOrEqual(t, d, 50*time.Millisecond)
+		assert.LessOrEqual(t, d, 100*time.Millisecond)
+	}
+	assert.Equal(t, 3*time.Second, wait(100*time.Millisecond, 3*time.Second))
+
+	now := time.Date(2022, 2, 2, 19, 39, 5, 0, time.UTC)
+	assert.Equal(t, 2*time.Second, parseRetryAfter("2", now))
+	assert.Equal(t, 10*time.Second, parseRetryAfter(now.Add(10*time.Second).Format(http.TimeFormat), now))
+	assert.Zero(t, parseRetryAfter("soon", now))
+	assert.Zero(t, parseRetryAfter("", now))
+	assert.False(t, errors.Is(&Error{StatusCode: http.StatusBadGateway}, ErrQueueFull))
+	assert.ErrorIs(t, &Error{StatusCode: http.StatusBadGateway}, ErrServer)
+}
@@ -0,0 +1,97 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+)
+
+// Errors matched by the *Error of a failed request, one per problem code of
+// the API. They are the server's own sentinel errors.
+var (
+	ErrNotFound       = model.ErrNotFound
+	ErrInvalidPayload = model.ErrInvalidPayload
+	ErrConflict       = model.ErrConflict
+	ErrQueueFull      = model.ErrQueueFull
+	ErrNotAcceptable  = model.ErrNotAcceptable
+	ErrUnauthorized   = model.ErrUnauthorized
+	ErrRateLimited    = model.ErrRateLimited
+	// ErrServer is matched by errors the API does not classify, such as
+	// INTERNAL_ERROR.
+	ErrServer = errors.New("server error")
+)
+
+// problemErrors maps the API's stable problem codes to errors, with the HTTP
+// status used when a response carries no problem body.
+var problemErrors = []struct {
+	code   string
+	status int
+	err    error
+}{
+	{"NOT_FOUND", http.StatusNotFound, ErrNotFound},
+	{"INVALID_PAYLOAD", http.StatusBadRequest, ErrInvalidPayload},
+	{"CONFLICT", http.StatusConflict, ErrConflict},
+	{"RATE_LIMITED", http.StatusTooManyRequests, ErrRateLimited},
+	{"QUEUE_FULL", http.StatusServiceUnavailable, ErrQueueFull},
+	{"NOT_ACCEPTABLE", http.StatusNotAcceptable, ErrNotAcceptable},
+	{"UNAUTHORIZED", http.StatusUnauthorized, ErrUnauthorized},
+}
+
+// Error is a request the API answered with an error status.
+type Error struct {
+	Method     string
+	Path       string
+	StatusCode int
+	// Problem is the RFC 7807 body of the response. Only Status is set when
+	// the response had no problem body.
+	Problem Problem
+	// RetryAfter is the wait the server asked for, or 0.
+	RetryAfter time.Duration
+}
+
+func newError(method, path string, resp *http.Response) *Error {
+	e := &Error{
+		Method:     method,
+		Path:       path,
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if json.Unmarshal(body, &e.Problem) != nil || e.Problem.Status == 0 {
+		e.Problem = Problem{Status: resp.StatusCode}
+	}
+	return e
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%s %s: %d %s", e.Method, e.Path, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Problem.Code != "" {
+		msg += " (" + e.Problem.Code + ")"
+	}
+	if e.Problem.Detail != "" {
+		msg += ": " + e.Problem.Detail
+	}
+	return msg
+}
+
+// Unwrap returns the sentinel error of the problem code, or of the status
+// when there is no code.
+func (e *Error) Unwrap() error {
+	for _, p := range problemErrors {
+		if e.Problem.Code == p.code || e.Problem.Code == "" && e.StatusCode == p.status {
+			return p.err
+		}
+	}
+	return ErrServer
+}
+
+// Temporary reports whether the server shed load and the request may succeed
+// if retried later: 429 and 503 responses.
+func (e *Error) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode == http.StatusServiceUnavailable
+}
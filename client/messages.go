@@ -0,0 +1,63 @@
+package client
+
+import (
+	"encoding/json"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+)
+
+// The message types of the API.
+const (
+	RocketLaunched       = model.RocketLaunched
+	RocketSpeedIncreased = model.RocketSpeedIncreased
+	RocketSpeedDecreased = model.RocketSpeedDecreased
+	RocketExploded       = model.RocketExploded
+	RocketMissionChanged = model.RocketMissionChanged
+)
+
+// NewMessage returns a message of messageType for channel with payload as its
+// message section. Its time is left zero, so that Client.Send stamps it.
+// The builders below cover every message type.
+func NewMessage(channel string, number int, messageType MessageType, payload any) (Message, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{
+		Metadata: Metadata{
+			Channel:       channel,
+			MessageNumber: number,
+			MessageType:   messageType,
+		},
+		Message: data,
+	}, nil
+}
+
+// build is NewMessage for payloads that always encode.
+func build(channel string, number int, messageType MessageType, payload any) Message {
+	msg, err := NewMessage(channel, number, messageType, payload)
+	if err != nil {
+		panic(err)
+	}
+	return msg
+}
+
+func Launched(channel string, number int, rocketType string, launchSpeed int, mission string) Message {
+	return build(channel, number, RocketLaunched, model.LaunchedMessage{Type: rocketType, LaunchSpeed: launchSpeed, Mission: mission})
+}
+
+func SpeedIncreased(channel string, number, by int) Message {
+	return build(channel, number, RocketSpeedIncreased, model.SpeedChangedMessage{By: by})
+}
+
+func SpeedDecreased(channel string, number, by int) Message {
+	return build(channel, number, RocketSpeedDecreased, model.SpeedChangedMessage{By: by})
+}
+
+func Exploded(channel string, number int, reason string) Message {
+	return build(channel, number, RocketExploded, model.RocketExplodedMessage{Reason: reason})
+}
+
+func MissionChanged(channel string, number int, newMission string) Message {
+	return build(channel, number, RocketMissionChanged, model.MissionChangedMessage{NewMission: newMission})
+}
@@ -1,67 +1,321 @@
 package cmd
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"log/slog"
+	"net/http"
 	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/seansa/rocket-challenge/docs"
+	"github.com/seansa/rocket-challenge/internal/config"
 	"github.com/seansa/rocket-challenge/internal/controller"
+	"github.com/seansa/rocket-challenge/internal/keyring"
+	"github.com/seansa/rocket-challenge/internal/logging"
+	"github.com/seansa/rocket-challenge/internal/metrics"
 	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/seansa/rocket-challenge/internal/queue"
+	"github.com/seansa/rocket-challenge/internal/ratelimit"
 	"github.com/seansa/rocket-challenge/internal/repository"
 	"github.com/seansa/rocket-challenge/internal/service"
+	"github.com/seansa/rocket-challenge/internal/webhook"
 	swaggerFiles "github.com/swaggo/files"
 
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
 var (
-	repo           repository.Repository[model.Rocket]
-	srv            service.Service
-	ctrl           *controller.RocketController
-	messageChannel = make(chan model.IncomingMessage, 1000)
-	numWorkers     = 5
+	cfg          = config.Default()
+	repo         repository.Repository[model.Rocket]
+	srv          service.Service
+	ctrl         *controller.RocketController
+	webhooks     *webhook.Dispatcher
+	webhookCtrl  *controller.WebhookController
+	heartbeats   *service.Heartbeats
+	pool         *service.WorkerPool
+	receipts     *service.Receipts
+	healthCtrl   *controller.HealthController
+	adminCtrl    *controller.AdminController
+	messageQueue queue.Queue
+	radio        *radioListener
+	// stopRadio stops the radio listeners on shutdown; the other stop
+	// functions cancel a background loop and wait for it to return.
+	stopRadio     context.CancelFunc = func() {}
+	stopAutoscale                    = func() {}
+	stopContact                      = func() {}
+	stopWebhooks                     = func() {}
 )
 
+// commands are the subcommands Run dispatches on instead of starting the server.
+var commands = map[string]func(args []string, stdout, stderr io.Writer) error{
+	"replay":   runReplay,
+	"simulate": runSimulate,
+}
+
+// Run starts the server, or runs the subcommand named by the first argument.
 func Run() {
-	port := getOrDefault("PORT", ":8088")
+	if len(os.Args) > 1 {
+		if command, exists := commands[os.Args[1]]; exists {
+			if err := command(os.Args[2:], os.Stdout, os.Stderr); err != nil && !errors.Is(err, flag.ErrHelp) {
+				fatal(os.Args[1]+" failed", err)
+			}
+			return
+		}
+	}
+
+	loaded, err := config.Load(os.Args[0], os.Args[1:], os.Getenv, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fatal("Failed to load configuration", err)
+	}
+	cfg = loaded
+	setupLogging()
+	slog.Info("Configuration loaded", "config", cfg.Redacted())
+	if cfg.Admin.Token == "" {
+		slog.Warn("No admin token configured; the /admin and /webhooks endpoints are disabled until ADMIN_TOKEN is set")
+	}
 
 	setupDependencies()
+	setupSigning()
+	setupRateLimits()
+	setupMetrics()
 	setupWorkers()
-	r := setupRoutes()
+	setupContactMonitor()
+	setupRadio()
+	server := &http.Server{
+		Addr:              cfg.Server.Addr,
+		Handler:           setupRoutes(),
+		ReadHeaderTimeout: time.Duration(cfg.Server.ReadHeaderTimeout),
+	}
+
+	go func() {
+		slog.Info("Server listening", "url", "http://localhost"+cfg.Server.Addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal("Server failed", err)
+		}
+	}()
+
+	waitForShutdown(server)
+}
+
+// setupLogging installs the configured logger as the slog default. Output of the
+// standard log package, such as the libraries', goes through it as well.
+func setupLogging() {
+	logger, err := logging.New(os.Stderr, cfg.Logging.Level, cfg.Logging.Format)
+	if err != nil {
+		fatal("Invalid logging configuration", err)
+	}
+	slog.SetDefault(logger)
+}
 
-	log.Printf("Server listening on http://localhost%s", port)
-	log.Fatal(r.Run(port))
+// fatal logs err and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
 
 func setupDependencies() {
-	repo = repository.NewRepository[model.Rocket]()
+	var err error
+	if messageQueue, err = openQueue(cfg.Queue); err != nil {
+		fatal("Failed to open message queue", err)
+	}
+	// config.Validate only accepts the in-memory backend so far.
+	repo = repository.WithMetrics(repository.NewRepository[model.Rocket]())
 	srv = service.NewRocketService(repo)
-	ctrl = controller.NewRocketController(srv, messageChannel)
+	ctrl = controller.NewRocketController(srv, messageQueue)
+	ctrl.SetAllowedOrigins(cfg.Server.AllowedOrigins)
+	webhooks = webhook.NewDispatcher(webhookOptions(cfg.Webhooks))
+	webhookCtrl = controller.NewWebhookController(webhooks)
+	radio = newRadioListener(messageQueue)
+	heartbeats = service.NewHeartbeats(time.Duration(cfg.Workers.StallTimeout))
+	healthCtrl = controller.NewHealthController(srv, heartbeats, messageQueue, cfg.Queue.ReadyThreshold)
+	pool = service.NewWorkerPool(messageQueue, srv, heartbeats, cfg.Workers.Max)
+	ctrl.SetBackpressure(time.Duration(cfg.Queue.EnqueueWait), pool)
+	receipts = service.NewReceipts(cfg.Receipts.Capacity, time.Duration(cfg.Receipts.TTL))
+	ctrl.SetReceipts(receipts)
+	pool.SetReceipts(receipts)
+	adminCtrl = controller.NewAdminController(cfg, pool)
+}
+
+// openQueue returns the configured message queue. The disk queue hands out the
+// messages left unacknowledged by the previous run first.
+func openQueue(c config.QueueConfig) (queue.Queue, error) {
+	if c.Backend != config.QueueBackendDisk {
+		return queue.NewMemory(c.Size, queueLanes(c)), nil
+	}
+	q, err := queue.OpenDisk(c.Dir, queue.DiskOptions{
+		Capacity:     c.Size,
+		SegmentSize:  int64(c.SegmentSize),
+		Sync:         c.Sync,
+		MaxAttempts:  c.MaxAttempts,
+		RetryBackoff: time.Duration(c.RetryBackoff),
+		Lanes:        queueLanes(c),
+	})
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("Durable message queue opened", "dir", c.Dir, "queued", q.Len())
+	return q, nil
+}
+
+func queueLanes(c config.QueueConfig) queue.Lanes {
+	lanes := queue.Lanes{Weights: c.LaneWeights, Priorities: make(map[model.MessageType]int, len(c.Priorities))}
+	for messageType, lane := range c.Priorities {
+		lanes.Priorities[model.MessageType(messageType)] = lane
+	}
+	return lanes
+}
+
+func webhookOptions(c config.WebhooksConfig) webhook.Options {
+	opts := webhook.DefaultOptions()
+	opts.MaxAttempts = c.MaxAttempts
+	opts.InitialBackoff = time.Duration(c.InitialBackoff)
+	opts.MaxBackoff = time.Duration(c.MaxBackoff)
+	opts.DisableAfter = c.DisableAfter
+	opts.QueueSize = c.QueueSize
+	opts.Timeout = time.Duration(c.Timeout)
+	opts.AllowedHosts = c.AllowedHosts
+	return opts
 }
 
 func setupWorkers() {
-	service.StartMessageProcessor(messageChannel, srv, numWorkers)
-	log.Printf("Started %d message processing workers.", numWorkers)
+	if err := pool.Resize(cfg.Workers.Count); err != nil {
+		fatal("Failed to start workers", err)
+	}
+	slog.Info("Started message processing workers", "workers", cfg.Workers.Count)
+
+	if autoscale := cfg.Workers.Autoscale; autoscale.Enabled {
+		opts := service.AutoscaleOptions{
+			Min:            autoscale.Min,
+			Max:            cfg.Workers.Max,
+			HighWater:      autoscale.HighWater,
+			Interval:       time.Duration(autoscale.Interval),
+			ScaleUpAfter:   time.Duration(autoscale.ScaleUpAfter),
+			ScaleDownAfter: time.Duration(autoscale.ScaleDownAfter),
+		}
+		stopAutoscale = background(func(ctx context.Context) { pool.Autoscale(ctx, opts) })
+	}
+
+	stopWebhooks = background(func(ctx context.Context) { webhooks.Run(ctx, srv.Events()) })
+}
+
+// background runs loop in a goroutine. The returned function cancels the
+// loop's context and waits for it to return.
+func background(loop func(ctx context.Context)) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		loop(ctx)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// setupContactMonitor flags rockets that stay silent for longer than the
+// configured threshold. A threshold of 0 disables it.
+func setupContactMonitor() {
+	contact := cfg.Contact
+	if contact.LostAfter == 0 {
+		return
+	}
+	stopContact = background(func(ctx context.Context) {
+		service.MonitorContact(ctx, srv, time.Duration(contact.LostAfter), time.Duration(contact.CheckInterval))
+	})
+	slog.Info("Contact monitor started", "lost_after", time.Duration(contact.LostAfter), "interval", time.Duration(contact.CheckInterval))
+}
+
+// setupSigning enables signed ingestion on POST /messages when a keyfile is
+// configured. The keyfile is polled for changes so secrets can be rotated live.
+func setupSigning() {
+	if cfg.Signing.Keyfile == "" {
+		return
+	}
+
+	keys, err := keyring.Load(cfg.Signing.Keyfile)
+	if err != nil {
+		fatal("Failed to load ingestion keys", err)
+	}
+	go keys.Watch(context.Background(), time.Duration(cfg.Signing.Reload))
+
+	ctrl.SetMessageVerifier(keyring.NewVerifier(keys, time.Duration(cfg.Signing.Tolerance)))
+	slog.Info("Signed ingestion enabled", "keyfile", cfg.Signing.Keyfile)
+}
+
+// setupRateLimits throttles POST /messages per channel and per client so that a
+// single chatty producer cannot fill the message queue for everyone. A rate
+// of 0 disables a limit.
+func setupRateLimits() {
+	limits := cfg.Limits
+	ctrl.SetAPIKeys(limits.APIKeys)
+	ctrl.SetRateLimiters(
+		ratelimit.NewLimiter("channel", limits.Channel, limits.ChannelOverrides),
+		ratelimit.NewLimiter("client", limits.Client, nil),
+	)
+	slog.Info("Rate limits configured", "channel", limits.Channel.String(), "channel_overrides", len(limits.ChannelOverrides), "client", limits.Client.String(), "api_keys", len(limits.APIKeys))
+}
+
+func setupRadio() {
+	var ctx context.Context
+	ctx, stopRadio = context.WithCancel(context.Background())
+	if err := radio.start(ctx, cfg.Radio.TCPAddr, cfg.Radio.UDPAddr); err != nil {
+		fatal("Failed to start radio listener", err)
+	}
 }
 
 func setupRoutes() *gin.Engine {
-	r := gin.Default()
+	r := gin.New()
+	// Without trusted proxies, ClientIP is the peer address and
+	// X-Forwarded-For cannot be used to escape the client rate limit.
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		fatal("Invalid trusted proxies", err)
+	}
+	r.Use(controller.RequestID())
+	if cfg.Logging.Requests {
+		r.Use(controller.AccessLog())
+	}
+	r.Use(gin.Recovery())
 	r.RedirectTrailingSlash = false
+	r.Use(controller.ErrorHandler())
 
 	r.POST("/messages", ctrl.MessageHandler)
+	r.GET("/messages/:id", ctrl.GetMessageReceiptHandler)
 	r.GET("/rockets", ctrl.GetAllRocketsHandler)
+	r.GET("/rockets/stream", ctrl.StreamRocketsHandler)
 	r.GET("/rockets/:channel", ctrl.GetRocketStateHandler)
+	r.GET("/rockets/:channel/stream", ctrl.StreamRocketStateHandler)
+	r.GET("/stats", ctrl.GetStatsHandler)
+	r.GET("/ws", ctrl.WebSocketHandler)
+	r.GET("/metrics", gin.WrapH(metrics.Default.Handler()))
+	r.GET("/healthz", healthCtrl.LivenessHandler)
+	r.GET("/readyz", healthCtrl.ReadinessHandler)
+
+	// Webhooks make the server call out, so they take the admin token.
+	hooks := r.Group("/webhooks", controller.AdminAuth(cfg.Admin.Token))
+	hooks.POST("", webhookCtrl.CreateWebhookHandler)
+	hooks.GET("", webhookCtrl.ListWebhooksHandler)
+	hooks.GET("/:id", webhookCtrl.GetWebhookHandler)
+	hooks.DELETE("/:id", webhookCtrl.DeleteWebhookHandler)
+	hooks.POST("/:id/enable", webhookCtrl.EnableWebhookHandler)
+	hooks.GET("/:id/deliveries", webhookCtrl.GetWebhookDeliveriesHandler)
+
+	admin := r.Group("/admin", controller.AdminAuth(cfg.Admin.Token))
+	admin.GET("/config", adminCtrl.GetConfigHandler)
+	admin.GET("/workers", adminCtrl.GetWorkersHandler)
+	admin.PUT("/workers", adminCtrl.SetWorkersHandler)
+	admin.GET("/processing", adminCtrl.GetProcessingHandler)
+	admin.POST("/processing/pause", adminCtrl.PauseProcessingHandler)
+	admin.POST("/processing/resume", adminCtrl.ResumeProcessingHandler)
 
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	return r
 }
-
-func getOrDefault(key string, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
-	}
-	return value
-}
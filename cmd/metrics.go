@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"strconv"
+
+	"github.com/seansa/rocket-challenge/internal/metrics"
+	"github.com/seansa/rocket-challenge/internal/model"
+)
+
+// setupMetrics registers the gauges that are read from the wired dependencies
+// at scrape time. Counters and histograms are registered by the packages that
+// update them.
+func setupMetrics() {
+	metrics.Default.NewGaugeFunc("rocket_queue_depth",
+		"Messages waiting in the message queue.", func() float64 {
+			return float64(messageQueue.Len())
+		})
+	metrics.Default.NewGaugeVecFunc("rocket_queue_lane_depth",
+		"Messages waiting in each priority lane of the message queue.", "lane", func() map[string]float64 {
+			depths := make(map[string]float64)
+			for lane, depth := range messageQueue.LaneLens() {
+				depths[strconv.Itoa(lane)] = float64(depth)
+			}
+			return depths
+		})
+	metrics.Default.NewGaugeFunc("rocket_queue_capacity",
+		"Capacity of the message queue.", func() float64 {
+			return float64(messageQueue.Cap())
+		})
+	metrics.Default.NewGaugeFunc("rocket_queue_drain_rate",
+		"Messages per second the workers took off the queue over the last 10s.", func() float64 {
+			return pool.DrainRate()
+		})
+	metrics.Default.NewGaugeFunc("rocket_processing_paused",
+		"1 while message processing is paused through the admin API.", func() float64 {
+			if pool.Paused() {
+				return 1
+			}
+			return 0
+		})
+	metrics.Default.NewGaugeFunc("rocket_workers",
+		"Message processing workers in the pool.", func() float64 {
+			return float64(pool.Size())
+		})
+	radioCounter := func(name, help string, value func(RadioStats) int64) {
+		metrics.Default.NewCounterFunc(name, help, func() float64 {
+			return float64(value(radio.Stats()))
+		})
+	}
+	radioCounter("rocket_radio_connections_total", "TCP connections accepted by the radio listener.",
+		func(s RadioStats) int64 { return s.Connections })
+	radioCounter("rocket_radio_accepted_total", "Radio messages fed into the message queue.",
+		func(s RadioStats) int64 { return s.Accepted })
+	radioCounter("rocket_radio_malformed_total", "Radio lines and datagrams skipped as malformed.",
+		func(s RadioStats) int64 { return s.Malformed })
+	radioCounter("rocket_radio_dropped_total", "Radio datagrams dropped because the message queue was full.",
+		func(s RadioStats) int64 { return s.Dropped })
+	metrics.Default.NewGaugeVecFunc("rocket_rockets",
+		"Known rockets by state.", "status", func() map[string]float64 {
+			// Every state is always exported so that rate() and alerts see zeros.
+			counts := map[string]float64{
+				model.StatusAwaitingLaunch: 0,
+				model.StatusInFlight:       0,
+				model.StatusExploded:       0,
+			}
+			for status, count := range srv.GetStats().ByStatus {
+				counts[status] = float64(count)
+			}
+			return counts
+		})
+}
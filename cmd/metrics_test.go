@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scrapeMetrics fetches /metrics and returns every sample keyed by its name and
+// label set as written.
+func scrapeMetrics(t *testing.T, r *gin.Engine) map[string]float64 {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4"))
+
+	samples := map[string]float64{}
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		require.Positive(t, i, "sample line %q", line)
+		value, err := strconv.ParseFloat(line[i+1:], 64)
+		require.NoError(t, err, "sample line %q", line)
+		samples[line[:i]] = value
+	}
+	return samples
+}
+
+// TestMetricsEndpoint tests that ingestion, processing and repository activity
+// show up at /metrics.
+func TestMetricsEndpoint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupDependencies()
+	setupMetrics()
+	r := setupRoutes()
+
+	before := scrapeMetrics(t, r)
+
+	body := fmt.Sprintf(radioTestMessage, "metrics-rocket", 1)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader(body)))
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader("{")))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	queued := scrapeMetrics(t, r)
+	assert.Equal(t, 1.0, queued["rocket_queue_depth"])
+	assert.Equal(t, float64(messageQueue.Cap()), queued["rocket_queue_capacity"])
+
+	msg := (<-messageQueue.Deliveries()).Message
+	_, err := srv.ProcessMessage(&msg)
+	require.NoError(t, err)
+
+	after := scrapeMetrics(t, r)
+	delta := func(key string) float64 { return after[key] - before[key] }
+	assert.Equal(t, 1.0, delta(`rocket_messages_received_total{result="accepted"}`))
+	assert.Equal(t, 1.0, delta(`rocket_messages_received_total{result="invalid"}`))
+	assert.Equal(t, 1.0, delta(`rocket_messages_processed_total{status="processed"}`))
+	assert.Equal(t, 1.0, delta(`rocket_message_processing_seconds_count{status="processed"}`))
+	assert.Equal(t, 1.0, delta(`rocket_message_processing_seconds_bucket{status="processed",le="+Inf"}`))
+	assert.Equal(t, 1.0, delta(`rocket_repository_operation_seconds_count{op="save"}`))
+	assert.Equal(t, 0.0, after["rocket_queue_depth"])
+	// A speed change before the launch message leaves the rocket awaiting launch.
+	assert.Equal(t, 1.0, after[`rocket_rockets{status="awaiting_launch"}`])
+	assert.Contains(t, after, `rocket_rockets{status="exploded"}`)
+	assert.Contains(t, after, "rocket_radio_dropped_total", "radio counters are exported even when it is disabled")
+}
@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+
+	"github.com/seansa/rocket-challenge/internal/controller"
+	"github.com/seansa/rocket-challenge/internal/logging"
+	"github.com/seansa/rocket-challenge/internal/queue"
+)
+
+// maxRadioMessageSize bounds a single NDJSON line or UDP datagram.
+const maxRadioMessageSize = 64 * 1024
+
+// RadioStats counts what the radio listener has received since it started.
+type RadioStats struct {
+	Connections int64 `json:"connections"`
+	Accepted    int64 `json:"accepted"`
+	Malformed   int64 `json:"malformed"`
+	Dropped     int64 `json:"dropped"`
+}
+
+// radioListener accepts model.IncomingMessage telemetry from radio gateways
+// over raw sockets: newline-delimited JSON over TCP and one JSON message per
+// UDP datagram. Messages are validated like POST /messages and fed into the
+// same message queue.
+type radioListener struct {
+	queue queue.Queue
+	mutex sync.Mutex
+	stats RadioStats
+	// serving tracks the serve loops and the TCP connections, so that Wait
+	// can tell when nothing enqueues anymore.
+	serving sync.WaitGroup
+}
+
+func newRadioListener(q queue.Queue) *radioListener {
+	return &radioListener{queue: q}
+}
+
+// Stats returns the listener-wide counters.
+func (r *radioListener) Stats() RadioStats {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.stats
+}
+
+func (r *radioListener) count(update func(stats *RadioStats)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	update(&r.stats)
+}
+
+// Wait blocks until the listeners started by start and their connections
+// have stopped, after their context is done.
+func (r *radioListener) Wait() {
+	r.serving.Wait()
+}
+
+// ServeTCP accepts gateway connections until ctx is done or the listener
+// fails. Open connections are closed when ctx is done.
+func (r *radioListener) ServeTCP(ctx context.Context, listener net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		r.count(func(stats *RadioStats) { stats.Connections++ })
+		r.serving.Add(1)
+		go func() {
+			defer r.serving.Done()
+			r.handleConn(ctx, conn)
+		}()
+	}
+}
+
+// handleConn reads NDJSON from a single TCP connection. Enqueueing blocks when
+// the message queue is full, which pushes back on the gateway through TCP
+// flow control instead of dropping telemetry. Lines longer than
+// maxRadioMessageSize are skipped and counted as malformed.
+func (r *radioListener) handleConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	// Closing the connection unblocks the read when ctx is done.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	remote := conn.RemoteAddr().String()
+	logger := slog.With("gateway", remote)
+	logger.Info("Radio gateway connected")
+
+	var connStats RadioStats
+	malformed := func(err error) {
+		connStats.Malformed++
+		r.count(func(stats *RadioStats) { stats.Malformed++ })
+		logger.Warn("Radio gateway sent a malformed line", "error", err)
+	}
+	reader := bufio.NewReaderSize(conn, maxRadioMessageSize)
+	for {
+		line, readErr := reader.ReadSlice('\n')
+		if errors.Is(readErr, bufio.ErrBufferFull) {
+			malformed(errRadioLineTooLong)
+			if readErr = skipLine(reader); readErr == nil {
+				continue
+			}
+			line = nil
+		}
+
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			msg, err := controller.DecodeMessage(line)
+			if err != nil {
+				malformed(err)
+			} else {
+				msg.RequestID = logging.NewRequestID()
+				if err := r.queue.Enqueue(ctx, msg); err != nil {
+					if ctx.Err() == nil {
+						logger.Error("Radio gateway message could not be enqueued", "error", err)
+					}
+					return
+				}
+				connStats.Accepted++
+				r.count(func(stats *RadioStats) { stats.Accepted++ })
+			}
+		}
+
+		if readErr != nil {
+			if !errors.Is(readErr, io.EOF) && ctx.Err() == nil {
+				logger.Error("Radio gateway read error", "error", readErr)
+			}
+			break
+		}
+	}
+
+	logger.Info("Radio gateway disconnected", "accepted", connStats.Accepted, "malformed", connStats.Malformed)
+}
+
+// errRadioLineTooLong is reported for lines longer than maxRadioMessageSize.
+var errRadioLineTooLong = errors.New("line exceeds the maximum radio message size")
+
+// skipLine discards the rest of the current line, up to and including its
+// newline.
+func skipLine(reader *bufio.Reader) error {
+	for {
+		if _, err := reader.ReadSlice('\n'); !errors.Is(err, bufio.ErrBufferFull) {
+			return err
+		}
+	}
+}
+
+// ServeUDP reads one message per datagram until ctx is done. Datagrams that
+// arrive while the message queue is full are dropped and counted.
+func (r *radioListener) ServeUDP(ctx context.Context, conn net.PacketConn) error {
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	buf := make([]byte, maxRadioMessageSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		msg, err := controller.DecodeMessage(buf[:n])
+		if err != nil {
+			r.count(func(stats *RadioStats) { stats.Malformed++ })
+			slog.Warn("Radio datagram is malformed", "gateway", addr, "error", err)
+			continue
+		}
+		msg.RequestID = logging.NewRequestID()
+
+		if err := r.queue.TryEnqueue(msg); err != nil {
+			r.count(func(stats *RadioStats) { stats.Dropped++ })
+			logging.ForMessage(&msg).Warn("Radio datagram dropped", "gateway", addr, "error", err)
+			continue
+		}
+		r.count(func(stats *RadioStats) { stats.Accepted++ })
+	}
+}
+
+// start starts the optional listeners until ctx is done. An empty address
+// disables the corresponding transport.
+func (r *radioListener) start(ctx context.Context, tcpAddr, udpAddr string) error {
+	if tcpAddr != "" {
+		listener, err := net.Listen("tcp", tcpAddr)
+		if err != nil {
+			return err
+		}
+		slog.Info("Radio NDJSON listener started", "addr", "tcp "+listener.Addr().String())
+		r.serving.Add(1)
+		go func() {
+			defer r.serving.Done()
+			if err := r.ServeTCP(ctx, listener); err != nil {
+				slog.Error("Radio TCP listener stopped", "error", err)
+			}
+		}()
+	}
+
+	if udpAddr != "" {
+		conn, err := net.ListenPacket("udp", udpAddr)
+		if err != nil {
+			return err
+		}
+		slog.Info("Radio datagram listener started", "addr", "udp "+conn.LocalAddr().String())
+		r.serving.Add(1)
+		go func() {
+			defer r.serving.Done()
+			if err := r.ServeUDP(ctx, conn); err != nil {
+				slog.Error("Radio UDP listener stopped", "error", err)
+			}
+		}()
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/seansa/rocket-challenge/internal/queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const radioTestMessage = `{"metadata":{"channel":"%s","messageNumber":%d,"messageTime":"2022-02-02T19:39:05.86337+01:00","messageType":"RocketSpeedIncreased"},"message":{"by":100}}`
+
+func receiveMessage(t *testing.T, q queue.Queue) model.IncomingMessage {
+	t.Helper()
+	select {
+	case delivery := <-q.Deliveries():
+		delivery.Ack()
+		return delivery.Message
+	case <-time.After(2 * time.Second):
+		t.Fatal("Message not received on channel within timeout")
+		return model.IncomingMessage{}
+	}
+}
+
+// TestRadioListener_TCP tests NDJSON ingestion and malformed-line accounting over TCP.
+func TestRadioListener_TCP(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q := queue.NewMemory(10, queue.Lanes{})
+	radio := newRadioListener(q)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = radio.ServeTCP(ctx, listener) }()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	_, err = fmt.Fprintf(conn, radioTestMessage+"\n\n{not json}\n"+radioTestMessage+"\n", "rocket-a", 1, "rocket-a", 2)
+	require.NoError(t, err)
+	// Missing channel fails the same validation as POST /messages.
+	_, err = fmt.Fprintf(conn, radioTestMessage+"\n", "", 3)
+	require.NoError(t, err)
+	conn.Close()
+
+	assert.Equal(t, 1, receiveMessage(t, q).Metadata.MessageNumber)
+	assert.Equal(t, 2, receiveMessage(t, q).Metadata.MessageNumber)
+	assert.Eventually(t, func() bool {
+		return radio.Stats() == RadioStats{Connections: 1, Accepted: 2, Malformed: 2}
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+// TestRadioListener_TCPLineTooLong tests that an over-long line is counted as
+// malformed and skipped without dropping the connection.
+func TestRadioListener_TCPLineTooLong(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q := queue.NewMemory(10, queue.Lanes{})
+	radio := newRadioListener(q)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = radio.ServeTCP(ctx, listener) }()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = fmt.Fprintf(conn, radioTestMessage+"\n%s\n"+radioTestMessage+"\n", "rocket-a", 1, strings.Repeat("x", 3*maxRadioMessageSize), "rocket-a", 2)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, receiveMessage(t, q).Metadata.MessageNumber)
+	assert.Equal(t, 2, receiveMessage(t, q).Metadata.MessageNumber)
+	assert.Eventually(t, func() bool {
+		return radio.Stats() == RadioStats{Connections: 1, Accepted: 2, Malformed: 1}
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+// TestRadioListener_UDP tests datagram ingestion and dropping when the queue is full.
+func TestRadioListener_UDP(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q := queue.NewMemory(1, queue.Lanes{})
+	radio := newRadioListener(q)
+
+	packetConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = radio.ServeUDP(ctx, packetConn) }()
+
+	conn, err := net.Dial("udp", packetConn.LocalAddr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = fmt.Fprintf(conn, radioTestMessage, "rocket-b", 7)
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool { return radio.Stats().Accepted == 1 }, 2*time.Second, 10*time.Millisecond)
+
+	_, _ = conn.Write([]byte(`{"metadata":`))
+	_, _ = fmt.Fprintf(conn, radioTestMessage, "rocket-b", 8)
+	assert.Eventually(t, func() bool {
+		return radio.Stats() == RadioStats{Accepted: 1, Malformed: 1, Dropped: 1}
+	}, 2*time.Second, 10*time.Millisecond)
+
+	msg := receiveMessage(t, q)
+	assert.Equal(t, "rocket-b", msg.Metadata.Channel)
+	assert.Equal(t, 7, msg.Metadata.MessageNumber)
+}
+
+// TestRadioListener_Stop tests that the listeners and their open connections
+// stop with their context.
+func TestRadioListener_Stop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	radio := newRadioListener(queue.NewMemory(10, queue.Lanes{}))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+	require.NoError(t, radio.start(ctx, addr, "127.0.0.1:0"))
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Eventually(t, func() bool { return radio.Stats().Connections == 1 }, 2*time.Second, 10*time.Millisecond)
+
+	cancel()
+	stopped := make(chan struct{})
+	go func() {
+		radio.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("radio listener did not stop")
+	}
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, err = conn.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF, "the connection is closed by the listener")
+}
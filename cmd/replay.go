@@ -0,0 +1,349 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seansa/rocket-challenge/internal/controller"
+	"github.com/seansa/rocket-challenge/internal/keyring"
+	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/seansa/rocket-challenge/internal/queue"
+	"github.com/seansa/rocket-challenge/internal/repository"
+	"github.com/seansa/rocket-challenge/internal/service"
+	"github.com/seansa/rocket-challenge/internal/signature"
+)
+
+// maxCaptureLineSize bounds a single message in a JSONL capture.
+const maxCaptureLineSize = 1024 * 1024
+
+// perturbation describes how a message sequence is mangled before delivery
+// to reproduce ordering bugs.
+type perturbation struct {
+	shuffle   bool
+	duplicate float64
+	delay     float64
+	delayBy   int
+}
+
+func (p perturbation) active() bool {
+	return p.shuffle || p.duplicate > 0 || p.delay > 0
+}
+
+func (p perturbation) validate() error {
+	if p.delayBy < 1 || p.duplicate < 0 || p.duplicate > 1 || p.delay < 0 || p.delay > 1 {
+		return fmt.Errorf("duplicate and delay rates must be in [0, 1] and -delay-by positive")
+	}
+	return nil
+}
+
+// postOptions configure posting messages to a running server.
+type postOptions struct {
+	target  string
+	rate    float64
+	apiKey  string
+	keyfile string
+}
+
+func (o *postOptions) register(flags *flag.FlagSet) {
+	flags.StringVar(&o.target, "target", "", "base URL of a running server to POST the messages to, e.g. http://localhost:8088")
+	flags.Float64Var(&o.rate, "rate", 0, "messages per second when posting to -target; 0 sends as fast as possible")
+	flags.StringVar(&o.apiKey, "api-key", "", "value of the "+controller.APIKeyHeader+" header when posting to -target")
+	flags.StringVar(&o.keyfile, "keyfile", "", "keyfile used to sign messages when posting to -target")
+}
+
+type replayOptions struct {
+	post    postOptions
+	perturb perturbation
+	seed    int64
+	workers int
+	verbose bool
+}
+
+// runReplay implements the replay subcommand. It reads a JSONL capture of
+// model.IncomingMessage and either posts it to a running server or processes
+// it offline against an in-memory repository and prints the fleet state.
+func runReplay(args []string, stdout, stderr io.Writer) error {
+	var opts replayOptions
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: %s replay [flags] <capture.jsonl | ->\n\n", os.Args[0])
+		fmt.Fprintf(stderr, "Without -target the capture is processed offline and the resulting fleet state is printed as JSON.\n\n")
+		flags.PrintDefaults()
+	}
+	opts.post.register(flags)
+	flags.IntVar(&opts.workers, "workers", 1, "concurrent workers when replaying offline")
+	flags.BoolVar(&opts.verbose, "v", false, "log what happens to every message when replaying offline")
+	flags.Int64Var(&opts.seed, "seed", 0, "seed for -shuffle, -duplicate and -delay; 0 picks one and prints it")
+	flags.BoolVar(&opts.perturb.shuffle, "shuffle", false, "replay the messages in random order")
+	flags.Float64Var(&opts.perturb.duplicate, "duplicate", 0, "fraction of messages sent twice")
+	flags.Float64Var(&opts.perturb.delay, "delay", 0, "fraction of messages held back behind later ones")
+	flags.IntVar(&opts.perturb.delayBy, "delay-by", 5, "maximum number of positions a delayed message is held back")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected one capture file, got %d arguments", flags.NArg())
+	}
+	if opts.workers < 1 {
+		return fmt.Errorf("-workers must be positive")
+	}
+	if err := opts.perturb.validate(); err != nil {
+		return err
+	}
+
+	messages, err := readCapture(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if opts.perturb.active() {
+		if opts.seed == 0 {
+			opts.seed = time.Now().UnixNano()
+		}
+		fmt.Fprintf(stderr, "Perturbing %d messages with -seed %d.\n", len(messages), opts.seed)
+		messages = opts.perturb.apply(messages, rand.New(rand.NewSource(opts.seed)))
+	}
+
+	if opts.post.target != "" {
+		return postMessages(context.Background(), messages, opts.post, stderr)
+	}
+	return replayOffline(messages, opts, stdout, stderr)
+}
+
+// readCapture reads a JSONL file, or stdin for "-". Blank lines are skipped and
+// every message must pass the validation POST /messages applies.
+func readCapture(path string) ([]model.IncomingMessage, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	var messages []model.IncomingMessage
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxCaptureLineSize)
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		msg, err := controller.DecodeMessage(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		messages = append(messages, msg)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return messages, nil
+}
+
+// apply reorders and duplicates messages to reproduce delivery problems:
+// shuffling, duplicates sent back to back, and messages held back behind up
+// to delayBy later ones. The result only depends on the rng seed.
+func (p perturbation) apply(messages []model.IncomingMessage, rng *rand.Rand) []model.IncomingMessage {
+	out := append([]model.IncomingMessage(nil), messages...)
+	if p.shuffle {
+		rng.Shuffle(len(out), func(i, j int) { out[i], out[j] = out[j], out[i] })
+	}
+
+	if p.duplicate > 0 {
+		duplicated := make([]model.IncomingMessage, 0, len(out))
+		for _, msg := range out {
+			duplicated = append(duplicated, msg)
+			if rng.Float64() < p.duplicate {
+				duplicated = append(duplicated, msg)
+			}
+		}
+		out = duplicated
+	}
+
+	if p.delay > 0 {
+		// A delayed message sorts just after the message delayBy positions
+		// later; everything else keeps its place.
+		positions := make([]float64, len(out))
+		for i := range out {
+			positions[i] = float64(i)
+			if rng.Float64() < p.delay {
+				positions[i] += float64(rng.Intn(p.delayBy)+1) + 0.5
+			}
+		}
+		indexes := make([]int, len(out))
+		for i := range indexes {
+			indexes[i] = i
+		}
+		sort.SliceStable(indexes, func(a, b int) bool { return positions[indexes[a]] < positions[indexes[b]] })
+		delayed := make([]model.IncomingMessage, len(out))
+		for i, index := range indexes {
+			delayed[i] = out[index]
+		}
+		out = delayed
+	}
+	return out
+}
+
+// replayOffline processes messages through a fresh service and prints the
+// resulting rockets, sorted by channel, as a JSON array.
+func replayOffline(messages []model.IncomingMessage, opts replayOptions, stdout, stderr io.Writer) error {
+	if !opts.verbose {
+		defer silenceLogs()()
+	}
+
+	svc := service.NewRocketService(repository.NewRepository[model.Rocket]())
+	// The queue hands out each channel's messages in order, one at a time.
+	q := queue.NewMemory(len(messages), queue.Lanes{})
+	outcomes := make(map[string]int)
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for range opts.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for delivery := range q.Deliveries() {
+				status, err := svc.ProcessMessage(&delivery.Message)
+				delivery.Ack()
+				if err != nil {
+					status = "error"
+				}
+				mutex.Lock()
+				outcomes[status]++
+				mutex.Unlock()
+			}
+		}()
+	}
+	for _, msg := range messages {
+		if err := q.TryEnqueue(msg); err != nil {
+			return err
+		}
+	}
+	q.Close()
+	wg.Wait()
+
+	rockets, err := svc.GetAllRocketStates()
+	if err != nil {
+		return err
+	}
+	sort.Slice(rockets, func(i, j int) bool { return rockets[i].Channel < rockets[j].Channel })
+	if err := writeRockets(stdout, rockets); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stderr, "Replayed %d messages into %d rockets: %s\n", len(messages), len(rockets), formatCounts(outcomes))
+	return nil
+}
+
+// silenceLogs discards log output until the returned function is called.
+func silenceLogs() (restore func()) {
+	logger, writer := slog.Default(), log.Writer()
+	slog.SetDefault(slog.New(slog.DiscardHandler))
+	return func() {
+		slog.SetDefault(logger)
+		log.SetOutput(writer)
+	}
+}
+
+// writeRockets writes rockets as an indented JSON array, like GET /rockets.
+func writeRockets(w io.Writer, rockets []model.Rocket) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(rockets)
+}
+
+// postMessages posts messages to opts.target at opts.rate, signing them when a
+// keyfile is given, and reports the response status codes.
+func postMessages(ctx context.Context, messages []model.IncomingMessage, opts postOptions, stderr io.Writer) error {
+	var keys *keyring.Keyring
+	if opts.keyfile != "" {
+		var err error
+		if keys, err = keyring.Load(opts.keyfile); err != nil {
+			return err
+		}
+	}
+
+	var tick <-chan time.Time
+	if opts.rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / opts.rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	url := strings.TrimSuffix(opts.target, "/") + "/messages"
+	responses := make(map[string]int)
+	for i, msg := range messages {
+		if tick != nil && i > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-tick:
+			}
+		}
+
+		body, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if opts.apiKey != "" {
+			req.Header.Set(controller.APIKeyHeader, opts.apiKey)
+		}
+		if keys != nil {
+			if secret, ok := keys.Secret(msg.Metadata.Channel); ok {
+				req.Header.Set(signature.Header, signature.Sign(secret, time.Now(), body))
+			}
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			responses["error"]++
+			continue
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		responses[fmt.Sprint(resp.StatusCode)]++
+	}
+
+	fmt.Fprintf(stderr, "Posted %d messages to %s: %s\n", len(messages), url, formatCounts(responses))
+	return nil
+}
+
+// formatCounts renders counts as "key=n" pairs sorted by key.
+func formatCounts(counts map[string]int) string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = fmt.Sprintf("%s=%d", key, counts[key])
+	}
+	return strings.Join(pairs, " ")
+}
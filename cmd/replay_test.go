@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/seansa/rocket-challenge/internal/signature"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const replayTestCapture = `{"metadata":{"channel":"rocket-a","messageNumber":1,"messageTime":"2022-02-02T19:39:05Z","messageType":"RocketLaunched"},"message":{"type":"Falcon-9","launchSpeed":500,"mission":"ARTEMIS"}}
+{"metadata":{"channel":"rocket-a","messageNumber":2,"messageTime":"2022-02-02T19:39:06Z","messageType":"RocketSpeedIncreased"},"message":{"by":300}}
+
+{"metadata":{"channel":"rocket-b","messageNumber":1,"messageTime":"2022-02-02T19:39:05Z","messageType":"RocketLaunched"},"message":{"type":"Saturn-V","launchSpeed":100,"mission":"APOLLO"}}
+{"metadata":{"channel":"rocket-b","messageNumber":2,"messageTime":"2022-02-02T19:39:07Z","messageType":"RocketExploded"},"message":{"reason":"PRESSURE_VESSEL_FAILURE"}}
+`
+
+func writeCapture(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "capture.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+// TestReplay_Offline tests that a capture is processed into the fleet state.
+func TestReplay_Offline(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	err := runReplay([]string{"-workers", "2", writeCapture(t, replayTestCapture)}, &stdout, &stderr)
+	require.NoError(t, err)
+
+	var rockets []model.Rocket
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &rockets))
+	require.Len(t, rockets, 2)
+	assert.Equal(t, "rocket-a", rockets[0].Channel)
+	assert.Equal(t, 800, rockets[0].Speed)
+	assert.Equal(t, "rocket-b", rockets[1].Channel)
+	assert.True(t, rockets[1].Exploded)
+	assert.Contains(t, stderr.String(), "Replayed 4 messages into 2 rockets: processed=4")
+}
+
+// TestReplay_Errors tests that bad arguments and captures are reported.
+func TestReplay_Errors(t *testing.T) {
+	invalid := writeCapture(t, replayTestCapture+`{"metadata":{"messageNumber":1}}`+"\n")
+	cases := map[string][]string{
+		"no file":       nil,
+		"missing file":  {filepath.Join(t.TempDir(), "missing.jsonl")},
+		"invalid line":  {invalid},
+		"bad duplicate": {"-duplicate", "2", invalid},
+		"unknown flag":  {"-nope", invalid},
+		"zero workers":  {"-workers", "0", invalid},
+	}
+	for name, args := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Error(t, runReplay(args, &bytes.Buffer{}, &bytes.Buffer{}))
+		})
+	}
+
+	err := runReplay([]string{invalid}, &bytes.Buffer{}, &bytes.Buffer{})
+	assert.ErrorIs(t, err, model.ErrInvalidPayload)
+	assert.Contains(t, err.Error(), ":6:", "the line number is reported")
+}
+
+// TestPerturb tests that perturbation is reproducible from the seed and only
+// reorders and duplicates messages.
+func TestPerturb(t *testing.T) {
+	messages := make([]model.IncomingMessage, 100)
+	for i := range messages {
+		messages[i].Metadata.MessageNumber = i
+	}
+	p := perturbation{duplicate: 0.2, delay: 0.3, delayBy: 3}
+
+	first := p.apply(messages, rand.New(rand.NewSource(42)))
+	second := p.apply(messages, rand.New(rand.NewSource(42)))
+	assert.Equal(t, first, second)
+	assert.Greater(t, len(first), len(messages))
+
+	seen := make(map[int]int)
+	for i, msg := range first {
+		seen[msg.Metadata.MessageNumber]++
+		// A message is never held back more than delayBy positions behind a
+		// later one, counting the duplicates inserted before it.
+		if i > 0 {
+			assert.Less(t, first[i-1].Metadata.MessageNumber-msg.Metadata.MessageNumber, 2*p.delayBy+1)
+		}
+	}
+	assert.Len(t, seen, len(messages), "no message is lost")
+
+	shuffled := perturbation{shuffle: true}.apply(messages, rand.New(rand.NewSource(1)))
+	assert.NotEqual(t, messages, shuffled)
+	assert.ElementsMatch(t, messages, shuffled)
+	assert.Equal(t, messages, perturbation{}.apply(messages, rand.New(rand.NewSource(1))), "no options, no change")
+}
+
+// TestReplay_Target tests posting a capture to a server with an API key and signatures.
+func TestReplay_Target(t *testing.T) {
+	var mutex sync.Mutex
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body bytes.Buffer
+		_, _ = body.ReadFrom(r.Body)
+		_, err := signature.Verify([]byte("s3cr3t"), r.Header.Get(signature.Header), body.Bytes(), time.Now(), time.Minute)
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		received = append(received, fmt.Sprintf("%s %s %s %v", r.Method, r.URL.Path, r.Header.Get("X-API-Key"), err == nil))
+		if len(received) == 4 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	keyfile := filepath.Join(t.TempDir(), "keys")
+	require.NoError(t, os.WriteFile(keyfile, []byte("rocket-* s3cr3t\n"), 0o600))
+
+	var stderr bytes.Buffer
+	err := runReplay([]string{"-target", server.URL + "/", "-rate", "1000", "-api-key", "producer-1", "-keyfile", keyfile, writeCapture(t, replayTestCapture)}, &bytes.Buffer{}, &stderr)
+	require.NoError(t, err)
+
+	assert.Equal(t, strings.Repeat("POST /messages producer-1 true,", 4), strings.Join(received, ",")+",")
+	assert.Contains(t, stderr.String(), "Posted 4 messages")
+	assert.Contains(t, stderr.String(), "202=3 429=1")
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// waitForShutdown blocks until SIGINT or SIGTERM, then marks the service as not
+// ready and gracefully shuts the HTTP server down.
+func waitForShutdown(server *http.Server) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-signals
+	signal.Stop(signals)
+
+	// The delay gives the orchestrator time to see /readyz fail and stop
+	// routing traffic before the listener closes.
+	delay := time.Duration(cfg.Server.ShutdownDelay)
+	slog.Info("Shutting down", "signal", sig.String(), "delay", delay)
+	healthCtrl.SetShuttingDown()
+	time.Sleep(delay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Server.ShutdownTimeout))
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("Graceful shutdown failed", "error", err)
+		return
+	}
+	// Stop the radio listeners, so that nothing enqueues anymore, the
+	// autoscaler, so that it starts no workers, and the contact monitor, so
+	// that nothing writes to the repository behind the workers' backs. Then
+	// let the workers finish the messages they are processing, and the
+	// webhooks the deliveries they are making.
+	stopRadio()
+	radio.Wait()
+	stopAutoscale()
+	stopContact()
+	pool.Stop()
+	if err := messageQueue.Close(); err != nil {
+		slog.Error("Closing message queue failed", "error", err)
+	}
+	stopWebhooks()
+	webhooks.Close()
+	slog.Info("Server stopped")
+}
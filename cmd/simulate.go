@@ -0,0 +1,221 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+)
+
+var (
+	simulatedRocketTypes = []string{"Falcon-9", "Falcon-Heavy", "Saturn-V", "Starship", "Atlas-V", "Ariane-5"}
+	simulatedMissions    = []string{"ARTEMIS", "APOLLO", "GEMINI", "MERCURY", "SHUTTLE_MIR", "DRAGON"}
+	simulatedExplosions  = []string{"PRESSURE_VESSEL_FAILURE", "ENGINE_FAILURE", "GUIDANCE_FAILURE", "STRUCTURAL_FAILURE"}
+)
+
+type simulateOptions struct {
+	post     postOptions
+	perturb  perturbation
+	seed     int64
+	rockets  int
+	steps    int
+	explode  float64
+	interval time.Duration
+	jitter   time.Duration
+	expected string
+}
+
+// runSimulate implements the simulate subcommand. It generates flights for a
+// fleet of rockets, delivers their messages out of order, duplicated and
+// jittered as requested, and writes the state every rocket really ended in, in
+// the same JSON as GET /rockets.
+func runSimulate(args []string, stdout, stderr io.Writer) error {
+	var opts simulateOptions
+	flags := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: %s simulate [flags]\n\n", os.Args[0])
+		fmt.Fprintf(stderr, "Without -target the messages are written to stdout as JSONL.\n\n")
+		flags.PrintDefaults()
+	}
+	opts.post.register(flags)
+	flags.Int64Var(&opts.seed, "seed", 0, "seed for the flights and their delivery; 0 picks one and prints it")
+	flags.IntVar(&opts.rockets, "rockets", 10, "number of rockets")
+	flags.IntVar(&opts.steps, "steps", 20, "average number of messages per rocket after its launch")
+	flags.Float64Var(&opts.explode, "explode", 0.1, "fraction of rockets that explode during their flight")
+	flags.DurationVar(&opts.interval, "interval", time.Second, "messageTime interval between the messages of one rocket")
+	flags.DurationVar(&opts.jitter, "jitter", 0, "maximum random delivery delay; when above -interval, a rocket's messages overtake each other")
+	flags.Float64Var(&opts.perturb.delay, "out-of-order", 0, "fraction of messages held back behind later ones")
+	flags.IntVar(&opts.perturb.delayBy, "out-of-order-by", 5, "maximum number of positions an out-of-order message is held back")
+	flags.Float64Var(&opts.perturb.duplicate, "duplicate", 0, "fraction of messages sent twice")
+	flags.StringVar(&opts.expected, "expected", "", "file the expected final fleet state is written to; defaults to stdout with -target")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return fmt.Errorf("unexpected arguments %v", flags.Args())
+	}
+	if opts.rockets < 1 || opts.steps < 1 || opts.interval <= 0 || opts.jitter < 0 || opts.explode < 0 || opts.explode > 1 {
+		return fmt.Errorf("-rockets, -steps and -interval must be positive, -jitter not negative and -explode in [0, 1]")
+	}
+	if err := opts.perturb.validate(); err != nil {
+		return err
+	}
+	if opts.seed == 0 {
+		opts.seed = time.Now().UnixNano()
+	}
+	fmt.Fprintf(stderr, "Simulating %d rockets with -seed %d.\n", opts.rockets, opts.seed)
+
+	rng := rand.New(rand.NewSource(opts.seed))
+	flights := simulateFlights(rng, opts, time.Now().UTC().Truncate(time.Second))
+	messages := opts.perturb.apply(deliveryOrder(rng, flights, opts.jitter), rng)
+
+	expected, err := expectedFleet(flights)
+	if err != nil {
+		return err
+	}
+	switch {
+	case opts.expected != "":
+		file, err := os.Create(opts.expected)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		if err := writeRockets(file, expected); err != nil {
+			return err
+		}
+	case opts.post.target != "":
+		if err := writeRockets(stdout, expected); err != nil {
+			return err
+		}
+	}
+
+	if opts.post.target != "" {
+		return postMessages(context.Background(), messages, opts.post, stderr)
+	}
+	encoder := json.NewEncoder(stdout)
+	for _, msg := range messages {
+		if err := encoder.Encode(msg); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(stderr, "Wrote %d messages.\n", len(messages))
+	return nil
+}
+
+// simulateFlights generates one flight per rocket: a launch, a speed ramp up
+// followed by cruising, the odd mission change, and for some an explosion that
+// ends the flight. Messages are numbered from 1 and spaced by opts.interval,
+// with every rocket starting at a random offset within the first interval.
+func simulateFlights(rng *rand.Rand, opts simulateOptions, start time.Time) [][]model.IncomingMessage {
+	flights := make([][]model.IncomingMessage, opts.rockets)
+	for i := range flights {
+		channel := fmt.Sprintf("%08x-%04x-%04x-%04x-%012x", rng.Uint32(), rng.Intn(1<<16), rng.Intn(1<<16), rng.Intn(1<<16), rng.Int63n(1<<48))
+		at := start.Add(time.Duration(rng.Int63n(int64(opts.interval))))
+		var flight []model.IncomingMessage
+		emit := func(messageType model.MessageType, message any) {
+			data, _ := json.Marshal(message)
+			flight = append(flight, model.IncomingMessage{
+				Metadata: model.Metadata{
+					Channel:       channel,
+					MessageNumber: len(flight) + 1,
+					MessageTime:   at,
+					MessageType:   messageType,
+				},
+				Message: data,
+			})
+			at = at.Add(opts.interval)
+		}
+
+		speed := 500 + 100*rng.Intn(16)
+		emit(model.RocketLaunched, model.LaunchedMessage{
+			Type:        simulatedRocketTypes[rng.Intn(len(simulatedRocketTypes))],
+			LaunchSpeed: speed,
+			Mission:     simulatedMissions[rng.Intn(len(simulatedMissions))],
+		})
+
+		steps := opts.steps/2 + rng.Intn(opts.steps+1)
+		explodeAt := -1
+		if rng.Float64() < opts.explode {
+			explodeAt = rng.Intn(steps + 1)
+		}
+		for step := 0; step < steps; step++ {
+			if step == explodeAt {
+				emit(model.RocketExploded, model.RocketExplodedMessage{Reason: simulatedExplosions[rng.Intn(len(simulatedExplosions))]})
+				break
+			}
+			switch ramp := step < steps*2/3; {
+			case rng.Float64() < 0.05:
+				emit(model.RocketMissionChanged, model.MissionChangedMessage{NewMission: simulatedMissions[rng.Intn(len(simulatedMissions))]})
+			case ramp || rng.Intn(2) == 0 || speed < 1000:
+				by := 100 * (1 + rng.Intn(30))
+				speed += by
+				emit(model.RocketSpeedIncreased, model.SpeedChangedMessage{By: by})
+			default:
+				by := 100 * (1 + rng.Intn(10))
+				speed -= by
+				emit(model.RocketSpeedDecreased, model.SpeedChangedMessage{By: by})
+			}
+		}
+		if explodeAt == steps {
+			emit(model.RocketExploded, model.RocketExplodedMessage{Reason: simulatedExplosions[rng.Intn(len(simulatedExplosions))]})
+		}
+		flights[i] = flight
+	}
+	return flights
+}
+
+// deliveryOrder interleaves the flights by messageTime plus a random delivery
+// delay of up to jitter.
+func deliveryOrder(rng *rand.Rand, flights [][]model.IncomingMessage, jitter time.Duration) []model.IncomingMessage {
+	type delivery struct {
+		msg model.IncomingMessage
+		at  time.Time
+	}
+	var deliveries []delivery
+	for _, flight := range flights {
+		for _, msg := range flight {
+			at := msg.Metadata.MessageTime
+			if jitter > 0 {
+				at = at.Add(time.Duration(rng.Int63n(int64(jitter))))
+			}
+			deliveries = append(deliveries, delivery{msg: msg, at: at})
+		}
+	}
+	sort.SliceStable(deliveries, func(i, j int) bool { return deliveries[i].at.Before(deliveries[j].at) })
+
+	messages := make([]model.IncomingMessage, len(deliveries))
+	for i, d := range deliveries {
+		messages[i] = d.msg
+	}
+	return messages
+}
+
+// expectedFleet applies every flight in order, which is the ground truth the
+// service should converge to however the messages were delivered.
+func expectedFleet(flights [][]model.IncomingMessage) ([]model.Rocket, error) {
+	defer silenceLogs()()
+
+	rockets := make([]model.Rocket, 0, len(flights))
+	for _, flight := range flights {
+		rocket := model.NewRocket(flight[0].Metadata.Channel)
+		for _, msg := range flight {
+			if err := rocket.UpdateState(msg.Metadata.MessageType, msg.Message); err != nil {
+				return nil, err
+			}
+			rocket.MessageNumber = msg.Metadata.MessageNumber
+			rocket.MessageTime = msg.Metadata.MessageTime
+		}
+		rockets = append(rockets, rocket)
+	}
+	sort.Slice(rockets, func(i, j int) bool { return rockets[i].Channel < rockets[j].Channel })
+	return rockets, nil
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSimulateFlights tests that flights are reproducible and well formed.
+func TestSimulateFlights(t *testing.T) {
+	opts := simulateOptions{rockets: 20, steps: 10, explode: 0.5, interval: time.Second}
+	start := time.Date(2022, 2, 2, 19, 39, 5, 0, time.UTC)
+	flights := simulateFlights(rand.New(rand.NewSource(3)), opts, start)
+	assert.Equal(t, flights, simulateFlights(rand.New(rand.NewSource(3)), opts, start))
+	require.Len(t, flights, opts.rockets)
+
+	exploded := 0
+	for _, flight := range flights {
+		require.NotEmpty(t, flight)
+		assert.Equal(t, model.RocketLaunched, flight[0].Metadata.MessageType)
+		for i, msg := range flight {
+			assert.Equal(t, flight[0].Metadata.Channel, msg.Metadata.Channel)
+			assert.Equal(t, i+1, msg.Metadata.MessageNumber)
+			if i > 0 {
+				assert.Equal(t, time.Second, msg.Metadata.MessageTime.Sub(flight[i-1].Metadata.MessageTime))
+			}
+			if msg.Metadata.MessageType == model.RocketExploded {
+				assert.Equal(t, len(flight)-1, i, "nothing follows an explosion")
+				exploded++
+			}
+		}
+	}
+	assert.Positive(t, exploded)
+	assert.Less(t, exploded, opts.rockets)
+
+	expected, err := expectedFleet(flights)
+	require.NoError(t, err)
+	for _, rocket := range expected {
+		assert.GreaterOrEqual(t, rocket.Speed, 0)
+	}
+}
+
+// TestDeliveryOrder tests that jitter reorders messages without losing any.
+func TestDeliveryOrder(t *testing.T) {
+	opts := simulateOptions{rockets: 5, steps: 10, interval: time.Second}
+	flights := simulateFlights(rand.New(rand.NewSource(1)), opts, time.Now())
+	var all []model.IncomingMessage
+	for _, flight := range flights {
+		all = append(all, flight...)
+	}
+
+	ordered := deliveryOrder(rand.New(rand.NewSource(1)), flights, 0)
+	assert.ElementsMatch(t, all, ordered)
+	last := make(map[string]int)
+	for _, msg := range ordered {
+		assert.Greater(t, msg.Metadata.MessageNumber, last[msg.Metadata.Channel], "without jitter every rocket is in order")
+		last[msg.Metadata.Channel] = msg.Metadata.MessageNumber
+	}
+
+	jittered := deliveryOrder(rand.New(rand.NewSource(1)), flights, 5*time.Second)
+	assert.ElementsMatch(t, all, jittered)
+	assert.NotEqual(t, ordered, jittered)
+}
+
+// TestSimulate_Replay tests that an unperturbed simulation replayed offline
+// ends in exactly the expected fleet state.
+func TestSimulate_Replay(t *testing.T) {
+	dir := t.TempDir()
+	expectedPath := filepath.Join(dir, "expected.json")
+	var capture bytes.Buffer
+	err := runSimulate([]string{"-rockets", "8", "-seed", "11", "-expected", expectedPath}, &capture, &bytes.Buffer{})
+	require.NoError(t, err)
+
+	capturePath := filepath.Join(dir, "capture.jsonl")
+	require.NoError(t, os.WriteFile(capturePath, capture.Bytes(), 0o600))
+	var actual bytes.Buffer
+	require.NoError(t, runReplay([]string{capturePath}, &actual, &bytes.Buffer{}))
+
+	expected, err := os.ReadFile(expectedPath)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(expected), actual.String())
+
+	var rockets []model.Rocket
+	require.NoError(t, json.Unmarshal(expected, &rockets))
+	assert.Len(t, rockets, 8)
+}
+
+// TestSimulate_Target tests that the expected state goes to stdout when the
+// messages are posted, duplicates included.
+func TestSimulate_Target(t *testing.T) {
+	var posted atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posted.Add(1)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+	err := runSimulate([]string{"-target", server.URL, "-rockets", "3", "-steps", "4", "-seed", "5", "-duplicate", "1"}, &stdout, &stderr)
+	require.NoError(t, err)
+
+	var rockets []model.Rocket
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &rockets))
+	assert.Len(t, rockets, 3)
+	assert.Contains(t, stderr.String(), "with -seed 5")
+	assert.Equal(t, 0, int(posted.Load())%2, "every message is sent twice")
+	assert.GreaterOrEqual(t, int(posted.Load()), 2*3*3)
+}
+
+// TestSimulate_Errors tests that bad arguments are reported.
+func TestSimulate_Errors(t *testing.T) {
+	for _, args := range [][]string{
+		{"-rockets", "0"},
+		{"-explode", "1.5"},
+		{"-out-of-order", "-1"},
+		{"-jitter", "-1s"},
+		{"extra"},
+	} {
+		assert.Error(t, runSimulate(args, &bytes.Buffer{}, &bytes.Buffer{}), args)
+	}
+}
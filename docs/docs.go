@@ -15,15 +15,268 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/messages": {
+        "/admin/config": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Returns the configuration the server started with, after merging the YAML file, environment variables and flags. Secrets are redacted.",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Get the effective configuration",
+                "responses": {
+                    "200": {
+                        "description": "Effective configuration",
+                        "schema": {
+                            "$ref": "#/definitions/config.Config"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid admin token (UNAUTHORIZED)",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/processing": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Returns whether the workers are paused, since when, the channels paused on their own and the message queue.",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Get the processing state",
+                "responses": {
+                    "200": {
+                        "description": "Processing state",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProcessingStatus"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid admin token (UNAUTHORIZED)",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/processing/pause": {
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Stops the workers from taking messages off the queue, for one channel or for the whole fleet. POST /messages keeps accepting messages until the queue is full. Messages being processed are finished. Pausing again is a no-op.",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Pause message processing",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Pause only this channel",
+                        "name": "channel",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Processing state",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProcessingStatus"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid admin token (UNAUTHORIZED)",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/processing/resume": {
             "post": {
-                "description": "Processes an incoming rocket state message. Handles out-of-order and duplicate messages.",
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Undoes a pause for one channel or for the whole fleet. Resuming the fleet leaves channels paused on their own paused. Resuming what is not paused is a no-op.",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Resume message processing",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Resume only this channel",
+                        "name": "channel",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Processing state",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProcessingStatus"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid admin token (UNAUTHORIZED)",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/workers": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Returns the number of message processing workers, the autoscaler settings when it runs, and each worker's last heartbeat.",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Get the worker pool",
+                "responses": {
+                    "200": {
+                        "description": "Worker pool",
+                        "schema": {
+                            "$ref": "#/definitions/model.WorkerPoolStatus"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid admin token (UNAUTHORIZED)",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Starts or stops workers until the pool has the requested size, without interrupting messages being processed. When autoscaling is enabled the autoscaler keeps adjusting from the new size.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Resize the worker pool",
+                "parameters": [
+                    {
+                        "description": "New worker count",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.WorkerPoolRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Resized worker pool",
+                        "schema": {
+                            "$ref": "#/definitions/model.WorkerPoolStatus"
+                        }
+                    },
+                    "400": {
+                        "description": "Count missing or outside [1, workers.max] (INVALID_PAYLOAD)",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid admin token (UNAUTHORIZED)",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/healthz": {
+            "get": {
+                "description": "Reports whether the process is alive and no message processing worker is wedged, i.e. has gone longer than the stall timeout without a heartbeat.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Liveness probe",
+                "responses": {
+                    "200": {
+                        "description": "Alive",
+                        "schema": {
+                            "$ref": "#/definitions/model.HealthReport"
+                        }
+                    },
+                    "503": {
+                        "description": "A worker is wedged or no worker is running",
+                        "schema": {
+                            "$ref": "#/definitions/model.HealthReport"
+                        }
+                    }
+                }
+            }
+        },
+        "/messages": {
+            "post": {
+                "description": "Processes an incoming rocket state message. Handles out-of-order and duplicate messages. When signed ingestion is enabled, the body must be signed with the channel's secret in the X-Signature header (\"t=\u003cunix\u003e,v1=\u003chex HMAC-SHA256 of \"\u003ct\u003e.\u003cbody\u003e\"\u003e\").",
+                "consumes": [
                     "application/json"
                 ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
                 "tags": [
                     "messages"
                 ],
@@ -37,11 +290,29 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/model.IncomingMessage"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Body signature, required when signed ingestion is enabled",
+                        "name": "X-Signature",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Correlation ID for the message's log lines; generated when absent and echoed in the response",
+                        "name": "X-Request-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Client identity for rate limiting; the client IP is used when absent or unknown",
+                        "name": "X-API-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "Status of message processing",
+                    "202": {
+                        "description": "Message accepted for processing, with its requestId and receiptId; Location points to the receipt",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -50,119 +321,1134 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Invalid JSON or bad request",
+                        "description": "Invalid JSON or bad request, or the reserved channel \\\"stream\\\" (INVALID_PAYLOAD)",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/model.Problem"
                         }
                     },
-                    "500": {
-                        "description": "Internal server error",
+                    "401": {
+                        "description": "Missing, invalid or replayed signature (UNAUTHORIZED)",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Channel or client rate limit exceeded, see Retry-After (RATE_LIMITED)",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "Message queue still full after the enqueue wait, see Retry-After and the queue member (QUEUE_FULL)",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
                         }
                     }
                 }
             }
         },
-        "/rockets": {
+        "/messages/{id}": {
             "get": {
-                "description": "Returns a list of the current states of all rockets in the system, sorted by channel ID.",
+                "description": "Returns the lifecycle of an accepted message by the receiptId of its 202 response: queued, processing, then processed, ignored_old, duplicate or failed with the error. Receipts expire after the configured TTL, and the oldest are evicted first when the store is full.",
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "application/problem+json"
                 ],
                 "tags": [
-                    "rockets"
+                    "messages"
+                ],
+                "summary": "Get a message receipt",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Receipt ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "Get all rocket states",
                 "responses": {
                     "200": {
-                        "description": "List of all rockets",
+                        "description": "Message receipt",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/model.Rocket"
-                            }
+                            "$ref": "#/definitions/model.Receipt"
                         }
                     },
-                    "500": {
-                        "description": "Internal server error",
+                    "404": {
+                        "description": "Unknown, expired or evicted receipt (NOT_FOUND)",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/model.Problem"
                         }
                     }
                 }
             }
         },
-        "/rockets/{channel}": {
+        "/readyz": {
             "get": {
-                "description": "Returns the current state of a specific rocket by its channel ID.",
+                "description": "Reports whether the service should receive traffic: the repository is reachable, the message queue is below its saturation threshold and the server is not shutting down.",
                 "produces": [
                     "application/json"
                 ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Readiness probe",
+                "responses": {
+                    "200": {
+                        "description": "Ready",
+                        "schema": {
+                            "$ref": "#/definitions/model.HealthReport"
+                        }
+                    },
+                    "503": {
+                        "description": "Not ready",
+                        "schema": {
+                            "$ref": "#/definitions/model.HealthReport"
+                        }
+                    }
+                }
+            }
+        },
+        "/rockets": {
+            "get": {
+                "description": "Returns a list of the current states of all rockets in the system, sorted by channel ID. The representation is chosen with ?format= or the Accept header (JSON, CSV or NDJSON) and rows are streamed as they are read from the repository. ?fields= selects a sparse fieldset.",
+                "produces": [
+                    "application/json",
+                    "text/csv",
+                    "application/x-ndjson",
+                    "application/problem+json"
+                ],
                 "tags": [
                     "rockets"
                 ],
-                "summary": "Get a single rocket state",
+                "summary": "Get all rocket states",
                 "parameters": [
                     {
+                        "enum": [
+                            "json",
+                            "csv",
+                            "ndjson"
+                        ],
                         "type": "string",
-                        "description": "Rocket Channel ID",
-                        "name": "channel",
-                        "in": "path",
-                        "required": true
+                        "description": "Representation, overrides Accept",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated fields to include, e.g. channel,speed,mission",
+                        "name": "fields",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only rockets whose contact is lost (true) or not (false)",
+                        "name": "contactLost",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Current state of the rocket",
+                        "description": "List of all rockets",
                         "schema": {
-                            "$ref": "#/definitions/model.Rocket"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.Rocket"
+                            }
                         }
                     },
                     "400": {
-                        "description": "Missing rocket channel ID",
+                        "description": "Unknown format or field, or invalid contactLost (INVALID_PAYLOAD)",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/model.Problem"
                         }
                     },
-                    "404": {
-                        "description": "Rocket not found",
+                    "406": {
+                        "description": "No acceptable representation (NOT_ACCEPTABLE)",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/model.Problem"
                         }
                     },
                     "500": {
-                        "description": "Internal server error",
+                        "description": "Internal server error (INTERNAL_ERROR)",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/model.Problem"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
+        },
+        "/rockets/stream": {
+            "get": {
+                "description": "Pushes a Server-Sent Event (\"rocket_updated\") with the new rocket state and the message that caused it every time a rocket changes, and \"contact_lost\" / \"contact_restored\" events when a rocket goes silent and is heard from again. When the client falls behind, or resumes (Last-Event-ID) from an event that is no longer retained, a \"resync\" event is sent and the client should re-read GET /rockets.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "rockets"
+                ],
+                "summary": "Stream rocket state changes",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Resume after this event ID",
+                        "name": "Last-Event-ID",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Stream of rocket events",
+                        "schema": {
+                            "$ref": "#/definitions/model.RocketEvent"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid Last-Event-ID (INVALID_PAYLOAD)",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/rockets/{channel}": {
+            "get": {
+                "description": "Returns the current state of a specific rocket by its channel ID, in the representation chosen with ?format= or the Accept header (JSON, CSV or NDJSON), optionally restricted to ?fields=.",
+                "produces": [
+                    "application/json",
+                    "text/csv",
+                    "application/x-ndjson",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "rockets"
+                ],
+                "summary": "Get a single rocket state",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Rocket Channel ID",
+                        "name": "channel",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "json",
+                            "csv",
+                            "ndjson"
+                        ],
+                        "type": "string",
+                        "description": "Representation, overrides Accept",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated fields to include, e.g. channel,speed,mission",
+                        "name": "fields",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Current state of the rocket",
+                        "schema": {
+                            "$ref": "#/definitions/model.Rocket"
+                        }
+                    },
+                    "400": {
+                        "description": "Unknown format or field (INVALID_PAYLOAD)",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Rocket not found (NOT_FOUND)",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "No acceptable representation (NOT_ACCEPTABLE)",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error (INTERNAL_ERROR)",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/rockets/{channel}/stream": {
+            "get": {
+                "description": "Same as /rockets/stream, restricted to the rocket on the given channel.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "rockets"
+                ],
+                "summary": "Stream state changes of a single rocket",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Rocket Channel ID",
+                        "name": "channel",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Resume after this event ID",
+                        "name": "Last-Event-ID",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Stream of rocket events",
+                        "schema": {
+                            "$ref": "#/definitions/model.RocketEvent"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid Last-Event-ID (INVALID_PAYLOAD)",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/stats": {
+            "get": {
+                "description": "Returns aggregate statistics of the fleet (rockets by status, type and mission, speeds, explosion reasons) and message ingestion counters.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stats"
+                ],
+                "summary": "Get fleet statistics",
+                "responses": {
+                    "200": {
+                        "description": "Fleet-wide statistics",
+                        "schema": {
+                            "$ref": "#/definitions/model.FleetStats"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Returns every registered webhook (without secrets), oldest first.",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "List webhooks",
+                "responses": {
+                    "200": {
+                        "description": "Registered webhooks",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.Webhook"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid admin token (UNAUTHORIZED)",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Registers an endpoint that is called on matching rocket state changes. Events: launched, speed_changed, exploded, mission_changed, contact_lost, contact_restored or \"*\". Optional channels restrict deliveries to those rockets. Payloads are signed with HMAC-SHA256 in the X-Signature header (\"t=\u003cunix\u003e,v1=\u003chex\u003e\" over \"\u003ct\u003e.\u003cbody\u003e\"); the secret is generated when omitted and only returned by this call.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Register a webhook",
+                "parameters": [
+                    {
+                        "description": "Webhook registration",
+                        "name": "webhook",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.WebhookRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Registered webhook, including its secret",
+                        "schema": {
+                            "$ref": "#/definitions/model.Webhook"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid registration, or a loopback, link-local or private target that is not allowed (INVALID_PAYLOAD)",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid admin token (UNAUTHORIZED)",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "URL already registered (CONFLICT)",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Get a webhook",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Webhook",
+                        "schema": {
+                            "$ref": "#/definitions/model.Webhook"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid admin token (UNAUTHORIZED)",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Webhook not found (NOT_FOUND)",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "produces": [
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Delete a webhook",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Webhook deleted"
+                    },
+                    "401": {
+                        "description": "Missing or invalid admin token (UNAUTHORIZED)",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Webhook not found (NOT_FOUND)",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}/deliveries": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Returns the most recent delivery attempts, newest first.",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Get the delivery log of a webhook",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Delivery attempts",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.WebhookDelivery"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid admin token (UNAUTHORIZED)",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Webhook not found (NOT_FOUND)",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}/enable": {
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Re-enables a webhook that was disabled automatically after repeated failed deliveries.",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Re-enable a webhook",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Webhook",
+                        "schema": {
+                            "$ref": "#/definitions/model.Webhook"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid admin token (UNAUTHORIZED)",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Webhook not found (NOT_FOUND)",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/ws": {
+            "get": {
+                "description": "Upgrades to a WebSocket. Clients send {\"type\":\"subscribe\"|\"unsubscribe\",\"channels\":[],\"missions\":[],\"types\":[]} (\"*\" selects everything) and {\"type\":\"ping\"}. After each subscription change the server sends a \"snapshot\" of the matching rockets, followed by an \"update\" for every matching state change, including the one after which a rocket no longer matches. A new snapshot is also sent if the client falls behind.",
+                "tags": [
+                    "rockets"
+                ],
+                "summary": "Subscribe to rocket updates over WebSocket",
+                "parameters": [
+                    {
+                        "description": "Client message (sent over the socket)",
+                        "name": "message",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/controller.WSClientMessage"
+                        }
+                    }
+                ],
+                "responses": {
+                    "101": {
+                        "description": "Server message (sent over the socket)",
+                        "schema": {
+                            "$ref": "#/definitions/controller.WSServerMessage"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "config.AdminConfig": {
+            "type": "object",
+            "properties": {
+                "token": {
+                    "description": "Token protects the /admin and /webhooks endpoints as a bearer token.\nWithout it they are disabled.",
+                    "type": "string"
+                }
+            }
+        },
+        "config.AutoscaleConfig": {
+            "type": "object",
+            "properties": {
+                "enabled": {
+                    "type": "boolean"
+                },
+                "highWater": {
+                    "description": "HighWater is the queue depth above which the pool grows.",
+                    "type": "integer"
+                },
+                "interval": {
+                    "type": "string"
+                },
+                "min": {
+                    "type": "integer"
+                },
+                "scaleDownAfter": {
+                    "type": "string"
+                },
+                "scaleUpAfter": {
+                    "type": "string"
+                }
+            }
+        },
+        "config.Config": {
+            "type": "object",
+            "properties": {
+                "admin": {
+                    "$ref": "#/definitions/config.AdminConfig"
+                },
+                "contact": {
+                    "$ref": "#/definitions/config.ContactConfig"
+                },
+                "limits": {
+                    "$ref": "#/definitions/config.LimitsConfig"
+                },
+                "logging": {
+                    "$ref": "#/definitions/config.LoggingConfig"
+                },
+                "queue": {
+                    "$ref": "#/definitions/config.QueueConfig"
+                },
+                "radio": {
+                    "$ref": "#/definitions/config.RadioConfig"
+                },
+                "receipts": {
+                    "$ref": "#/definitions/config.ReceiptsConfig"
+                },
+                "repository": {
+                    "$ref": "#/definitions/config.RepositoryConfig"
+                },
+                "server": {
+                    "$ref": "#/definitions/config.ServerConfig"
+                },
+                "signing": {
+                    "$ref": "#/definitions/config.SigningConfig"
+                },
+                "webhooks": {
+                    "$ref": "#/definitions/config.WebhooksConfig"
+                },
+                "workers": {
+                    "$ref": "#/definitions/config.WorkersConfig"
+                }
+            }
+        },
+        "config.ContactConfig": {
+            "type": "object",
+            "properties": {
+                "checkInterval": {
+                    "type": "string"
+                },
+                "lostAfter": {
+                    "description": "LostAfter is how long a rocket can stay silent before it is flagged\ncontactLost. Zero disables the check.",
+                    "type": "string"
+                }
+            }
+        },
+        "config.LimitsConfig": {
+            "type": "object",
+            "properties": {
+                "apiKeys": {
+                    "description": "APIKeys maps client names to the API keys they send in X-API-Key. Only\nthese keys get a client bucket of their own; others are limited by IP.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "channel": {
+                    "type": "string"
+                },
+                "channelOverrides": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "client": {
+                    "type": "string"
+                }
+            }
+        },
+        "config.LoggingConfig": {
+            "type": "object",
+            "properties": {
+                "format": {
+                    "description": "Format is \"text\" or \"json\".",
+                    "type": "string"
+                },
+                "level": {
+                    "type": "string"
+                },
+                "requests": {
+                    "description": "Requests enables the access log of every HTTP request.",
+                    "type": "boolean"
+                }
+            }
+        },
+        "config.QueueConfig": {
+            "type": "object",
+            "properties": {
+                "backend": {
+                    "description": "Backend is \"memory\", or \"disk\" to keep accepted messages across\nrestarts until they are processed.",
+                    "type": "string"
+                },
+                "dir": {
+                    "type": "string"
+                },
+                "enqueueWait": {
+                    "description": "EnqueueWait is how long POST /messages waits for room in a full queue\nbefore answering 503. Zero answers at once.",
+                    "type": "string"
+                },
+                "laneWeights": {
+                    "description": "LaneWeights has one weight per priority lane, from lane 0 up. Busy\nlanes share the workers in proportion to their weights.",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "maxAttempts": {
+                    "description": "MaxAttempts is how often the disk queue delivers a message that fails\nbefore moving it to the dead-letter file, RetryBackoff apart and\ndoubling.",
+                    "type": "integer"
+                },
+                "priorities": {
+                    "description": "Priorities maps message types to lanes; other types use lane 0. The\nYAML map is merged into the defaults, the environment replaces them.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "readyThreshold": {
+                    "description": "ReadyThreshold is the fraction of Size at which /readyz starts failing.",
+                    "type": "number"
+                },
+                "retryBackoff": {
+                    "type": "string"
+                },
+                "segmentSize": {
+                    "description": "SegmentSize is the size in bytes of the disk queue's log files.",
+                    "type": "integer"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "sync": {
+                    "description": "Sync makes the disk queue fsync every message before accepting it.",
+                    "type": "boolean"
+                }
+            }
+        },
+        "config.RadioConfig": {
+            "type": "object",
+            "properties": {
+                "tcpAddr": {
+                    "type": "string"
+                },
+                "udpAddr": {
+                    "type": "string"
+                },
+                "unsigned": {
+                    "description": "Unsigned allows the listeners, which do not verify signatures, to run\nwhile signed ingestion is enabled.",
+                    "type": "boolean"
+                }
+            }
+        },
+        "config.ReceiptsConfig": {
+            "type": "object",
+            "properties": {
+                "capacity": {
+                    "description": "Capacity is how many receipts are kept; the oldest are evicted first.",
+                    "type": "integer"
+                },
+                "ttl": {
+                    "type": "string"
+                }
+            }
+        },
+        "config.RepositoryConfig": {
+            "type": "object",
+            "properties": {
+                "backend": {
+                    "type": "string"
+                }
+            }
+        },
+        "config.ServerConfig": {
+            "type": "object",
+            "properties": {
+                "addr": {
+                    "description": "Addr is the HTTP listen address, e.g. \":8088\".",
+                    "type": "string"
+                },
+                "allowedOrigins": {
+                    "description": "AllowedOrigins are the browser origins, besides the API's own, that may\nopen /ws. \"*\" allows any origin.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "readHeaderTimeout": {
+                    "type": "string"
+                },
+                "shutdownDelay": {
+                    "description": "ShutdownDelay is how long /readyz fails before the listener closes.",
+                    "type": "string"
+                },
+                "shutdownTimeout": {
+                    "type": "string"
+                },
+                "trustedProxies": {
+                    "description": "TrustedProxies are the addresses or CIDR networks whose\nX-Forwarded-For header is believed for the client IP. None by default.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "config.SigningConfig": {
+            "type": "object",
+            "properties": {
+                "keyfile": {
+                    "description": "Keyfile enables signed ingestion when set.",
+                    "type": "string"
+                },
+                "reload": {
+                    "type": "string"
+                },
+                "tolerance": {
+                    "type": "string"
+                }
+            }
+        },
+        "config.WebhooksConfig": {
+            "type": "object",
+            "properties": {
+                "allowedHosts": {
+                    "description": "AllowedHosts are host names, IP addresses and CIDR networks webhooks\nmay target although they are loopback, link-local or private.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "disableAfter": {
+                    "type": "integer"
+                },
+                "initialBackoff": {
+                    "type": "string"
+                },
+                "maxAttempts": {
+                    "type": "integer"
+                },
+                "maxBackoff": {
+                    "type": "string"
+                },
+                "queueSize": {
+                    "type": "integer"
+                },
+                "timeout": {
+                    "type": "string"
+                }
+            }
+        },
+        "config.WorkersConfig": {
+            "type": "object",
+            "properties": {
+                "autoscale": {
+                    "$ref": "#/definitions/config.AutoscaleConfig"
+                },
+                "count": {
+                    "type": "integer"
+                },
+                "max": {
+                    "description": "Max bounds runtime resizing, manual or automatic.",
+                    "type": "integer"
+                },
+                "stallTimeout": {
+                    "type": "string"
+                }
+            }
+        },
+        "controller.WSClientMessage": {
+            "type": "object",
+            "properties": {
+                "channels": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "missions": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "type": {
+                    "type": "string"
+                },
+                "types": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "controller.WSServerMessage": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "event": {
+                    "$ref": "#/definitions/model.RocketEvent"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "lastEventId": {
+                    "type": "integer"
+                },
+                "rockets": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Rocket"
+                    }
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.AutoscaleStatus": {
+            "type": "object",
+            "properties": {
+                "highWater": {
+                    "type": "integer"
+                },
+                "max": {
+                    "type": "integer"
+                },
+                "min": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.ChannelPause": {
+            "type": "object",
+            "properties": {
+                "channel": {
+                    "type": "string",
+                    "example": "193270a9-c9cf-404a-8f83-838e71d9ae67"
+                },
+                "pausedSince": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.EventKind": {
+            "type": "string",
+            "enum": [
+                "rocket_updated",
+                "contact_lost",
+                "contact_restored",
+                "resync"
+            ],
+            "x-enum-varnames": [
+                "EventRocketUpdated",
+                "EventContactLost",
+                "EventContactRestored",
+                "EventResync"
+            ]
+        },
+        "model.FleetStats": {
+            "type": "object",
+            "properties": {
+                "averageSpeed": {
+                    "type": "number"
+                },
+                "byMission": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "byStatus": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "byType": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "explosionReasons": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "ingestion": {
+                    "$ref": "#/definitions/model.IngestionStats"
+                },
+                "maxSpeed": {
+                    "type": "integer"
+                },
+                "totalRockets": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.HealthCheck": {
+            "type": "object",
+            "properties": {
+                "detail": {
+                    "type": "string",
+                    "example": "12/1000 messages queued (1.2%)"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "ok"
+                }
+            }
+        },
+        "model.HealthReport": {
+            "type": "object",
+            "properties": {
+                "checks": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/model.HealthCheck"
+                    }
+                },
+                "status": {
+                    "type": "string",
+                    "example": "ok"
+                },
+                "workers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.WorkerHealth"
+                    }
+                }
+            }
+        },
         "model.IncomingMessage": {
             "type": "object"
         },
+        "model.IngestionStats": {
+            "type": "object",
+            "properties": {
+                "duplicates": {
+                    "type": "integer"
+                },
+                "errors": {
+                    "type": "integer"
+                },
+                "ignoredOld": {
+                    "type": "integer"
+                },
+                "processed": {
+                    "type": "integer"
+                }
+            }
+        },
         "model.MessageType": {
             "type": "string",
             "enum": [
@@ -203,12 +1489,132 @@ const docTemplate = `{
                 }
             }
         },
+        "model.Problem": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "detail": {
+                    "type": "string"
+                },
+                "instance": {
+                    "type": "string"
+                },
+                "queue": {
+                    "description": "Queue is set on QUEUE_FULL problems so that producers can back off.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.QueueStatus"
+                        }
+                    ]
+                },
+                "status": {
+                    "type": "integer"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.ProcessingStatus": {
+            "type": "object",
+            "properties": {
+                "pausedChannels": {
+                    "description": "PausedChannels lists the channels paused on their own.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.ChannelPause"
+                    }
+                },
+                "pausedSince": {
+                    "description": "PausedSince is set while processing is paused as a whole.",
+                    "type": "string"
+                },
+                "queue": {
+                    "$ref": "#/definitions/model.QueueStatus"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "paused"
+                }
+            }
+        },
+        "model.QueueStatus": {
+            "type": "object",
+            "properties": {
+                "capacity": {
+                    "type": "integer"
+                },
+                "depth": {
+                    "type": "integer"
+                },
+                "drainRate": {
+                    "description": "DrainRate is how many messages per second the workers recently took\noff the queue.",
+                    "type": "number"
+                }
+            }
+        },
+        "model.Receipt": {
+            "type": "object",
+            "properties": {
+                "channel": {
+                    "type": "string",
+                    "example": "193270a9-c9cf-404a-8f83-838e71d9ae67"
+                },
+                "completedAt": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "9f86d081884c7d659a2feaa0c55ad015"
+                },
+                "messageNumber": {
+                    "type": "integer",
+                    "example": 7
+                },
+                "processingAt": {
+                    "type": "string"
+                },
+                "queuedAt": {
+                    "type": "string"
+                },
+                "requestId": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "queued",
+                        "processing",
+                        "processed",
+                        "ignored_old",
+                        "duplicate",
+                        "failed"
+                    ],
+                    "example": "processed"
+                }
+            }
+        },
         "model.Rocket": {
             "type": "object",
             "properties": {
                 "channel": {
                     "type": "string"
                 },
+                "contactLost": {
+                    "description": "ContactLost is set once the rocket has been silent for longer than the\nconfigured threshold, from ContactLostSince, and cleared by its next\nmessage.",
+                    "type": "boolean"
+                },
+                "contactLostSince": {
+                    "type": "string"
+                },
                 "exploded": {
                     "type": "boolean"
                 },
@@ -225,6 +1631,187 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "model.RocketEvent": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "integer"
+                },
+                "kind": {
+                    "$ref": "#/definitions/model.EventKind"
+                },
+                "message": {
+                    "$ref": "#/definitions/model.IncomingMessage"
+                },
+                "rocket": {
+                    "$ref": "#/definitions/model.Rocket"
+                },
+                "time": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.Webhook": {
+            "type": "object",
+            "properties": {
+                "channels": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "consecutiveFailures": {
+                    "type": "integer"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "disabledAt": {
+                    "type": "string"
+                },
+                "enabled": {
+                    "type": "boolean"
+                },
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "secret": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.WebhookDelivery": {
+            "type": "object",
+            "properties": {
+                "attempt": {
+                    "type": "integer"
+                },
+                "durationMs": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "event": {
+                    "type": "string"
+                },
+                "eventId": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "statusCode": {
+                    "type": "integer"
+                },
+                "success": {
+                    "type": "boolean"
+                },
+                "time": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.WebhookRequest": {
+            "type": "object",
+            "required": [
+                "events",
+                "url"
+            ],
+            "properties": {
+                "channels": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "secret": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.WorkerHealth": {
+            "type": "object",
+            "properties": {
+                "busySince": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "lastHeartbeat": {
+                    "type": "string"
+                },
+                "stalled": {
+                    "type": "boolean"
+                },
+                "state": {
+                    "type": "string",
+                    "example": "idle"
+                }
+            }
+        },
+        "model.WorkerPoolRequest": {
+            "type": "object",
+            "required": [
+                "count"
+            ],
+            "properties": {
+                "count": {
+                    "type": "integer",
+                    "minimum": 1,
+                    "example": 8
+                }
+            }
+        },
+        "model.WorkerPoolStatus": {
+            "type": "object",
+            "properties": {
+                "autoscale": {
+                    "$ref": "#/definitions/model.AutoscaleStatus"
+                },
+                "count": {
+                    "type": "integer",
+                    "example": 5
+                },
+                "max": {
+                    "type": "integer",
+                    "example": 64
+                },
+                "workers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.WorkerHealth"
+                    }
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "AdminToken": {
+            "description": "\"Bearer \u003ctoken\u003e\" configured with ADMIN_TOKEN; required by /admin and /webhooks endpoints, which are disabled without it.",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
@@ -0,0 +1,354 @@
+// Package config loads the service configuration from a YAML file,
+// environment variables and command-line flags, in increasing order of
+// precedence.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/seansa/rocket-challenge/internal/logging"
+	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/seansa/rocket-challenge/internal/ratelimit"
+	"gopkg.in/yaml.v3"
+)
+
+// Repository backends.
+const (
+	BackendMemory = "memory"
+)
+
+// Message queue backends.
+const (
+	QueueBackendMemory = "memory"
+	QueueBackendDisk   = "disk"
+)
+
+// redacted replaces secrets in Config.Redacted.
+const redacted = "[REDACTED]"
+
+// Duration is a time.Duration written as a Go duration string ("5s", "1m30s")
+// in YAML, JSON, environment variables and flags.
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	duration, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+type Config struct {
+	Server     ServerConfig     `yaml:"server" json:"server"`
+	Queue      QueueConfig      `yaml:"queue" json:"queue"`
+	Workers    WorkersConfig    `yaml:"workers" json:"workers"`
+	Receipts   ReceiptsConfig   `yaml:"receipts" json:"receipts"`
+	Contact    ContactConfig    `yaml:"contact" json:"contact"`
+	Repository RepositoryConfig `yaml:"repository" json:"repository"`
+	Limits     LimitsConfig     `yaml:"limits" json:"limits"`
+	Signing    SigningConfig    `yaml:"signing" json:"signing"`
+	Radio      RadioConfig      `yaml:"radio" json:"radio"`
+	Webhooks   WebhooksConfig   `yaml:"webhooks" json:"webhooks"`
+	Logging    LoggingConfig    `yaml:"logging" json:"logging"`
+	Admin      AdminConfig      `yaml:"admin" json:"admin"`
+}
+
+type ServerConfig struct {
+	// Addr is the HTTP listen address, e.g. ":8088".
+	Addr              string   `yaml:"addr" json:"addr"`
+	ReadHeaderTimeout Duration `yaml:"readHeaderTimeout" json:"readHeaderTimeout" swaggertype:"string"`
+	// ShutdownDelay is how long /readyz fails before the listener closes.
+	ShutdownDelay   Duration `yaml:"shutdownDelay" json:"shutdownDelay" swaggertype:"string"`
+	ShutdownTimeout Duration `yaml:"shutdownTimeout" json:"shutdownTimeout" swaggertype:"string"`
+	// AllowedOrigins are the browser origins, besides the API's own, that may
+	// open /ws. "*" allows any origin.
+	AllowedOrigins []string `yaml:"allowedOrigins" json:"allowedOrigins"`
+	// TrustedProxies are the addresses or CIDR networks whose
+	// X-Forwarded-For header is believed for the client IP. None by default.
+	TrustedProxies []string `yaml:"trustedProxies" json:"trustedProxies"`
+}
+
+type QueueConfig struct {
+	Size int `yaml:"size" json:"size"`
+	// ReadyThreshold is the fraction of Size at which /readyz starts failing.
+	ReadyThreshold float64 `yaml:"readyThreshold" json:"readyThreshold"`
+	// EnqueueWait is how long POST /messages waits for room in a full queue
+	// before answering 503. Zero answers at once.
+	EnqueueWait Duration `yaml:"enqueueWait" json:"enqueueWait" swaggertype:"string"`
+	// Backend is "memory", or "disk" to keep accepted messages across
+	// restarts until they are processed.
+	Backend string `yaml:"backend" json:"backend"`
+	Dir     string `yaml:"dir" json:"dir"`
+	// SegmentSize is the size in bytes of the disk queue's log files.
+	SegmentSize int `yaml:"segmentSize" json:"segmentSize"`
+	// Sync makes the disk queue fsync every message before accepting it.
+	Sync bool `yaml:"sync" json:"sync"`
+	// MaxAttempts is how often the disk queue delivers a message that fails
+	// before moving it to the dead-letter file, RetryBackoff apart and
+	// doubling.
+	MaxAttempts  int      `yaml:"maxAttempts" json:"maxAttempts"`
+	RetryBackoff Duration `yaml:"retryBackoff" json:"retryBackoff" swaggertype:"string"`
+	// LaneWeights has one weight per priority lane, from lane 0 up. Busy
+	// lanes share the workers in proportion to their weights.
+	LaneWeights []int `yaml:"laneWeights" json:"laneWeights"`
+	// Priorities maps message types to lanes; other types use lane 0. The
+	// YAML map is merged into the defaults, the environment replaces them.
+	Priorities map[string]int `yaml:"priorities" json:"priorities"`
+}
+
+type WorkersConfig struct {
+	Count        int      `yaml:"count" json:"count"`
+	StallTimeout Duration `yaml:"stallTimeout" json:"stallTimeout" swaggertype:"string"`
+	// Max bounds runtime resizing, manual or automatic.
+	Max       int             `yaml:"max" json:"max"`
+	Autoscale AutoscaleConfig `yaml:"autoscale" json:"autoscale"`
+}
+
+type AutoscaleConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	Min     int  `yaml:"min" json:"min"`
+	// HighWater is the queue depth above which the pool grows.
+	HighWater      int      `yaml:"highWater" json:"highWater"`
+	Interval       Duration `yaml:"interval" json:"interval" swaggertype:"string"`
+	ScaleUpAfter   Duration `yaml:"scaleUpAfter" json:"scaleUpAfter" swaggertype:"string"`
+	ScaleDownAfter Duration `yaml:"scaleDownAfter" json:"scaleDownAfter" swaggertype:"string"`
+}
+
+type ReceiptsConfig struct {
+	// Capacity is how many receipts are kept; the oldest are evicted first.
+	Capacity int      `yaml:"capacity" json:"capacity"`
+	TTL      Duration `yaml:"ttl" json:"ttl" swaggertype:"string"`
+}
+
+type ContactConfig struct {
+	// LostAfter is how long a rocket can stay silent before it is flagged
+	// contactLost. Zero disables the check.
+	LostAfter     Duration `yaml:"lostAfter" json:"lostAfter" swaggertype:"string"`
+	CheckInterval Duration `yaml:"checkInterval" json:"checkInterval" swaggertype:"string"`
+}
+
+type RepositoryConfig struct {
+	Backend string `yaml:"backend" json:"backend"`
+}
+
+type LimitsConfig struct {
+	Channel          ratelimit.Limit            `yaml:"channel" json:"channel" swaggertype:"string"`
+	ChannelOverrides map[string]ratelimit.Limit `yaml:"channelOverrides" json:"channelOverrides" swaggertype:"object,string"`
+	Client           ratelimit.Limit            `yaml:"client" json:"client" swaggertype:"string"`
+	// APIKeys maps client names to the API keys they send in X-API-Key. Only
+	// these keys get a client bucket of their own; others are limited by IP.
+	APIKeys map[string]string `yaml:"apiKeys" json:"apiKeys"`
+}
+
+type SigningConfig struct {
+	// Keyfile enables signed ingestion when set.
+	Keyfile   string   `yaml:"keyfile" json:"keyfile"`
+	Reload    Duration `yaml:"reload" json:"reload" swaggertype:"string"`
+	Tolerance Duration `yaml:"tolerance" json:"tolerance" swaggertype:"string"`
+}
+
+type RadioConfig struct {
+	TCPAddr string `yaml:"tcpAddr" json:"tcpAddr"`
+	UDPAddr string `yaml:"udpAddr" json:"udpAddr"`
+	// Unsigned allows the listeners, which do not verify signatures, to run
+	// while signed ingestion is enabled.
+	Unsigned bool `yaml:"unsigned" json:"unsigned"`
+}
+
+type WebhooksConfig struct {
+	MaxAttempts    int      `yaml:"maxAttempts" json:"maxAttempts"`
+	InitialBackoff Duration `yaml:"initialBackoff" json:"initialBackoff" swaggertype:"string"`
+	MaxBackoff     Duration `yaml:"maxBackoff" json:"maxBackoff" swaggertype:"string"`
+	DisableAfter   int      `yaml:"disableAfter" json:"disableAfter"`
+	QueueSize      int      `yaml:"queueSize" json:"queueSize"`
+	Timeout        Duration `yaml:"timeout" json:"timeout" swaggertype:"string"`
+	// AllowedHosts are host names, IP addresses and CIDR networks webhooks
+	// may target although they are loopback, link-local or private.
+	AllowedHosts []string `yaml:"allowedHosts" json:"allowedHosts"`
+}
+
+type LoggingConfig struct {
+	// Requests enables the access log of every HTTP request.
+	Requests bool   `yaml:"requests" json:"requests"`
+	Level    string `yaml:"level" json:"level"`
+	// Format is "text" or "json".
+	Format string `yaml:"format" json:"format"`
+}
+
+type AdminConfig struct {
+	// Token protects the /admin and /webhooks endpoints as a bearer token.
+	// Without it they are disabled.
+	Token string `yaml:"token" json:"token"`
+}
+
+// Default returns the configuration used when nothing is overridden.
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Addr:              ":8088",
+			ReadHeaderTimeout: Duration(10 * time.Second),
+			ShutdownDelay:     Duration(5 * time.Second),
+			ShutdownTimeout:   Duration(10 * time.Second),
+		},
+		Queue: QueueConfig{
+			Size:           1000,
+			ReadyThreshold: 0.9,
+			EnqueueWait:    Duration(200 * time.Millisecond),
+			Backend:        QueueBackendMemory,
+			Dir:            "data/queue",
+			SegmentSize:    16 * 1024 * 1024,
+			MaxAttempts:    5,
+			RetryBackoff:   Duration(time.Second),
+			LaneWeights:    []int{1, 4, 16},
+			Priorities: map[string]int{
+				string(model.RocketLaunched):       2,
+				string(model.RocketExploded):       2,
+				string(model.RocketMissionChanged): 1,
+			},
+		},
+		Workers: WorkersConfig{
+			Count:        5,
+			StallTimeout: Duration(30 * time.Second),
+			Max:          64,
+			Autoscale: AutoscaleConfig{
+				Min:            1,
+				HighWater:      500,
+				Interval:       Duration(time.Second),
+				ScaleUpAfter:   Duration(5 * time.Second),
+				ScaleDownAfter: Duration(time.Minute),
+			},
+		},
+		Receipts:   ReceiptsConfig{Capacity: 100_000, TTL: Duration(time.Hour)},
+		Contact:    ContactConfig{LostAfter: Duration(5 * time.Minute), CheckInterval: Duration(10 * time.Second)},
+		Repository: RepositoryConfig{Backend: BackendMemory},
+		Limits: LimitsConfig{
+			Channel: ratelimit.Limit{Rate: 20, Burst: 50},
+			Client:  ratelimit.Limit{Rate: 100, Burst: 200},
+		},
+		Signing: SigningConfig{
+			Reload:    Duration(5 * time.Second),
+			Tolerance: Duration(5 * time.Minute),
+		},
+		Webhooks: WebhooksConfig{
+			MaxAttempts:    5,
+			InitialBackoff: Duration(500 * time.Millisecond),
+			MaxBackoff:     Duration(30 * time.Second),
+			DisableAfter:   10,
+			QueueSize:      256,
+			Timeout:        Duration(5 * time.Second),
+		},
+		Logging: LoggingConfig{Requests: true, Level: "info", Format: logging.FormatText},
+	}
+}
+
+// loadFile merges the YAML file at path over c. Unknown keys are errors, so
+// that typos do not silently fall back to defaults.
+func (c *Config) loadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Addr != "", "server.addr must not be empty")
+	check(c.Server.ShutdownDelay >= 0, "server.shutdownDelay must not be negative")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdownTimeout must be positive")
+	check(c.Queue.Size > 0, "queue.size must be positive, got %d", c.Queue.Size)
+	check(c.Queue.ReadyThreshold > 0 && c.Queue.ReadyThreshold <= 1, "queue.readyThreshold must be in (0, 1], got %g", c.Queue.ReadyThreshold)
+	check(c.Queue.EnqueueWait >= 0, "queue.enqueueWait must not be negative")
+	check(c.Queue.Backend == QueueBackendMemory || c.Queue.Backend == QueueBackendDisk, "queue.backend must be %q or %q, got %q", QueueBackendMemory, QueueBackendDisk, c.Queue.Backend)
+	if c.Queue.Backend == QueueBackendDisk {
+		check(c.Queue.Dir != "", "queue.dir must not be empty with the disk backend")
+		check(c.Queue.SegmentSize > 0, "queue.segmentSize must be positive, got %d", c.Queue.SegmentSize)
+		check(c.Queue.MaxAttempts > 0, "queue.maxAttempts must be positive, got %d", c.Queue.MaxAttempts)
+		check(c.Queue.RetryBackoff > 0, "queue.retryBackoff must be positive")
+	}
+	check(len(c.Queue.LaneWeights) > 0, "queue.laneWeights must list at least one lane")
+	for lane, weight := range c.Queue.LaneWeights {
+		check(weight > 0, "queue.laneWeights[%d] must be positive, got %d", lane, weight)
+	}
+	for messageType, lane := range c.Queue.Priorities {
+		check(slices.Contains(model.MessageTypes, model.MessageType(messageType)), "queue.priorities: unknown message type %q", messageType)
+		check(lane >= 0 && lane < len(c.Queue.LaneWeights), "queue.priorities[%s] must be a lane in [0, %d), got %d", messageType, len(c.Queue.LaneWeights), lane)
+	}
+	check(c.Workers.Count > 0, "workers.count must be positive, got %d", c.Workers.Count)
+	check(c.Workers.StallTimeout > 0, "workers.stallTimeout must be positive")
+	check(c.Workers.Max >= c.Workers.Count, "workers.max (%d) must be at least workers.count (%d)", c.Workers.Max, c.Workers.Count)
+	if autoscale := c.Workers.Autoscale; autoscale.Enabled {
+		check(autoscale.Min > 0 && autoscale.Min <= c.Workers.Max, "workers.autoscale.min must be in [1, workers.max], got %d", autoscale.Min)
+		check(autoscale.HighWater >= 0 && autoscale.HighWater < c.Queue.Size, "workers.autoscale.highWater must be in [0, queue.size), got %d", autoscale.HighWater)
+		check(autoscale.Interval > 0 && autoscale.ScaleUpAfter > 0 && autoscale.ScaleDownAfter > 0, "workers.autoscale interval, scaleUpAfter and scaleDownAfter must be positive")
+	}
+	check(c.Receipts.Capacity > 0, "receipts.capacity must be positive, got %d", c.Receipts.Capacity)
+	check(c.Receipts.TTL > 0, "receipts.ttl must be positive")
+	check(c.Contact.LostAfter >= 0, "contact.lostAfter must not be negative")
+	check(c.Contact.CheckInterval > 0, "contact.checkInterval must be positive")
+	check(c.Repository.Backend == BackendMemory, "repository.backend %q is not supported (use %q)", c.Repository.Backend, BackendMemory)
+	for name, key := range c.Limits.APIKeys {
+		check(name != "" && key != "", "limits.apiKeys: names and keys must not be empty")
+	}
+	check(c.Signing.Reload > 0, "signing.reload must be positive")
+	check(c.Signing.Tolerance > 0, "signing.tolerance must be positive")
+	radio := c.Radio.TCPAddr != "" || c.Radio.UDPAddr != ""
+	check(!radio || c.Signing.Keyfile == "" || c.Radio.Unsigned, "radio listeners do not verify signatures: set radio.unsigned to run them with signing.keyfile")
+	check(c.Webhooks.MaxAttempts > 0, "webhooks.maxAttempts must be positive")
+	check(c.Webhooks.InitialBackoff > 0 && c.Webhooks.MaxBackoff >= c.Webhooks.InitialBackoff, "webhooks backoff must satisfy 0 < initialBackoff <= maxBackoff")
+	check(c.Webhooks.DisableAfter > 0, "webhooks.disableAfter must be positive")
+	check(c.Webhooks.QueueSize > 0, "webhooks.queueSize must be positive")
+	check(c.Webhooks.Timeout > 0, "webhooks.timeout must be positive")
+	if _, err := logging.ParseLevel(c.Logging.Level); err != nil {
+		errs = append(errs, fmt.Errorf("logging.level: %w", err))
+	}
+	check(c.Logging.Format == logging.FormatText || c.Logging.Format == logging.FormatJSON, "logging.format must be %q or %q, got %q", logging.FormatText, logging.FormatJSON, c.Logging.Format)
+
+	return errors.Join(errs...)
+}
+
+// Redacted returns a copy of c that is safe to expose, with secrets masked.
+func (c Config) Redacted() Config {
+	if c.Admin.Token != "" {
+		c.Admin.Token = redacted
+	}
+	if c.Limits.APIKeys != nil {
+		keys := make(map[string]string, len(c.Limits.APIKeys))
+		for name := range c.Limits.APIKeys {
+			keys[name] = redacted
+		}
+		c.Limits.APIKeys = keys
+	}
+	return c
+}
+
+// String renders the redacted configuration as JSON for logging.
+func (c Config) String() string {
+	data, _ := json.Marshal(c.Redacted())
+	return string(data)
+}
@@ -0,0 +1,205 @@
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/seansa/rocket-challenge/internal/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func env(values map[string]string) func(string) string {
+	return func(key string) string { return values[key] }
+}
+
+// TestLoad_Defaults tests that the defaults are valid and used when nothing is set.
+func TestLoad_Defaults(t *testing.T) {
+	cfg, err := Load("test", nil, env(nil), io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, Default(), cfg)
+	assert.Equal(t, 1000, cfg.Queue.Size)
+	assert.Equal(t, 5, cfg.Workers.Count)
+}
+
+// TestLoad_Precedence tests that env overrides the file and flags override both.
+func TestLoad_Precedence(t *testing.T) {
+	path := writeConfigFile(t, `
+queue:
+  size: 10
+  readyThreshold: 0.5
+  priorities:
+    RocketSpeedDecreased: 1
+workers:
+  count: 2
+  stallTimeout: 1m
+limits:
+  channel: "5:10"
+  channelOverrides:
+    rocket-a: "50:100"
+signing:
+  tolerance: 30s
+`)
+
+	cfg, err := Load("test",
+		[]string{"-workers", "8", "-rate-limit-client", "0"},
+		env(map[string]string{FileEnv: path, "QUEUE_SIZE": "20", "QUEUE_LANE_WEIGHTS": "1, 2, 3", "WORKERS": "4", "LOG_REQUESTS": "false", "WS_ALLOWED_ORIGINS": "https://a.example, ,https://b.example",
+			"RATE_LIMIT_API_KEYS": "ops=k1, dashboard = k2", "TRUSTED_PROXIES": "10.0.0.0/8"}),
+		io.Discard)
+	require.NoError(t, err)
+
+	assert.Equal(t, 20, cfg.Queue.Size, "env overrides the file")
+	assert.Equal(t, 0.5, cfg.Queue.ReadyThreshold, "the file overrides defaults")
+	assert.Equal(t, []int{1, 2, 3}, cfg.Queue.LaneWeights)
+	assert.Equal(t, []string{"https://a.example", "https://b.example"}, cfg.Server.AllowedOrigins)
+	assert.Equal(t, []string{"10.0.0.0/8"}, cfg.Server.TrustedProxies)
+	assert.Equal(t, map[string]string{"ops": "k1", "dashboard": "k2"}, cfg.Limits.APIKeys)
+	assert.Equal(t, 1, cfg.Queue.Priorities["RocketSpeedDecreased"])
+	assert.Equal(t, 2, cfg.Queue.Priorities["RocketExploded"], "file maps are merged into the defaults")
+	assert.Equal(t, 8, cfg.Workers.Count, "flags override env")
+	assert.Equal(t, Duration(time.Minute), cfg.Workers.StallTimeout)
+	assert.Equal(t, Duration(30*time.Second), cfg.Signing.Tolerance)
+	assert.Equal(t, ratelimit.Limit{Rate: 5, Burst: 10}, cfg.Limits.Channel)
+	assert.Equal(t, map[string]ratelimit.Limit{"rocket-a": {Rate: 50, Burst: 100}}, cfg.Limits.ChannelOverrides)
+	assert.Equal(t, ratelimit.Limit{}, cfg.Limits.Client)
+	assert.False(t, cfg.Logging.Requests)
+	assert.Equal(t, Default().Webhooks, cfg.Webhooks, "untouched sections keep their defaults")
+
+	// -config wins over CONFIG_FILE.
+	other := writeConfigFile(t, "queue:\n  size: 30\n")
+	cfg, err = Load("test", []string{"-config", other}, env(map[string]string{FileEnv: path}), io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, 30, cfg.Queue.Size)
+	assert.Equal(t, 5, cfg.Workers.Count)
+}
+
+// TestLoad_Env tests the settings that are rarely changed but must still be
+// settable from the environment and flags.
+func TestLoad_Env(t *testing.T) {
+	cfg, err := Load("test",
+		[]string{"-webhook-queue-size", "64"},
+		env(map[string]string{
+			"AUTOSCALE_INTERVAL":      "250ms",
+			"WEBHOOK_INITIAL_BACKOFF": "2s",
+			"WEBHOOK_MAX_BACKOFF":     "1m",
+			"WEBHOOK_DISABLE_AFTER":   "3",
+			"WEBHOOK_QUEUE_SIZE":      "32",
+			"INGEST_KEYFILE":          "keys.txt",
+			"RADIO_UDP_ADDR":          ":9000",
+			"RADIO_UNSIGNED":          "true",
+		}),
+		io.Discard)
+	require.NoError(t, err)
+
+	assert.Equal(t, Duration(250*time.Millisecond), cfg.Workers.Autoscale.Interval)
+	assert.Equal(t, Duration(2*time.Second), cfg.Webhooks.InitialBackoff)
+	assert.Equal(t, Duration(time.Minute), cfg.Webhooks.MaxBackoff)
+	assert.Equal(t, 3, cfg.Webhooks.DisableAfter)
+	assert.Equal(t, 64, cfg.Webhooks.QueueSize, "flags override env")
+	assert.True(t, cfg.Radio.Unsigned, "unsigned radio listeners may run with a keyfile")
+}
+
+// TestLoad_Errors tests that bad input is reported at startup.
+func TestLoad_Errors(t *testing.T) {
+	cases := map[string]struct {
+		args []string
+		env  map[string]string
+		file string
+		want []string
+	}{
+		"unknown yaml key": {file: "queue:\n  sise: 10\n", want: []string{"field sise not found"}},
+		"bad env value":    {env: map[string]string{"WORKERS": "many"}, want: []string{"env WORKERS"}},
+		"bad flag value":   {args: []string{"-worker-stall-timeout", "soon"}, want: []string{"flag -worker-stall-timeout"}},
+		"unknown flag":     {args: []string{"-nope"}, want: []string{"not defined"}},
+		"positional args":  {args: []string{"extra"}, want: []string{"unexpected arguments"}},
+		"validation": {
+			env:  map[string]string{"QUEUE_SIZE": "0", "WORKERS": "-1", "REPOSITORY_BACKEND": "postgres"},
+			want: []string{"queue.size must be positive", "workers.count must be positive", `repository.backend "postgres"`},
+		},
+		"logging": {
+			env:  map[string]string{"LOG_LEVEL": "loud", "LOG_FORMAT": "xml"},
+			want: []string{`invalid log level "loud"`, `logging.format must be "text" or "json", got "xml"`},
+		},
+		"autoscale": {
+			env:  map[string]string{"WORKERS": "8", "WORKERS_MAX": "4", "AUTOSCALE": "true", "AUTOSCALE_MIN": "0", "AUTOSCALE_HIGH_WATER": "1000"},
+			want: []string{"workers.max (4) must be at least workers.count (8)", "workers.autoscale.min", "workers.autoscale.highWater"},
+		},
+		"queue": {
+			file: "queue:\n  backend: disk\n  dir: \"\"\n  segmentSize: 0\n  maxAttempts: 0\n  retryBackoff: 0s\n",
+			want: []string{"queue.dir must not be empty", "queue.segmentSize must be positive", "queue.maxAttempts must be positive", "queue.retryBackoff must be positive"},
+		},
+		"lanes": {
+			env:  map[string]string{"QUEUE_LANE_WEIGHTS": "1,0", "QUEUE_PRIORITIES": "RocketExploded=2,RocketCrashed=1"},
+			want: []string{"queue.laneWeights[1] must be positive", "queue.priorities[RocketExploded] must be a lane in [0, 2)", `unknown message type "RocketCrashed"`},
+		},
+		"contact": {
+			env:  map[string]string{"CONTACT_LOST_AFTER": "-1m", "CONTACT_CHECK_INTERVAL": "0s"},
+			want: []string{"contact.lostAfter must not be negative", "contact.checkInterval must be positive"},
+		},
+		"bad priorities": {env: map[string]string{"QUEUE_PRIORITIES": "RocketExploded"}, want: []string{"env QUEUE_PRIORITIES"}},
+		"webhooks": {
+			env:  map[string]string{"WEBHOOK_INITIAL_BACKOFF": "1m", "WEBHOOK_MAX_BACKOFF": "1s", "WEBHOOK_DISABLE_AFTER": "0", "WEBHOOK_QUEUE_SIZE": "0"},
+			want: []string{"webhooks backoff", "webhooks.disableAfter must be positive", "webhooks.queueSize must be positive"},
+		},
+		"unsigned radio": {
+			env:  map[string]string{"INGEST_KEYFILE": "keys.txt", "RADIO_TCP_ADDR": ":9000"},
+			want: []string{"radio listeners do not verify signatures"},
+		},
+		"autoscale interval": {env: map[string]string{"AUTOSCALE": "true", "AUTOSCALE_INTERVAL": "0s"}, want: []string{"workers.autoscale interval"}},
+		"bad api keys":       {env: map[string]string{"RATE_LIMIT_API_KEYS": "ops"}, want: []string{"env RATE_LIMIT_API_KEYS"}},
+		"empty api key":      {file: "limits:\n  apiKeys:\n    ops: \"\"\n", want: []string{"limits.apiKeys"}},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			values := map[string]string{}
+			for k, v := range tc.env {
+				values[k] = v
+			}
+			if tc.file != "" {
+				values[FileEnv] = writeConfigFile(t, tc.file)
+			}
+			_, err := Load("test", tc.args, env(values), io.Discard)
+			require.Error(t, err)
+			for _, want := range tc.want {
+				assert.Contains(t, err.Error(), want)
+			}
+		})
+	}
+
+	_, err := Load("test", []string{"-h"}, env(nil), io.Discard)
+	assert.ErrorIs(t, err, flag.ErrHelp)
+}
+
+// TestRedacted tests that secrets never leave the process.
+func TestRedacted(t *testing.T) {
+	cfg := Default()
+	cfg.Admin.Token = "s3cr3t"
+	cfg.Limits.APIKeys = map[string]string{"ops": "k3y"}
+
+	data, err := json.Marshal(cfg.Redacted())
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "s3cr3t")
+	assert.NotContains(t, cfg.String(), "s3cr3t")
+	assert.Equal(t, "s3cr3t", cfg.Admin.Token, "the original is untouched")
+	assert.NotContains(t, string(data), "k3y")
+	assert.Contains(t, string(data), `"apiKeys":{"ops":"[REDACTED]"}`)
+	assert.Equal(t, "k3y", cfg.Limits.APIKeys["ops"], "the original map is untouched")
+
+	var view map[string]map[string]any
+	require.NoError(t, json.Unmarshal(data, &view))
+	assert.Equal(t, "[REDACTED]", view["admin"]["token"])
+	assert.Equal(t, "30s", view["workers"]["stallTimeout"], "durations render as strings")
+	assert.Equal(t, "20:50", view["limits"]["channel"])
+}
@@ -0,0 +1,240 @@
+package config
+
+import (
+	"encoding"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/seansa/rocket-challenge/internal/ratelimit"
+)
+
+// FileEnv and FileFlag name the YAML file to load; the flag wins.
+const (
+	FileEnv  = "CONFIG_FILE"
+	FileFlag = "config"
+)
+
+// setting binds an environment variable, and the flag derived from its name,
+// to a field of Config.
+type setting struct {
+	env   string
+	usage string
+	field func(c *Config) any
+}
+
+// flagName derives the flag from the environment variable: QUEUE_SIZE becomes
+// -queue-size.
+func (s setting) flagName() string {
+	return strings.ToLower(strings.ReplaceAll(s.env, "_", "-"))
+}
+
+var settings = []setting{
+	{"PORT", "HTTP listen address", func(c *Config) any { return &c.Server.Addr }},
+	{"READ_HEADER_TIMEOUT", "time allowed to read request headers", func(c *Config) any { return &c.Server.ReadHeaderTimeout }},
+	{"SHUTDOWN_DELAY", "time /readyz fails before the listener closes", func(c *Config) any { return &c.Server.ShutdownDelay }},
+	{"SHUTDOWN_TIMEOUT", "time allowed for in-flight requests on shutdown", func(c *Config) any { return &c.Server.ShutdownTimeout }},
+	{"TRUSTED_PROXIES", "proxies whose X-Forwarded-For is believed, <ip|cidr>,...", func(c *Config) any { return &c.Server.TrustedProxies }},
+	{"WS_ALLOWED_ORIGINS", "browser origins allowed to open /ws besides the API's own, <origin>,... or *", func(c *Config) any { return &c.Server.AllowedOrigins }},
+	{"QUEUE_SIZE", "capacity of the message queue", func(c *Config) any { return &c.Queue.Size }},
+	{"READY_QUEUE_THRESHOLD", "queue fill ratio at which /readyz fails", func(c *Config) any { return &c.Queue.ReadyThreshold }},
+	{"QUEUE_ENQUEUE_WAIT", "time POST /messages waits for room in a full queue", func(c *Config) any { return &c.Queue.EnqueueWait }},
+	{"QUEUE_BACKEND", "message queue backend: memory or disk", func(c *Config) any { return &c.Queue.Backend }},
+	{"QUEUE_DIR", "directory of the disk message queue", func(c *Config) any { return &c.Queue.Dir }},
+	{"QUEUE_SEGMENT_SIZE", "size in bytes of the disk queue's segment files", func(c *Config) any { return &c.Queue.SegmentSize }},
+	{"QUEUE_SYNC", "fsync every message of the disk queue before accepting it", func(c *Config) any { return &c.Queue.Sync }},
+	{"QUEUE_MAX_ATTEMPTS", "deliveries of a failing message before the disk queue dead-letters it", func(c *Config) any { return &c.Queue.MaxAttempts }},
+	{"QUEUE_RETRY_BACKOFF", "delay before a failed message is delivered again, doubling", func(c *Config) any { return &c.Queue.RetryBackoff }},
+	{"QUEUE_LANE_WEIGHTS", "weights of the priority lanes from lane 0 up, <weight>,...", func(c *Config) any { return &c.Queue.LaneWeights }},
+	{"QUEUE_PRIORITIES", "lanes of message types, <type>=<lane>,...", func(c *Config) any { return &c.Queue.Priorities }},
+	{"RECEIPTS_CAPACITY", "message receipts kept for GET /messages/:id", func(c *Config) any { return &c.Receipts.Capacity }},
+	{"RECEIPTS_TTL", "how long a message receipt is kept", func(c *Config) any { return &c.Receipts.TTL }},
+	{"CONTACT_LOST_AFTER", "silence after which a rocket is flagged contactLost; 0 disables", func(c *Config) any { return &c.Contact.LostAfter }},
+	{"CONTACT_CHECK_INTERVAL", "how often rockets are checked for lost contact", func(c *Config) any { return &c.Contact.CheckInterval }},
+	{"WORKERS", "number of message processing workers", func(c *Config) any { return &c.Workers.Count }},
+	{"WORKERS_MAX", "upper bound for runtime worker resizing", func(c *Config) any { return &c.Workers.Max }},
+	{"AUTOSCALE", "grow and shrink the worker pool with the queue depth", func(c *Config) any { return &c.Workers.Autoscale.Enabled }},
+	{"AUTOSCALE_MIN", "smallest pool the autoscaler picks", func(c *Config) any { return &c.Workers.Autoscale.Min }},
+	{"AUTOSCALE_HIGH_WATER", "queue depth above which the pool grows", func(c *Config) any { return &c.Workers.Autoscale.HighWater }},
+	{"AUTOSCALE_INTERVAL", "how often the autoscaler samples the queue depth", func(c *Config) any { return &c.Workers.Autoscale.Interval }},
+	{"AUTOSCALE_UP_AFTER", "time above the high-water mark before growing", func(c *Config) any { return &c.Workers.Autoscale.ScaleUpAfter }},
+	{"AUTOSCALE_DOWN_AFTER", "time with an empty queue before shrinking", func(c *Config) any { return &c.Workers.Autoscale.ScaleDownAfter }},
+	{"WORKER_STALL_TIMEOUT", "heartbeat age after which a worker is wedged", func(c *Config) any { return &c.Workers.StallTimeout }},
+	{"REPOSITORY_BACKEND", "repository backend (memory)", func(c *Config) any { return &c.Repository.Backend }},
+	{"RATE_LIMIT_CHANNEL", "default rate limit per channel, <rps>:<burst>", func(c *Config) any { return &c.Limits.Channel }},
+	{"RATE_LIMIT_CHANNEL_OVERRIDES", "per-channel limits, <channel>=<rps>:<burst>,...", func(c *Config) any { return &c.Limits.ChannelOverrides }},
+	{"RATE_LIMIT_CLIENT", "rate limit per client, <rps>:<burst>", func(c *Config) any { return &c.Limits.Client }},
+	{"RATE_LIMIT_API_KEYS", "clients limited by API key instead of IP, <name>=<key>,...", func(c *Config) any { return &c.Limits.APIKeys }},
+	{"INGEST_KEYFILE", "keyfile enabling signed ingestion", func(c *Config) any { return &c.Signing.Keyfile }},
+	{"INGEST_KEYFILE_RELOAD", "keyfile polling interval", func(c *Config) any { return &c.Signing.Reload }},
+	{"INGEST_SIGNATURE_TOLERANCE", "allowed signature clock skew", func(c *Config) any { return &c.Signing.Tolerance }},
+	{"RADIO_TCP_ADDR", "radio NDJSON listen address", func(c *Config) any { return &c.Radio.TCPAddr }},
+	{"RADIO_UDP_ADDR", "radio datagram listen address", func(c *Config) any { return &c.Radio.UDPAddr }},
+	{"RADIO_UNSIGNED", "run the radio listeners, which accept unsigned messages, with signed ingestion", func(c *Config) any { return &c.Radio.Unsigned }},
+	{"WEBHOOK_MAX_ATTEMPTS", "delivery attempts per webhook event", func(c *Config) any { return &c.Webhooks.MaxAttempts }},
+	{"WEBHOOK_INITIAL_BACKOFF", "wait before the first retry of a webhook delivery", func(c *Config) any { return &c.Webhooks.InitialBackoff }},
+	{"WEBHOOK_MAX_BACKOFF", "longest wait between webhook delivery retries", func(c *Config) any { return &c.Webhooks.MaxBackoff }},
+	{"WEBHOOK_DISABLE_AFTER", "consecutive failed deliveries that disable a webhook", func(c *Config) any { return &c.Webhooks.DisableAfter }},
+	{"WEBHOOK_QUEUE_SIZE", "events waiting for delivery per webhook", func(c *Config) any { return &c.Webhooks.QueueSize }},
+	{"WEBHOOK_TIMEOUT", "timeout of a webhook delivery", func(c *Config) any { return &c.Webhooks.Timeout }},
+	{"WEBHOOK_ALLOWED_HOSTS", "private webhook targets to allow, <host|ip|cidr>,...", func(c *Config) any { return &c.Webhooks.AllowedHosts }},
+	{"LOG_REQUESTS", "log every HTTP request", func(c *Config) any { return &c.Logging.Requests }},
+	{"LOG_LEVEL", "minimum log level: debug, info, warn or error", func(c *Config) any { return &c.Logging.Level }},
+	{"LOG_FORMAT", "log output format: text or json", func(c *Config) any { return &c.Logging.Format }},
+	{"ADMIN_TOKEN", "bearer token of /admin and /webhooks; both are disabled without it", func(c *Config) any { return &c.Admin.Token }},
+}
+
+// set parses raw into the field the setting is bound to.
+func (s setting) set(c *Config, raw string) error {
+	var err error
+	switch field := s.field(c).(type) {
+	case *string:
+		*field = raw
+	case *int:
+		*field, err = strconv.Atoi(raw)
+	case *float64:
+		*field, err = strconv.ParseFloat(raw, 64)
+	case *bool:
+		*field, err = strconv.ParseBool(raw)
+	case *[]string:
+		*field = parseStrings(raw)
+	case *[]int:
+		*field, err = parseInts(raw)
+	case *map[string]int:
+		*field, err = parseIntMap(raw)
+	case *map[string]string:
+		*field, err = parseStringMap(raw)
+	case *map[string]ratelimit.Limit:
+		*field, err = ratelimit.ParseOverrides(raw)
+	case encoding.TextUnmarshaler:
+		err = field.UnmarshalText([]byte(raw))
+	default:
+		err = fmt.Errorf("unsupported setting type %T", field)
+	}
+	return err
+}
+
+// parseStrings reads a comma-separated list, skipping empty items.
+func parseStrings(s string) []string {
+	var values []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
+}
+
+// parseInts reads a comma-separated list of integers.
+func parseInts(s string) ([]int, error) {
+	var values []int
+	for _, item := range strings.Split(s, ",") {
+		value, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// parseIntMap reads comma-separated "<key>=<integer>" pairs.
+func parseIntMap(s string) (map[string]int, error) {
+	values := make(map[string]int)
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, raw, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q: expected <key>=<integer>", pair)
+		}
+		value, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", pair, err)
+		}
+		values[strings.TrimSpace(key)] = value
+	}
+	return values, nil
+}
+
+// parseStringMap reads comma-separated "<key>=<value>" pairs.
+func parseStringMap(s string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("expected <key>=<value> pairs")
+		}
+		values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return values, nil
+}
+
+// rawFlag records the text of a flag; it is applied after the file and the
+// environment so that flags win.
+type rawFlag struct {
+	value string
+}
+
+func (f *rawFlag) String() string     { return f.value }
+func (f *rawFlag) Set(s string) error { f.value = s; return nil }
+
+// Load builds the configuration from the defaults, the YAML file named by
+// -config or CONFIG_FILE, the environment and args, then validates it.
+// getenv is os.Getenv outside of tests.
+func Load(name string, args []string, getenv func(string) string, output io.Writer) (Config, error) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(output)
+	file := flags.String(FileFlag, getenv(FileEnv), "YAML configuration file (env "+FileEnv+")")
+	raw := make(map[string]*rawFlag, len(settings))
+	for _, s := range settings {
+		raw[s.env] = &rawFlag{}
+		flags.Var(raw[s.env], s.flagName(), s.usage+" (env "+s.env+")")
+	}
+	if err := flags.Parse(args); err != nil {
+		return Config{}, err
+	}
+	if flags.NArg() > 0 {
+		return Config{}, fmt.Errorf("unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	}
+
+	cfg := Default()
+	if *file != "" {
+		if err := cfg.loadFile(*file); err != nil {
+			return Config{}, fmt.Errorf("config file: %w", err)
+		}
+	}
+
+	for _, s := range settings {
+		if value := getenv(s.env); value != "" {
+			if err := s.set(&cfg, value); err != nil {
+				return Config{}, fmt.Errorf("env %s=%q: %w", s.env, value, err)
+			}
+		}
+	}
+
+	var flagErr error
+	flags.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if f.Name == s.flagName() && flagErr == nil {
+				if err := s.set(&cfg, raw[s.env].value); err != nil {
+					flagErr = fmt.Errorf("flag -%s=%q: %w", f.Name, raw[s.env].value, err)
+				}
+			}
+		}
+	})
+	if flagErr != nil {
+		return Config{}, flagErr
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seansa/rocket-challenge/internal/config"
+	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/seansa/rocket-challenge/internal/service"
+)
+
+type AdminController struct {
+	config config.Config
+	pool   *service.WorkerPool
+}
+
+func NewAdminController(cfg config.Config, pool *service.WorkerPool) *AdminController {
+	return &AdminController{
+		config: cfg,
+		pool:   pool,
+	}
+}
+
+// AdminAuth returns a middleware that requires "Authorization: Bearer <token>".
+// With an empty token every request is refused, so that the endpoints it
+// guards are never open by accident.
+func AdminAuth(token string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if token == "" {
+			_ = ctx.Error(fmt.Errorf("admin endpoints are disabled until an admin token is configured: %w", model.ErrUnauthorized))
+			ctx.Abort()
+			return
+		}
+		given, ok := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			_ = ctx.Error(fmt.Errorf("admin endpoints require a valid bearer token: %w", model.ErrUnauthorized))
+			ctx.Abort()
+		}
+	}
+}
+
+// GetConfigHandler handles GET requests to the /admin/config endpoint.
+// @Summary Get the effective configuration
+// @Description Returns the configuration the server started with, after merging the YAML file, environment variables and flags. Secrets are redacted.
+// @Tags admin
+// @Produce json,application/problem+json
+// @Security AdminToken
+// @Success 200 {object} config.Config "Effective configuration"
+// @Failure 401 {object} model.Problem "Missing or invalid admin token (UNAUTHORIZED)"
+// @Router /admin/config [get]
+func (c *AdminController) GetConfigHandler(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.config.Redacted())
+}
+
+// GetWorkersHandler handles GET requests to the /admin/workers endpoint.
+// @Summary Get the worker pool
+// @Description Returns the number of message processing workers, the autoscaler settings when it runs, and each worker's last heartbeat.
+// @Tags admin
+// @Produce json,application/problem+json
+// @Security AdminToken
+// @Success 200 {object} model.WorkerPoolStatus "Worker pool"
+// @Failure 401 {object} model.Problem "Missing or invalid admin token (UNAUTHORIZED)"
+// @Router /admin/workers [get]
+func (c *AdminController) GetWorkersHandler(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.pool.Status())
+}
+
+// SetWorkersHandler handles PUT requests to the /admin/workers endpoint.
+// @Summary Resize the worker pool
+// @Description Starts or stops workers until the pool has the requested size, without interrupting messages being processed. When autoscaling is enabled the autoscaler keeps adjusting from the new size.
+// @Tags admin
+// @Accept json
+// @Produce json,application/problem+json
+// @Security AdminToken
+// @Param request body model.WorkerPoolRequest true "New worker count"
+// @Success 200 {object} model.WorkerPoolStatus "Resized worker pool"
+// @Failure 400 {object} model.Problem "Count missing or outside [1, workers.max] (INVALID_PAYLOAD)"
+// @Failure 401 {object} model.Problem "Missing or invalid admin token (UNAUTHORIZED)"
+// @Router /admin/workers [put]
+func (c *AdminController) SetWorkersHandler(ctx *gin.Context) {
+	var req model.WorkerPoolRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		_ = ctx.Error(fmt.Errorf("invalid worker pool request: %w: %w", model.ErrInvalidPayload, err))
+		return
+	}
+
+	if err := c.pool.Resize(req.Count); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, c.pool.Status())
+}
+
+// GetProcessingHandler handles GET requests to the /admin/processing endpoint.
+// @Summary Get the processing state
+// @Description Returns whether the workers are paused, since when, the channels paused on their own and the message queue.
+// @Tags admin
+// @Produce json,application/problem+json
+// @Security AdminToken
+// @Success 200 {object} model.ProcessingStatus "Processing state"
+// @Failure 401 {object} model.Problem "Missing or invalid admin token (UNAUTHORIZED)"
+// @Router /admin/processing [get]
+func (c *AdminController) GetProcessingHandler(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.pool.Processing())
+}
+
+// PauseProcessingHandler handles POST requests to the /admin/processing/pause endpoint.
+// @Summary Pause message processing
+// @Description Stops the workers from taking messages off the queue, for one channel or for the whole fleet. POST /messages keeps accepting messages until the queue is full. Messages being processed are finished. Pausing again is a no-op.
+// @Tags admin
+// @Produce json,application/problem+json
+// @Security AdminToken
+// @Param channel query string false "Pause only this channel"
+// @Success 200 {object} model.ProcessingStatus "Processing state"
+// @Failure 401 {object} model.Problem "Missing or invalid admin token (UNAUTHORIZED)"
+// @Router /admin/processing/pause [post]
+func (c *AdminController) PauseProcessingHandler(ctx *gin.Context) {
+	c.pool.Pause(ctx.Query("channel"))
+	ctx.JSON(http.StatusOK, c.pool.Processing())
+}
+
+// ResumeProcessingHandler handles POST requests to the /admin/processing/resume endpoint.
+// @Summary Resume message processing
+// @Description Undoes a pause for one channel or for the whole fleet. Resuming the fleet leaves channels paused on their own paused. Resuming what is not paused is a no-op.
+// @Tags admin
+// @Produce json,application/problem+json
+// @Security AdminToken
+// @Param channel query string false "Resume only this channel"
+// @Success 200 {object} model.ProcessingStatus "Processing state"
+// @Failure 401 {object} model.Problem "Missing or invalid admin token (UNAUTHORIZED)"
+// @Router /admin/processing/resume [post]
+func (c *AdminController) ResumeProcessingHandler(ctx *gin.Context) {
+	c.pool.Resume(ctx.Query("channel"))
+	ctx.JSON(http.StatusOK, c.pool.Processing())
+}
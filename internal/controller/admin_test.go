@@ -0,0 +1,176 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seansa/rocket-challenge/internal/config"
+	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/seansa/rocket-challenge/internal/queue"
+	"github.com/seansa/rocket-challenge/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const testAdminToken = "s3cr3t"
+
+// adminConfig returns the default configuration with testAdminToken set.
+func adminConfig() config.Config {
+	cfg := config.Default()
+	cfg.Admin.Token = testAdminToken
+	return cfg
+}
+
+// adminRequest returns a request carrying testAdminToken.
+func adminRequest(method, path string, body io.Reader) *http.Request {
+	req := httptest.NewRequest(method, path, body)
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	return req
+}
+
+func setupAdminRouter(cfg config.Config, pool *service.WorkerPool) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(ErrorHandler())
+	adminController := NewAdminController(cfg, pool)
+	admin := r.Group("/admin", AdminAuth(cfg.Admin.Token))
+	admin.GET("/config", adminController.GetConfigHandler)
+	admin.GET("/workers", adminController.GetWorkersHandler)
+	admin.PUT("/workers", adminController.SetWorkersHandler)
+	admin.GET("/processing", adminController.GetProcessingHandler)
+	admin.POST("/processing/pause", adminController.PauseProcessingHandler)
+	admin.POST("/processing/resume", adminController.ResumeProcessingHandler)
+	return r
+}
+
+// TestGetConfigHandler tests the redacted view and the bearer token check.
+func TestGetConfigHandler(t *testing.T) {
+	cfg := adminConfig()
+	router := setupAdminRouter(cfg, nil)
+
+	for _, header := range []string{"", "Bearer wrong", "s3cr3t"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/admin/config", nil)
+		req.Header.Set("Authorization", header)
+		router.ServeHTTP(w, req)
+		assertProblem(t, w, http.StatusUnauthorized, CodeUnauthorized)
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/admin/config", nil)
+	req.Header.Set("Authorization", "Bearer s3cr3t")
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "s3cr3t")
+
+	var view config.Config
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &view))
+	assert.Equal(t, "[REDACTED]", view.Admin.Token)
+	assert.Equal(t, cfg.Queue, view.Queue)
+	assert.Equal(t, cfg.Limits.Channel, view.Limits.Channel)
+}
+
+// TestAdminAuth_NoToken tests that admin endpoints are disabled until a token
+// is configured.
+func TestAdminAuth_NoToken(t *testing.T) {
+	router := setupAdminRouter(config.Default(), nil)
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/admin/config", nil),
+		httptest.NewRequest(http.MethodPost, "/admin/processing/pause", nil),
+		adminRequest(http.MethodPut, "/admin/workers", bytes.NewBufferString(`{"count":1}`)),
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assertProblem(t, w, http.StatusUnauthorized, CodeUnauthorized)
+	}
+}
+
+// TestWorkersHandlers tests reading and resizing the worker pool.
+func TestWorkersHandlers(t *testing.T) {
+	q := queue.NewMemory(0, queue.Lanes{})
+	pool := service.NewWorkerPool(q, new(MockRocketService), service.NewHeartbeats(time.Minute), 4)
+	defer pool.Stop()
+	require.NoError(t, pool.Resize(2))
+	router := setupAdminRouter(adminConfig(), pool)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest(http.MethodGet, "/admin/workers", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var status model.WorkerPoolStatus
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, 2, status.Count)
+	assert.Equal(t, 4, status.Max)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest(http.MethodPut, "/admin/workers", bytes.NewBufferString(`{"count":3}`)))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, 3, status.Count)
+	assert.Equal(t, 3, pool.Size())
+
+	for _, body := range []string{`{"count":5}`, `{"count":0}`, `{}`, `nope`} {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, adminRequest(http.MethodPut, "/admin/workers", bytes.NewBufferString(body)))
+		assertProblem(t, w, http.StatusBadRequest, CodeInvalidPayload)
+	}
+	assert.Equal(t, 3, pool.Size())
+}
+
+// TestProcessingHandlers tests pausing and resuming the fleet and one channel
+// while messages keep being accepted.
+func TestProcessingHandlers(t *testing.T) {
+	q := queue.NewMemory(10, queue.Lanes{})
+	mockService := new(MockRocketService)
+	pool := service.NewWorkerPool(q, mockService, service.NewHeartbeats(time.Minute), 4)
+	defer pool.Stop()
+	require.NoError(t, pool.Resize(1))
+	router := setupAdminRouter(adminConfig(), pool)
+
+	processing := func(method, path string) model.ProcessingStatus {
+		t.Helper()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, adminRequest(method, path, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		var status model.ProcessingStatus
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+		return status
+	}
+
+	status := processing(http.MethodPost, "/admin/processing/pause")
+	assert.Equal(t, model.ProcessingPaused, status.Status)
+	require.NotNil(t, status.PausedSince)
+	since := *status.PausedSince
+	assert.Equal(t, since, *processing(http.MethodPost, "/admin/processing/pause").PausedSince, "pausing again keeps the time")
+
+	status = processing(http.MethodPost, "/admin/processing/pause?channel=rocket-b")
+	require.Len(t, status.PausedChannels, 1)
+	assert.Equal(t, "rocket-b", status.PausedChannels[0].Channel)
+
+	processed := make(chan string, 2)
+	mockService.On("ProcessMessage", mock.Anything).Run(func(args mock.Arguments) {
+		processed <- args.Get(0).(*model.IncomingMessage).Metadata.Channel
+	}).Return("processed", nil)
+	for _, channel := range []string{"rocket-a", "rocket-b"} {
+		require.NoError(t, q.TryEnqueue(model.IncomingMessage{Metadata: model.Metadata{Channel: channel, MessageNumber: 1}}))
+	}
+	status = processing(http.MethodGet, "/admin/processing")
+	assert.Equal(t, model.QueueStatus{Depth: 2, Capacity: 10}, status.Queue)
+
+	// Resuming the fleet leaves rocket-b paused.
+	status = processing(http.MethodPost, "/admin/processing/resume")
+	assert.Equal(t, model.ProcessingRunning, status.Status)
+	assert.Nil(t, status.PausedSince)
+	assert.Equal(t, "rocket-a", <-processed)
+	assert.Eventually(t, func() bool { return q.Len() == 1 }, time.Second, time.Millisecond)
+
+	status = processing(http.MethodPost, "/admin/processing/resume?channel=rocket-b")
+	assert.Empty(t, status.PausedChannels)
+	assert.Equal(t, "rocket-b", <-processed)
+}
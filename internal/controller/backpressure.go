@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+)
+
+// Bounds of the Retry-After estimated for a full queue.
+const (
+	minQueueRetryAfter = time.Second
+	maxQueueRetryAfter = time.Minute
+)
+
+// DrainRater reports how many messages per second the workers recently took
+// off the message queue.
+type DrainRater interface {
+	DrainRate() float64
+}
+
+// queueFullError is returned when a message could not be queued within the
+// enqueue wait. It wraps model.ErrQueueFull, tells the client when to retry
+// and describes the queue for the problem body.
+type queueFullError struct {
+	err        error
+	status     model.QueueStatus
+	retryAfter time.Duration
+}
+
+func (e *queueFullError) Error() string { return e.err.Error() }
+
+func (e *queueFullError) Unwrap() error { return e.err }
+
+// RetryAfter is how long the client should wait before retrying.
+func (e *queueFullError) RetryAfter() time.Duration { return e.retryAfter }
+
+// QueueStatus describes the queue at the time the message was rejected.
+func (e *queueFullError) QueueStatus() model.QueueStatus { return e.status }
+
+// enqueue queues msg, waiting up to the enqueue wait for room while the
+// request is alive. A full queue is reported as a *queueFullError.
+func (c *RocketController) enqueue(ctx context.Context, msg model.IncomingMessage) error {
+	var err error
+	if c.enqueueWait > 0 {
+		waitCtx, cancel := context.WithTimeout(ctx, c.enqueueWait)
+		defer cancel()
+		err = c.queue.Enqueue(waitCtx, msg)
+	} else {
+		err = c.queue.TryEnqueue(msg)
+	}
+	if err == nil || !errors.Is(err, model.ErrQueueFull) {
+		return err
+	}
+
+	status := model.QueueStatus{Depth: c.queue.Len(), Capacity: c.queue.Cap()}
+	if c.drain != nil {
+		status.DrainRate = c.drain.DrainRate()
+	}
+	return &queueFullError{err: err, status: status, retryAfter: queueRetryAfter(status)}
+}
+
+// queueRetryAfter estimates how long the workers need to work through the
+// messages queued now at their recent drain rate. Producers told to wait that
+// long come back to a queue with room, instead of retrying into it at once.
+func queueRetryAfter(status model.QueueStatus) time.Duration {
+	if status.DrainRate <= 0 {
+		return maxQueueRetryAfter
+	}
+	estimate := time.Duration(float64(status.Depth) / status.DrainRate * float64(time.Second))
+	return min(max(estimate, minQueueRetryAfter), maxQueueRetryAfter)
+}
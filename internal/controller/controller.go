@@ -1,104 +1,323 @@
 package controller
 
 import (
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
-	"strings"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/seansa/rocket-challenge/internal/logging"
 	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/seansa/rocket-challenge/internal/queue"
+	"github.com/seansa/rocket-challenge/internal/ratelimit"
 	"github.com/seansa/rocket-challenge/internal/service"
+	"github.com/seansa/rocket-challenge/internal/signature"
 )
 
+// MessageVerifier authenticates a raw POST /messages body for its channel,
+// given the value of the signature.Header request header.
+type MessageVerifier interface {
+	Verify(channel, header string, body []byte) error
+}
+
 type RocketController struct {
 	service        service.Service
-	messageChannel chan<- model.IncomingMessage
+	queue          queue.Queue
+	verifier       MessageVerifier
+	channelLimiter *ratelimit.Limiter
+	clientLimiter  *ratelimit.Limiter
+	receipts       *service.Receipts
+	enqueueWait    time.Duration
+	drain          DrainRater
+	allowedOrigins []string
+	// apiKeys maps the known API keys to the names of their clients.
+	apiKeys map[string]string
 }
 
-func NewRocketController(service service.Service, msgChan chan<- model.IncomingMessage) *RocketController {
+// APIKeyHeader identifies a client for rate limiting. Clients that do not send
+// a known key are identified by their IP address.
+const APIKeyHeader = "X-API-Key"
+
+func NewRocketController(service service.Service, q queue.Queue) *RocketController {
 	return &RocketController{
-		service:        service,
-		messageChannel: msgChan,
+		service: service,
+		queue:   q,
+	}
+}
+
+// SetMessageVerifier makes MessageHandler reject messages that verifier does
+// not accept. Without a verifier, messages are not authenticated.
+func (c *RocketController) SetMessageVerifier(verifier MessageVerifier) {
+	c.verifier = verifier
+}
+
+// SetRateLimiters makes MessageHandler throttle messages per channel and per
+// client. Either limiter may be nil to disable that limit.
+func (c *RocketController) SetRateLimiters(channel, client *ratelimit.Limiter) {
+	c.channelLimiter = channel
+	c.clientLimiter = client
+}
+
+// SetAPIKeys gives the clients of keys, a map of client names to API keys,
+// a client bucket of their own. Any other X-API-Key is ignored, so that
+// changing it on every request does not escape the client limit.
+func (c *RocketController) SetAPIKeys(keys map[string]string) {
+	c.apiKeys = make(map[string]string, len(keys))
+	for name, key := range keys {
+		c.apiKeys[key] = name
 	}
 }
 
+// SetReceipts makes MessageHandler issue a receipt for every accepted message,
+// served by GetMessageReceiptHandler.
+func (c *RocketController) SetReceipts(receipts *service.Receipts) {
+	c.receipts = receipts
+}
+
+// SetBackpressure makes MessageHandler wait up to wait for room in a full
+// queue, as long as the request is alive, before answering 503. The 503 then
+// carries a Retry-After estimated from the drain rate reported by drain,
+// which may be nil.
+func (c *RocketController) SetBackpressure(wait time.Duration, drain DrainRater) {
+	c.enqueueWait = wait
+	c.drain = drain
+}
+
 // MessageHandler handles incoming POST requests to the /messages endpoint.
 // @Summary Receive rocket message
-// @Description Processes an incoming rocket state message. Handles out-of-order and duplicate messages.
+// @Description Processes an incoming rocket state message. Handles out-of-order and duplicate messages. When signed ingestion is enabled, the body must be signed with the channel's secret in the X-Signature header ("t=<unix>,v1=<hex HMAC-SHA256 of "<t>.<body>">").
 // @Tags messages
 // @Accept json
-// @Produce json
+// @Produce json,application/problem+json
 // @Param message body model.IncomingMessage true "Rocket message payload"
-// @Success 200 {object} map[string]string "Status of message processing"
-// @Failure 400 {object} map[string]string "Invalid JSON or bad request"
-// @Failure 500 {object} map[string]string "Internal server error"
+// @Param X-Signature header string false "Body signature, required when signed ingestion is enabled"
+// @Param X-Request-ID header string false "Correlation ID for the message's log lines; generated when absent and echoed in the response"
+// @Param X-API-Key header string false "Client identity for rate limiting; the client IP is used when absent or unknown"
+// @Success 202 {object} map[string]string "Message accepted for processing, with its requestId and receiptId; Location points to the receipt"
+// @Failure 400 {object} model.Problem "Invalid JSON or bad request, or the reserved channel \"stream\" (INVALID_PAYLOAD)"
+// @Failure 401 {object} model.Problem "Missing, invalid or replayed signature (UNAUTHORIZED)"
+// @Failure 429 {object} model.Problem "Channel or client rate limit exceeded, see Retry-After (RATE_LIMITED)"
+// @Failure 503 {object} model.Problem "Message queue still full after the enqueue wait, see Retry-After and the queue member (QUEUE_FULL)"
 // @Router /messages [post]
 func (c *RocketController) MessageHandler(ctx *gin.Context) {
-	var msg model.IncomingMessage
+	// The client limit applies before any work is done on the request.
+	if err := c.throttle(ctx, c.clientLimiter, c.clientKey(ctx)); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	body, err := ctx.GetRawData()
+	if err != nil {
+		receivedMessages.WithLabelValues(resultInvalid).Inc()
+		_ = ctx.Error(fmt.Errorf("reading request body: %w: %w", model.ErrInvalidPayload, err))
+		return
+	}
 
-	if err := ctx.ShouldBindJSON(&msg); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON or empty request body", "details": err.Error()})
+	msg, err := DecodeMessage(body)
+	if err != nil {
+		receivedMessages.WithLabelValues(resultInvalid).Inc()
+		_ = ctx.Error(fmt.Errorf("invalid JSON or empty request body: %w", err))
 		return
 	}
+	msg.RequestID = requestID(ctx)
+	logger := logging.ForMessage(&msg)
+
+	if c.verifier != nil {
+		if err := c.verifier.Verify(msg.Metadata.Channel, ctx.GetHeader(signature.Header), body); err != nil {
+			receivedMessages.WithLabelValues(resultUnauthorized).Inc()
+			logger.Warn("Message rejected", "error", err)
+			_ = ctx.Error(err)
+			return
+		}
+	}
 
-	select {
-	case c.messageChannel <- msg:
-		log.Printf("Message for channel %s (msg #%d) accepted for processing.", msg.Metadata.Channel, msg.Metadata.MessageNumber)
-		// Return 202 Accepted, indicating the request has been accepted for processing.
-		ctx.JSON(http.StatusAccepted, gin.H{"status": "accepted_for_processing", "channel": msg.Metadata.Channel})
-	default:
-		// If the channel is full, respond with Service Unavailable (503).
-		log.Printf("Message for channel %s (msg #%d) rejected: message queue full.", msg.Metadata.Channel, msg.Metadata.MessageNumber)
-		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": "Message queue full, please try again later"})
+	// The channel limit applies after verification, so that forged messages
+	// cannot use up a channel's budget.
+	if err := c.throttle(ctx, c.channelLimiter, msg.Metadata.Channel); err != nil {
+		_ = ctx.Error(err)
+		return
 	}
 
+	// The receipt is issued before enqueueing so that a worker always finds it.
+	c.receipts.Issue(&msg)
+	if err := c.enqueue(ctx.Request.Context(), msg); err != nil {
+		c.receipts.Discard(msg.ReceiptID)
+		receivedMessages.WithLabelValues(resultRejected).Inc()
+		var full *queueFullError
+		if errors.As(err, &full) {
+			// If the queue is still full, respond with Service Unavailable (503).
+			logger.Warn("Message rejected: message queue full", "depth", full.status.Depth, "retry_after", full.retryAfter)
+		} else {
+			logger.Error("Enqueueing message failed", "error", err)
+		}
+		_ = ctx.Error(fmt.Errorf("channel %s (msg #%d): %w", msg.Metadata.Channel, msg.Metadata.MessageNumber, err))
+		return
+	}
+
+	receivedMessages.WithLabelValues(resultAccepted).Inc()
+	logger.Info("Message accepted for processing", "receipt", msg.ReceiptID)
+	// Return 202 Accepted, indicating the request has been accepted for processing.
+	response := gin.H{"status": "accepted_for_processing", "channel": msg.Metadata.Channel, "requestId": msg.RequestID}
+	if msg.ReceiptID != "" {
+		response["receiptId"] = msg.ReceiptID
+		ctx.Header("Location", "/messages/"+msg.ReceiptID)
+	}
+	ctx.JSON(http.StatusAccepted, response)
+}
+
+// GetMessageReceiptHandler handles GET requests to the /messages/{id} endpoint.
+// @Summary Get a message receipt
+// @Description Returns the lifecycle of an accepted message by the receiptId of its 202 response: queued, processing, then processed, ignored_old, duplicate or failed with the error. Receipts expire after the configured TTL, and the oldest are evicted first when the store is full.
+// @Tags messages
+// @Produce json,application/problem+json
+// @Param id path string true "Receipt ID"
+// @Success 200 {object} model.Receipt "Message receipt"
+// @Failure 404 {object} model.Problem "Unknown, expired or evicted receipt (NOT_FOUND)"
+// @Router /messages/{id} [get]
+func (c *RocketController) GetMessageReceiptHandler(ctx *gin.Context) {
+	receipt, err := c.receipts.Get(ctx.Param("id"))
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, receipt)
+}
+
+func (c *RocketController) throttle(ctx *gin.Context, limiter *ratelimit.Limiter, key string) error {
+	if limiter == nil {
+		return nil
+	}
+	if err := limiter.Allow(key); err != nil {
+		receivedMessages.WithLabelValues(resultThrottled).Inc()
+		throttledMessages.WithLabelValues(limiter.Scope()).Inc()
+		slog.WarnContext(ctx.Request.Context(), "Message throttled", "error", err)
+		return err
+	}
+	return nil
+}
+
+// clientKey names the client bucket of a request: the client name of a known
+// API key, so that the key itself is never logged, or the client IP.
+func (c *RocketController) clientKey(ctx *gin.Context) string {
+	if name, known := c.apiKeys[ctx.GetHeader(APIKeyHeader)]; known {
+		return "key:" + name
+	}
+	return ctx.ClientIP()
+}
+
+// streamChannel is not a valid channel, since GET /rockets/stream is the fleet
+// stream and could never return the state of a rocket of that name.
+const streamChannel = "stream"
+
+// DecodeMessage parses and validates a single JSON message with the same rules
+// MessageHandler applies, so that other transports accept exactly the same payloads.
+func DecodeMessage(data []byte) (model.IncomingMessage, error) {
+	var msg model.IncomingMessage
+	if err := binding.JSON.BindBody(data, &msg); err != nil {
+		return model.IncomingMessage{}, fmt.Errorf("%w: %w", model.ErrInvalidPayload, err)
+	}
+	if msg.Metadata.Channel == streamChannel {
+		return model.IncomingMessage{}, fmt.Errorf("channel %q is reserved: %w", streamChannel, model.ErrInvalidPayload)
+	}
+	return msg, nil
 }
 
 // GetAllRocketsHandler handles GET requests to the /rockets endpoint.
 // @Summary Get all rocket states
-// @Description Returns a list of the current states of all rockets in the system, sorted by channel ID.
+// @Description Returns a list of the current states of all rockets in the system, sorted by channel ID. The representation is chosen with ?format= or the Accept header (JSON, CSV or NDJSON) and rows are streamed as they are read from the repository. ?fields= selects a sparse fieldset.
 // @Tags rockets
-// @Produce json
+// @Produce json,text/csv,application/x-ndjson,application/problem+json
+// @Param format query string false "Representation, overrides Accept" Enums(json, csv, ndjson)
+// @Param fields query string false "Comma-separated fields to include, e.g. channel,speed,mission"
+// @Param contactLost query bool false "Only rockets whose contact is lost (true) or not (false)"
 // @Success 200 {array} model.Rocket "List of all rockets"
-// @Failure 500 {object} map[string]string "Internal server error"
+// @Failure 400 {object} model.Problem "Unknown format or field, or invalid contactLost (INVALID_PAYLOAD)"
+// @Failure 406 {object} model.Problem "No acceptable representation (NOT_ACCEPTABLE)"
+// @Failure 500 {object} model.Problem "Internal server error (INTERNAL_ERROR)"
 // @Router /rockets [get]
 func (c *RocketController) GetAllRocketsHandler(ctx *gin.Context) {
-	rockets, err := c.service.GetAllRocketStates()
+	encoder, err := negotiateRocketEncoder(ctx)
 	if err != nil {
-		log.Printf("Error getting all rockets from service: %+v", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching rockets", "details": err.Error()})
+		_ = ctx.Error(err)
+		return
+	}
+	include, err := rocketFilter(ctx)
+	if err != nil {
+		_ = ctx.Error(err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, rockets)
+	stream := encoder.stream(ctx, true)
+	err = c.service.ForEachRocketState(func(rocket model.Rocket) error {
+		if !include(rocket) {
+			return nil
+		}
+		return stream.write(rocket)
+	})
+	if err != nil {
+		stream.fail(err)
+		return
+	}
+	stream.close()
+}
+
+// rocketFilter returns the filter of GET /rockets from its query: every
+// rocket, or only those whose contact is lost or not with ?contactLost=.
+func rocketFilter(ctx *gin.Context) (func(model.Rocket) bool, error) {
+	value, set := ctx.GetQuery("contactLost")
+	if !set {
+		return func(model.Rocket) bool { return true }, nil
+	}
+	contactLost, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid contactLost %q (use true or false): %w", value, model.ErrInvalidPayload)
+	}
+	return func(rocket model.Rocket) bool { return rocket.ContactLost == contactLost }, nil
 }
 
 // GetRocketStateHandler handles GET requests to the /rockets/{channel} endpoint.
 // @Summary Get a single rocket state
-// @Description Returns the current state of a specific rocket by its channel ID.
+// @Description Returns the current state of a specific rocket by its channel ID, in the representation chosen with ?format= or the Accept header (JSON, CSV or NDJSON), optionally restricted to ?fields=.
 // @Tags rockets
-// @Produce json
+// @Produce json,text/csv,application/x-ndjson,application/problem+json
 // @Param channel path string true "Rocket Channel ID"
+// @Param format query string false "Representation, overrides Accept" Enums(json, csv, ndjson)
+// @Param fields query string false "Comma-separated fields to include, e.g. channel,speed,mission"
 // @Success 200 {object} model.Rocket "Current state of the rocket"
-// @Failure 400 {object} map[string]string "Missing rocket channel ID"
-// @Failure 404 {object} map[string]string "Rocket not found"
-// @Failure 500 {object} map[string]string "Internal server error"
+// @Failure 400 {object} model.Problem "Unknown format or field (INVALID_PAYLOAD)"
+// @Failure 404 {object} model.Problem "Rocket not found (NOT_FOUND)"
+// @Failure 406 {object} model.Problem "No acceptable representation (NOT_ACCEPTABLE)"
+// @Failure 500 {object} model.Problem "Internal server error (INTERNAL_ERROR)"
 // @Router /rockets/{channel} [get]
 func (c *RocketController) GetRocketStateHandler(ctx *gin.Context) {
 	channel := ctx.Param("channel")
 
+	encoder, err := negotiateRocketEncoder(ctx)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
 	rocket, err := c.service.GetRocketState(channel)
 	if err != nil {
-		//TODO: create a custom error type for better error handling
-		if strings.Contains(err.Error(), "not found") {
-			ctx.JSON(http.StatusNotFound, gin.H{"error": "Rocket not found", "channel": channel})
-		} else {
-			log.Printf("Error getting rocket state %s from service: %v", channel, err)
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error while fetching rocket %s", channel), "details": err.Error()})
-		}
+		_ = ctx.Error(err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, rocket)
+	encoder.writeRocket(ctx, rocket)
+}
+
+// GetStatsHandler handles GET requests to the /stats endpoint.
+// @Summary Get fleet statistics
+// @Description Returns aggregate statistics of the fleet (rockets by status, type and mission, speeds, explosion reasons) and message ingestion counters.
+// @Tags stats
+// @Produce json
+// @Success 200 {object} model.FleetStats "Fleet-wide statistics"
+// @Router /stats [get]
+func (c *RocketController) GetStatsHandler(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.service.GetStats())
 }
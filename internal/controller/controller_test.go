@@ -4,15 +4,21 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/seansa/rocket-challenge/internal/queue"
+	"github.com/seansa/rocket-challenge/internal/ratelimit"
+	"github.com/seansa/rocket-challenge/internal/service"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // === MOCKS === //
@@ -41,26 +47,89 @@ func (m *MockRocketService) GetAllRocketStates() ([]model.Rocket, error) {
 	return args.Get(0).([]model.Rocket), args.Error(1)
 }
 
+func (m *MockRocketService) ForEachRocketState(fn func(model.Rocket) error) error {
+	args := m.Called()
+	if rockets, ok := args.Get(0).([]model.Rocket); ok {
+		for _, rocket := range rockets {
+			if err := fn(rocket); err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockRocketService) GetStats() model.FleetStats {
+	args := m.Called()
+	return args.Get(0).(model.FleetStats)
+}
+
+func (m *MockRocketService) Events() *service.Publisher {
+	args := m.Called()
+	return args.Get(0).(*service.Publisher)
+}
+
+func (m *MockRocketService) Ping() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockRocketService) CheckContact(lostAfter time.Duration) (int, error) {
+	args := m.Called(lostAfter)
+	return args.Int(0), args.Error(1)
+}
+
+type MockMessageVerifier struct {
+	mock.Mock
+}
+
+func (m *MockMessageVerifier) Verify(channel, header string, body []byte) error {
+	args := m.Called(channel, header, body)
+	return args.Error(0)
+}
+
 // === END MOCKS === //
 
-func setupRouter(mockService *MockRocketService, messageChannel chan model.IncomingMessage) *gin.Engine {
+func setupRouter(mockService *MockRocketService, q queue.Queue) *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	r := gin.Default()
 
 	r.RedirectTrailingSlash = false
+	r.Use(RequestID())
+	r.Use(ErrorHandler())
 
-	controller := NewRocketController(mockService, messageChannel)
+	controller := NewRocketController(mockService, q)
+	controller.SetReceipts(service.NewReceipts(100, time.Hour))
 	r.POST("/messages", controller.MessageHandler)
+	r.GET("/messages/:id", controller.GetMessageReceiptHandler)
 	r.GET("/rockets", controller.GetAllRocketsHandler)
+	r.GET("/rockets/stream", controller.StreamRocketsHandler)
 	r.GET("/rockets/:channel", controller.GetRocketStateHandler)
+	r.GET("/rockets/:channel/stream", controller.StreamRocketStateHandler)
+	r.GET("/stats", controller.GetStatsHandler)
+	r.GET("/ws", controller.WebSocketHandler)
 	return r
 }
 
+// assertProblem checks that the response is an RFC 7807 problem with the given status and code.
+func assertProblem(t *testing.T, w *httptest.ResponseRecorder, status int, code string) model.Problem {
+	t.Helper()
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+
+	var problem model.Problem
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, status, problem.Status)
+	assert.Equal(t, code, problem.Code)
+	assert.NotEmpty(t, problem.Title)
+	assert.NotEmpty(t, problem.Type)
+	return problem
+}
+
 // TestMessageHandler_Success tests successful message handling by sending to channel.
 func TestMessageHandler_Success(t *testing.T) {
 	mockService := new(MockRocketService)
-	testMessageChannel := make(chan model.IncomingMessage, 1)
-	router := setupRouter(mockService, testMessageChannel)
+	testQueue := queue.NewMemory(1, queue.Lanes{})
+	router := setupRouter(mockService, testQueue)
 
 	testMessage := model.IncomingMessage{
 		Metadata: model.Metadata{
@@ -83,7 +152,8 @@ func TestMessageHandler_Success(t *testing.T) {
 	assert.Contains(t, w.Body.String(), `"channel":"193270a9-c9cf-404a-8f83-838e71d9ae67"`)
 
 	select {
-	case receivedMsg := <-testMessageChannel:
+	case delivery := <-testQueue.Deliveries():
+		receivedMsg := delivery.Message
 		assert.Equal(t, testMessage.Metadata.Channel, receivedMsg.Metadata.Channel)
 		assert.Equal(t, testMessage.Metadata.MessageNumber, receivedMsg.Metadata.MessageNumber)
 		expectedMsgBytes, _ := json.Marshal(testMessage.Message)
@@ -94,14 +164,14 @@ func TestMessageHandler_Success(t *testing.T) {
 	}
 
 	mockService.AssertNotCalled(t, "ProcessMessage")
-	close(testMessageChannel)
+	testQueue.Close()
 }
 
 // TestMessageHandler_InvalidJSON tests an invalid JSON payload.
 func TestMessageHandler_InvalidJSON(t *testing.T) {
 	mockService := new(MockRocketService)
-	testMessageChannel := make(chan model.IncomingMessage, 1)
-	router := setupRouter(mockService, testMessageChannel)
+	testQueue := queue.NewMemory(1, queue.Lanes{})
+	router := setupRouter(mockService, testQueue)
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("POST", "/messages", bytes.NewBuffer([]byte(`{"invalid json`)))
@@ -109,22 +179,22 @@ func TestMessageHandler_InvalidJSON(t *testing.T) {
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusBadRequest, w.Code)
-	assert.Contains(t, w.Body.String(), `"error":"Invalid JSON or empty request body"`)
+	assertProblem(t, w, http.StatusBadRequest, CodeInvalidPayload)
 
 	select {
-	case <-testMessageChannel:
+	case <-testQueue.Deliveries():
 		t.Fatal("Unexpected message received on channel")
 	default:
 	}
 	mockService.AssertNotCalled(t, "ProcessMessage")
-	close(testMessageChannel)
+	testQueue.Close()
 }
 
 // TestMessageHandler_MissingMetadata tests missing essential metadata.
 func TestMessageHandler_MissingMetadata(t *testing.T) {
 	mockService := new(MockRocketService)
-	testMessageChannel := make(chan model.IncomingMessage, 1)
-	router := setupRouter(mockService, testMessageChannel)
+	testQueue := queue.NewMemory(1, queue.Lanes{})
+	router := setupRouter(mockService, testQueue)
 
 	testMessage := model.IncomingMessage{
 		Metadata: model.Metadata{
@@ -143,22 +213,22 @@ func TestMessageHandler_MissingMetadata(t *testing.T) {
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusBadRequest, w.Code)
-	assert.Contains(t, w.Body.String(), `"error":"Invalid JSON or empty request body"`)
+	assertProblem(t, w, http.StatusBadRequest, CodeInvalidPayload)
 
 	select {
-	case <-testMessageChannel:
+	case <-testQueue.Deliveries():
 		t.Fatal("Unexpected message received on channel")
 	default:
 	}
 	mockService.AssertNotCalled(t, "ProcessMessage")
-	close(testMessageChannel)
+	testQueue.Close()
 }
 
 // TestMessageHandler_QueueFull tests when the message channel is full.
 func TestMessageHandler_QueueFull(t *testing.T) {
 	mockService := new(MockRocketService)
-	testMessageChannel := make(chan model.IncomingMessage)
-	router := setupRouter(mockService, testMessageChannel)
+	testQueue := queue.NewMemory(0, queue.Lanes{})
+	router := setupRouter(mockService, testQueue)
 
 	testMessage := model.IncomingMessage{
 		Metadata: model.Metadata{
@@ -177,30 +247,163 @@ func TestMessageHandler_QueueFull(t *testing.T) {
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusServiceUnavailable, w.Code) // Expect 503 Service Unavailable
-	assert.Contains(t, w.Body.String(), `"error":"Message queue full, please try again later"`)
+	assertProblem(t, w, http.StatusServiceUnavailable, CodeQueueFull)
 
 	select { // Ensure no message was sent to the channel
-	case <-testMessageChannel:
+	case <-testQueue.Deliveries():
 		t.Fatal("Unexpected message received on channel")
 	default:
 		// No message, as expected
 	}
 	mockService.AssertNotCalled(t, "ProcessMessage")
-	close(testMessageChannel)
+	testQueue.Close()
+}
+
+// fixedDrainRate is a DrainRater reporting a constant rate.
+type fixedDrainRate float64
+
+func (r fixedDrainRate) DrainRate() float64 { return float64(r) }
+
+// TestMessageHandler_Backpressure tests that a full queue is waited on for the
+// enqueue wait, and that the 503 after it tells the client when to retry.
+func TestMessageHandler_Backpressure(t *testing.T) {
+	testQueue := queue.NewMemory(1, queue.Lanes{})
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler())
+	controller := NewRocketController(new(MockRocketService), testQueue)
+	controller.SetBackpressure(200*time.Millisecond, fixedDrainRate(0.4))
+	router.POST("/messages", controller.MessageHandler)
+
+	post := func(number int) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"metadata":{"channel":"rocket-a","messageNumber":%d,"messageTime":"2022-02-02T19:39:05.86337+01:00","messageType":"RocketSpeedIncreased"},"message":{"by":1}}`, number)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader(body)))
+		return w
+	}
+	require.Equal(t, http.StatusAccepted, post(1).Code)
+
+	// A worker making room within the wait gets the message accepted.
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		<-testQueue.Deliveries()
+	}()
+	require.Equal(t, http.StatusAccepted, post(2).Code)
+
+	start := time.Now()
+	w := post(3)
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+	problem := assertProblem(t, w, http.StatusServiceUnavailable, CodeQueueFull)
+	assert.Equal(t, "3", w.Header().Get("Retry-After"), "1 queued message at 0.4/s takes 2.5s")
+	require.NotNil(t, problem.Queue)
+	assert.Equal(t, model.QueueStatus{Depth: 1, Capacity: 1, DrainRate: 0.4}, *problem.Queue)
+	assert.Equal(t, 1, testQueue.Len())
+}
+
+// TestQueueRetryAfter tests the bounds of the Retry-After estimate.
+func TestQueueRetryAfter(t *testing.T) {
+	assert.Equal(t, time.Minute, queueRetryAfter(model.QueueStatus{Depth: 10}), "nothing drained")
+	assert.Equal(t, time.Second, queueRetryAfter(model.QueueStatus{Depth: 10, DrainRate: 1000}))
+	assert.Equal(t, 5*time.Second, queueRetryAfter(model.QueueStatus{Depth: 500, DrainRate: 100}))
+	assert.Equal(t, time.Minute, queueRetryAfter(model.QueueStatus{Depth: 1000, DrainRate: 1}))
+}
+
+// TestMessageHandler_Signature tests that messages the verifier rejects get a
+// 401 and are never enqueued.
+func TestMessageHandler_Signature(t *testing.T) {
+	mockService := new(MockRocketService)
+	mockVerifier := new(MockMessageVerifier)
+	testQueue := queue.NewMemory(1, queue.Lanes{})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler())
+	controller := NewRocketController(mockService, testQueue)
+	controller.SetMessageVerifier(mockVerifier)
+	router.POST("/messages", controller.MessageHandler)
+
+	body := []byte(`{"metadata":{"channel":"rocket-a","messageNumber":1,"messageTime":"2022-02-02T19:39:05.86337+01:00","messageType":"RocketExploded"},"message":{"reason":"forged"}}`)
+	mockVerifier.On("Verify", "rocket-a", "t=1,v1=bad", body).Return(fmt.Errorf("channel rocket-a: %w: signature mismatch", model.ErrUnauthorized)).Once()
+	mockVerifier.On("Verify", "rocket-a", "t=1,v1=good", body).Return(nil).Once()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/messages", bytes.NewBuffer(body))
+	req.Header.Set("X-Signature", "t=1,v1=bad")
+	router.ServeHTTP(w, req)
+
+	problem := assertProblem(t, w, http.StatusUnauthorized, CodeUnauthorized)
+	assert.Contains(t, problem.Detail, "signature mismatch")
+	assert.Zero(t, testQueue.Len())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/messages", bytes.NewBuffer(body))
+	req.Header.Set("X-Signature", "t=1,v1=good")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "rocket-a", (<-testQueue.Deliveries()).Message.Metadata.Channel)
+	mockVerifier.AssertExpectations(t)
+}
+
+// TestMessageHandler_RateLimited tests 429 responses with Retry-After for the
+// channel and client limits.
+func TestMessageHandler_RateLimited(t *testing.T) {
+	mockService := new(MockRocketService)
+	testQueue := queue.NewMemory(10, queue.Lanes{})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler())
+	controller := NewRocketController(mockService, testQueue)
+	controller.SetRateLimiters(
+		ratelimit.NewLimiter("channel", ratelimit.Limit{Rate: 0.01, Burst: 1}, map[string]ratelimit.Limit{"unlimited": {}}),
+		ratelimit.NewLimiter("client", ratelimit.Limit{Rate: 0.5, Burst: 3}, nil),
+	)
+	controller.SetAPIKeys(map[string]string{"ops": "key-1"})
+	router.POST("/messages", controller.MessageHandler)
+
+	post := func(channel, apiKey string) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"metadata":{"channel":%q,"messageNumber":1,"messageTime":"2022-02-02T19:39:05.86337+01:00","messageType":"RocketSpeedIncreased"},"message":{"by":1}}`, channel)
+		req, _ := http.NewRequest("POST", "/messages", bytes.NewBufferString(body))
+		if apiKey != "" {
+			req.Header.Set(APIKeyHeader, apiKey)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusAccepted, post("rocket-a", "").Code)
+	w := post("rocket-a", "")
+	problem := assertProblem(t, w, http.StatusTooManyRequests, CodeRateLimited)
+	assert.Contains(t, problem.Detail, "channel rocket-a")
+	assert.Equal(t, "100", w.Header().Get("Retry-After"))
+
+	// An override lifts the channel limit; the client limit still applies.
+	assert.Equal(t, http.StatusAccepted, post("unlimited", "").Code)
+	w = post("unlimited", "")
+	problem = assertProblem(t, w, http.StatusTooManyRequests, CodeRateLimited)
+	assert.Contains(t, problem.Detail, "client")
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+
+	// Unknown API keys are limited by IP, known ones separately from it.
+	assert.Equal(t, http.StatusTooManyRequests, post("unlimited", "made-up").Code)
+	assert.Equal(t, http.StatusAccepted, post("unlimited", "key-1").Code)
+	assert.Equal(t, 3, testQueue.Len())
 }
 
 // TestGetAllRocketsHandler_Success tests successful retrieval of all rockets.
 func TestGetAllRocketsHandler_Success(t *testing.T) {
 	mockService := new(MockRocketService)
-	testMessageChannel := make(chan model.IncomingMessage)
-	router := setupRouter(mockService, testMessageChannel)
+	testQueue := queue.NewMemory(0, queue.Lanes{})
+	router := setupRouter(mockService, testQueue)
 
 	expectedRockets := []model.Rocket{
 		{Channel: "193270a9-c9cf-404a-8f83-838e71d9ae67", Type: "Falcon-9", Speed: 500, Mission: "ARTEMIS"},
 		{Channel: "193270a9-c9cf-404a-8f83-838e71d9ae68", Type: "Falcon-8", Speed: 1500, Mission: "ARTEMIS2"},
 	}
 
-	mockService.On("GetAllRocketStates").Return(expectedRockets, nil)
+	mockService.On("ForEachRocketState").Return(expectedRockets, nil)
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/rockets", nil)
@@ -213,32 +416,33 @@ func TestGetAllRocketsHandler_Success(t *testing.T) {
 	assert.Len(t, actualRockets, 2)
 	assert.Equal(t, expectedRockets[0].Channel, actualRockets[0].Channel)
 	mockService.AssertExpectations(t)
-	close(testMessageChannel)
+	testQueue.Close()
 }
 
 // TestGetAllRocketsHandler_ServiceError tests when the GetAll service returns an error.
 func TestGetAllRocketsHandler_ServiceError(t *testing.T) {
 	mockService := new(MockRocketService)
-	testMessageChannel := make(chan model.IncomingMessage)
-	router := setupRouter(mockService, testMessageChannel)
+	testQueue := queue.NewMemory(0, queue.Lanes{})
+	router := setupRouter(mockService, testQueue)
 
-	mockService.On("GetAllRocketStates").Return(nil, errors.New("foo bar error"))
+	mockService.On("ForEachRocketState").Return(nil, errors.New("foo bar error"))
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/rockets", nil)
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
-	assert.Contains(t, w.Body.String(), `"error":"Error while fetching rockets"`)
+	problem := assertProblem(t, w, http.StatusInternalServerError, CodeInternal)
+	assert.Contains(t, problem.Detail, "foo bar error")
 	mockService.AssertExpectations(t)
-	close(testMessageChannel)
+	testQueue.Close()
 }
 
 // TestGetRocketStateHandler_Success tests successful retrieval of a single rocket.
 func TestGetRocketStateHandler_Success(t *testing.T) {
 	mockService := new(MockRocketService)
-	testMessageChannel := make(chan model.IncomingMessage)
-	router := setupRouter(mockService, testMessageChannel)
+	testQueue := queue.NewMemory(0, queue.Lanes{})
+	router := setupRouter(mockService, testQueue)
 
 	expectedRocket := model.Rocket{Channel: "193270a9-c9cf-404a-8f83-838e71d9ae67", Type: "Falcon-9", Speed: 500, Mission: "ARTEMIS"}
 
@@ -254,32 +458,34 @@ func TestGetRocketStateHandler_Success(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, expectedRocket.Channel, actualRocket.Channel)
 	mockService.AssertExpectations(t)
-	close(testMessageChannel)
+	testQueue.Close()
 }
 
 // TestGetRocketStateHandler_NotFound tests when the rocket is not found.
 func TestGetRocketStateHandler_NotFound(t *testing.T) {
 	mockService := new(MockRocketService)
-	testMessageChannel := make(chan model.IncomingMessage)
-	router := setupRouter(mockService, testMessageChannel)
+	testQueue := queue.NewMemory(0, queue.Lanes{})
+	router := setupRouter(mockService, testQueue)
 
-	mockService.On("GetRocketState", "non-existent-channel").Return(nil, errors.New("rocket with channel non-existent-channel not found"))
+	mockService.On("GetRocketState", "non-existent-channel").Return(nil, fmt.Errorf("rocket non-existent-channel: %w", model.ErrNotFound))
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/rockets/non-existent-channel", nil)
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusNotFound, w.Code)
-	assert.Contains(t, w.Body.String(), `"error":"Rocket not found"`)
+	problem := assertProblem(t, w, http.StatusNotFound, CodeNotFound)
+	assert.Equal(t, "/rockets/non-existent-channel", problem.Instance)
+	assert.Contains(t, problem.Detail, "non-existent-channel")
 	mockService.AssertExpectations(t)
-	close(testMessageChannel)
+	testQueue.Close()
 }
 
 // TestGetRocketStateHandler_ServiceError tests when the Get service returns a generic error.
 func TestGetRocketStateHandler_ServiceError(t *testing.T) {
 	mockService := new(MockRocketService)
-	testMessageChannel := make(chan model.IncomingMessage)
-	router := setupRouter(mockService, testMessageChannel)
+	testQueue := queue.NewMemory(0, queue.Lanes{})
+	router := setupRouter(mockService, testQueue)
 
 	mockService.On("GetRocketState", "errChannel").Return(nil, errors.New("internal repository error"))
 
@@ -288,7 +494,205 @@ func TestGetRocketStateHandler_ServiceError(t *testing.T) {
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
-	assert.Contains(t, w.Body.String(), `"error":"Error while fetching rocket errChannel"`)
+	assertProblem(t, w, http.StatusInternalServerError, CodeInternal)
 	mockService.AssertExpectations(t)
-	close(testMessageChannel)
+	testQueue.Close()
+}
+
+// TestNewProblem_ConflictMapping tests that wrapped conflict errors map to 409.
+func TestNewProblem_ConflictMapping(t *testing.T) {
+	problem := NewProblem(fmt.Errorf("saving rocket: %w", model.ErrConflict), "/rockets/abc")
+
+	assert.Equal(t, http.StatusConflict, problem.Status)
+	assert.Equal(t, CodeConflict, problem.Code)
+	assert.Equal(t, "/problems/conflict", problem.Type)
+	assert.Equal(t, "/rockets/abc", problem.Instance)
+}
+
+// TestGetStatsHandler_Success tests that fleet statistics are returned as JSON.
+func TestGetStatsHandler_Success(t *testing.T) {
+	mockService := new(MockRocketService)
+	testQueue := queue.NewMemory(0, queue.Lanes{})
+	router := setupRouter(mockService, testQueue)
+
+	expectedStats := model.FleetStats{
+		TotalRockets: 2,
+		ByStatus:     map[string]int{model.StatusInFlight: 1, model.StatusExploded: 1},
+		ByType:       map[string]int{"Falcon-9": 2},
+		AverageSpeed: 250,
+		MaxSpeed:     500,
+		Ingestion:    model.IngestionStats{Processed: 7, IgnoredOld: 1},
+	}
+	mockService.On("GetStats").Return(expectedStats)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/stats", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var actualStats model.FleetStats
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &actualStats))
+	assert.Equal(t, expectedStats.TotalRockets, actualStats.TotalRockets)
+	assert.Equal(t, expectedStats.ByStatus, actualStats.ByStatus)
+	assert.Equal(t, expectedStats.Ingestion, actualStats.Ingestion)
+	mockService.AssertExpectations(t)
+	testQueue.Close()
+}
+
+// TestDecodeMessage tests that DecodeMessage applies the same validation as MessageHandler.
+func TestDecodeMessage(t *testing.T) {
+	msg, err := DecodeMessage([]byte(`{"metadata":{"channel":"abc","messageNumber":1,"messageTime":"2022-02-02T19:39:05.86337+01:00","messageType":"RocketLaunched"},"message":{"type":"Falcon-9"}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", msg.Metadata.Channel)
+
+	_, err = DecodeMessage([]byte(`{"metadata":{"messageNumber":1,"messageTime":"2022-02-02T19:39:05.86337+01:00","messageType":"RocketLaunched"}}`))
+	assert.ErrorIs(t, err, model.ErrInvalidPayload)
+
+	_, err = DecodeMessage([]byte(`{"metadata":`))
+	assert.ErrorIs(t, err, model.ErrInvalidPayload)
+
+	_, err = DecodeMessage([]byte(`{"metadata":{"channel":"stream","messageNumber":1,"messageTime":"2022-02-02T19:39:05.86337+01:00","messageType":"RocketLaunched"}}`))
+	assert.ErrorIs(t, err, model.ErrInvalidPayload, "stream is reserved for the fleet stream")
+}
+
+// TestGetAllRocketsHandler_Formats tests content negotiation and sparse fieldsets on /rockets.
+func TestGetAllRocketsHandler_Formats(t *testing.T) {
+	lostSince := time.Date(2022, 2, 2, 19, 44, 5, 0, time.UTC)
+	rockets := []model.Rocket{
+		{Channel: "rocket-a", Type: "Falcon-9", Speed: 500, Mission: "ARTEMIS", ContactLost: true, ContactLostSince: &lostSince},
+		{Channel: "rocket-b", Type: "Falcon-Heavy", Speed: 0, Mission: model.Aborted, Exploded: true, ExplosionReason: "ENGINE, FAILURE"},
+	}
+
+	tests := []struct {
+		name        string
+		url         string
+		accept      string
+		contentType string
+		body        string
+	}{
+		{"csv via accept", "/rockets", "text/csv", "text/csv; charset=utf-8",
+			"channel,type,speed,mission,exploded,explosionReason,contactLost,contactLostSince\nrocket-a,Falcon-9,500,ARTEMIS,false,,true,2022-02-02T19:44:05Z\nrocket-b,Falcon-Heavy,0,ABORTED,true,\"ENGINE, FAILURE\",false,\n"},
+		{"csv with fields", "/rockets?format=csv&fields=channel,speed", "", "text/csv; charset=utf-8",
+			"channel,speed\nrocket-a,500\nrocket-b,0\n"},
+		{"ndjson with fields", "/rockets?fields=channel,exploded", "application/x-ndjson", "application/x-ndjson",
+			"{\"channel\":\"rocket-a\",\"exploded\":false}\n{\"channel\":\"rocket-b\",\"exploded\":true}\n"},
+		{"json with fields", "/rockets?format=json&fields=speed,channel", "text/csv", "application/json; charset=utf-8",
+			`[{"speed":500,"channel":"rocket-a"},{"speed":0,"channel":"rocket-b"}]`},
+		{"json by default", "/rockets?fields=channel", "*/*", "application/json; charset=utf-8",
+			`[{"channel":"rocket-a"},{"channel":"rocket-b"}]`},
+		{"contact lost", "/rockets?contactLost=true&fields=channel,contactLostSince", "", "application/json; charset=utf-8",
+			`[{"channel":"rocket-a","contactLostSince":"2022-02-02T19:44:05Z"}]`},
+		{"contact kept", "/rockets?contactLost=false&fields=channel,contactLost", "", "application/json; charset=utf-8",
+			`[{"channel":"rocket-b","contactLost":false}]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockRocketService)
+			router := setupRouter(mockService, queue.NewMemory(0, queue.Lanes{}))
+			mockService.On("ForEachRocketState").Return(rockets, nil)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tt.url, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tt.contentType, w.Header().Get("Content-Type"))
+			assert.Equal(t, tt.body, w.Body.String())
+		})
+	}
+}
+
+// TestGetAllRocketsHandler_EmptyJSON tests that an empty fleet is an empty JSON array.
+func TestGetAllRocketsHandler_EmptyJSON(t *testing.T) {
+	mockService := new(MockRocketService)
+	router := setupRouter(mockService, queue.NewMemory(0, queue.Lanes{}))
+	mockService.On("ForEachRocketState").Return([]model.Rocket{}, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/rockets", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "[]", w.Body.String())
+}
+
+// TestGetAllRocketsHandler_NegotiationErrors tests unsupported formats, fields and Accept headers.
+func TestGetAllRocketsHandler_NegotiationErrors(t *testing.T) {
+	mockService := new(MockRocketService)
+	router := setupRouter(mockService, queue.NewMemory(0, queue.Lanes{}))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/rockets?format=xml", nil)
+	router.ServeHTTP(w, req)
+	assertProblem(t, w, http.StatusBadRequest, CodeInvalidPayload)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/rockets?fields=channel,color", nil)
+	router.ServeHTTP(w, req)
+	assertProblem(t, w, http.StatusBadRequest, CodeInvalidPayload)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/rockets", nil)
+	req.Header.Set("Accept", "application/xml")
+	router.ServeHTTP(w, req)
+	assertProblem(t, w, http.StatusNotAcceptable, CodeNotAcceptable)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/rockets?contactLost=maybe", nil)
+	router.ServeHTTP(w, req)
+	assertProblem(t, w, http.StatusBadRequest, CodeInvalidPayload)
+
+	mockService.AssertNotCalled(t, "ForEachRocketState")
+}
+
+// TestGetRocketStateHandler_CSV tests a single rocket rendered as CSV.
+func TestGetRocketStateHandler_CSV(t *testing.T) {
+	mockService := new(MockRocketService)
+	router := setupRouter(mockService, queue.NewMemory(0, queue.Lanes{}))
+	mockService.On("GetRocketState", "rocket-a").Return(model.Rocket{Channel: "rocket-a", Speed: 500, Mission: "ARTEMIS"}, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/rockets/rocket-a?format=csv&fields=channel,speed,mission", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "channel,speed,mission\nrocket-a,500,ARTEMIS\n", w.Body.String())
+	mockService.AssertExpectations(t)
+}
+
+// TestGetMessageReceiptHandler tests that an accepted message can be looked up
+// by the receipt in its 202 response.
+func TestGetMessageReceiptHandler(t *testing.T) {
+	testQueue := queue.NewMemory(1, queue.Lanes{})
+	router := setupRouter(new(MockRocketService), testQueue)
+
+	w := httptest.NewRecorder()
+	body := `{"metadata":{"channel":"rocket-a","messageNumber":3,"messageTime":"2022-02-02T19:39:05Z","messageType":"RocketSpeedIncreased"},"message":{"by":100}}`
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/messages", bytes.NewBufferString(body)))
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	var accepted map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &accepted))
+	require.NotEmpty(t, accepted["receiptId"])
+	assert.Equal(t, "/messages/"+accepted["receiptId"], w.Header().Get("Location"))
+	assert.Equal(t, accepted["receiptId"], (<-testQueue.Deliveries()).Message.ReceiptID)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/messages/"+accepted["receiptId"], nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var receipt model.Receipt
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &receipt))
+	assert.Equal(t, model.ReceiptQueued, receipt.Status)
+	assert.Equal(t, "rocket-a", receipt.Channel)
+	assert.Equal(t, 3, receipt.MessageNumber)
+	assert.Equal(t, accepted["requestId"], receipt.RequestID)
+	assert.False(t, receipt.QueuedAt.IsZero())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/messages/unknown", nil))
+	assertProblem(t, w, http.StatusNotFound, CodeNotFound)
 }
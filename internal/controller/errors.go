@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seansa/rocket-challenge/internal/model"
+)
+
+const problemContentType = "application/problem+json"
+
+// Stable problem codes returned in the "code" member of every error response.
+// Clients may switch on these; they must never change once published.
+const (
+	CodeNotFound       = "NOT_FOUND"
+	CodeInvalidPayload = "INVALID_PAYLOAD"
+	CodeConflict       = "CONFLICT"
+	CodeQueueFull      = "QUEUE_FULL"
+	CodeNotAcceptable  = "NOT_ACCEPTABLE"
+	CodeUnauthorized   = "UNAUTHORIZED"
+	CodeRateLimited    = "RATE_LIMITED"
+	CodeInternal       = "INTERNAL_ERROR"
+)
+
+type problemKind struct {
+	err    error
+	status int
+	code   string
+	title  string
+}
+
+// problemKinds maps domain errors to their HTTP representation. The first
+// entry matching the error chain (errors.Is) wins.
+var problemKinds = []problemKind{
+	{model.ErrNotFound, http.StatusNotFound, CodeNotFound, "Resource not found"},
+	{model.ErrInvalidPayload, http.StatusBadRequest, CodeInvalidPayload, "Invalid request payload"},
+	{model.ErrConflict, http.StatusConflict, CodeConflict, "Resource conflict"},
+	{model.ErrRateLimited, http.StatusTooManyRequests, CodeRateLimited, "Too many requests, please slow down"},
+	{model.ErrQueueFull, http.StatusServiceUnavailable, CodeQueueFull, "Message queue full, please try again later"},
+	{model.ErrNotAcceptable, http.StatusNotAcceptable, CodeNotAcceptable, "Requested representation not available"},
+	{model.ErrUnauthorized, http.StatusUnauthorized, CodeUnauthorized, "Missing or invalid credentials"},
+}
+
+var internalProblemKind = problemKind{nil, http.StatusInternalServerError, CodeInternal, "Internal server error"}
+
+// retryAfterError is implemented by errors that know when the client may try
+// again; ErrorHandler turns it into a Retry-After header.
+type retryAfterError interface {
+	RetryAfter() time.Duration
+}
+
+// queueStatusError is implemented by errors that describe the message queue;
+// ErrorHandler adds the description to the problem body.
+type queueStatusError interface {
+	QueueStatus() model.QueueStatus
+}
+
+// ErrorHandler returns a middleware that renders the last error attached to the
+// context (via ctx.Error) as an RFC 7807 problem+json response. Handlers only
+// need to attach the error and return.
+func ErrorHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Next()
+
+		if len(ctx.Errors) == 0 || ctx.Writer.Written() {
+			return
+		}
+
+		err := ctx.Errors.Last().Err
+		problem := NewProblem(err, ctx.Request.URL.Path)
+		if problem.Status >= http.StatusInternalServerError {
+			slog.ErrorContext(ctx.Request.Context(), "Request failed", "method", ctx.Request.Method, "path", ctx.Request.URL.Path, "error", err)
+		}
+
+		var retryable retryAfterError
+		if errors.As(err, &retryable) {
+			// Retry-After is in whole seconds; round up so clients never retry early.
+			seconds := int(math.Ceil(retryable.RetryAfter().Seconds()))
+			ctx.Header("Retry-After", strconv.Itoa(max(seconds, 1)))
+		}
+		var queueStatus queueStatusError
+		if errors.As(err, &queueStatus) {
+			status := queueStatus.QueueStatus()
+			problem.Queue = &status
+		}
+
+		ctx.Header("Content-Type", problemContentType)
+		ctx.JSON(problem.Status, problem)
+	}
+}
+
+// NewProblem builds the problem details for err, resolving its status and code
+// from the domain error it wraps.
+func NewProblem(err error, instance string) model.Problem {
+	kind := internalProblemKind
+	for _, k := range problemKinds {
+		if errors.Is(err, k.err) {
+			kind = k
+			break
+		}
+	}
+
+	return model.Problem{
+		Type:     "/problems/" + strings.ToLower(strings.ReplaceAll(kind.code, "_", "-")),
+		Title:    kind.title,
+		Status:   kind.status,
+		Detail:   err.Error(),
+		Instance: instance,
+		Code:     kind.code,
+	}
+}
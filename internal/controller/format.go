@@ -0,0 +1,257 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seansa/rocket-challenge/internal/model"
+)
+
+// Representations supported by the rocket query endpoints.
+const (
+	FormatJSON   = "json"
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+
+	MIMECSV    = "text/csv"
+	MIMENDJSON = "application/x-ndjson"
+)
+
+var formatContentTypes = map[string]string{
+	FormatJSON:   "application/json; charset=utf-8",
+	FormatCSV:    MIMECSV + "; charset=utf-8",
+	FormatNDJSON: MIMENDJSON,
+}
+
+// rocketField is a field that can be selected with ?fields=. Names match the
+// JSON representation of model.Rocket.
+type rocketField struct {
+	name  string
+	value func(r model.Rocket) any
+}
+
+var rocketFields = []rocketField{
+	{"channel", func(r model.Rocket) any { return r.Channel }},
+	{"type", func(r model.Rocket) any { return r.Type }},
+	{"speed", func(r model.Rocket) any { return r.Speed }},
+	{"mission", func(r model.Rocket) any { return r.Mission }},
+	{"exploded", func(r model.Rocket) any { return r.Exploded }},
+	{"explosionReason", func(r model.Rocket) any { return r.ExplosionReason }},
+	{"contactLost", func(r model.Rocket) any { return r.ContactLost }},
+	{"contactLostSince", func(r model.Rocket) any { return r.ContactLostSince }},
+}
+
+// rocketEncoder writes rockets in the representation negotiated for a request.
+type rocketEncoder struct {
+	format string
+	// fields is nil when the full representation was requested.
+	fields []rocketField
+}
+
+// negotiateRocketEncoder picks the representation from ?format= (which wins)
+// or the Accept header, and the sparse fieldset from ?fields=.
+func negotiateRocketEncoder(ctx *gin.Context) (*rocketEncoder, error) {
+	encoder := &rocketEncoder{}
+
+	switch format := strings.ToLower(ctx.Query("format")); format {
+	case FormatJSON, FormatCSV, FormatNDJSON:
+		encoder.format = format
+	case "":
+		switch ctx.NegotiateFormat(gin.MIMEJSON, MIMECSV, MIMENDJSON) {
+		case gin.MIMEJSON:
+			encoder.format = FormatJSON
+		case MIMECSV:
+			encoder.format = FormatCSV
+		case MIMENDJSON:
+			encoder.format = FormatNDJSON
+		default:
+			return nil, fmt.Errorf("accept %q: supported types are %s, %s and %s: %w",
+				ctx.GetHeader("Accept"), gin.MIMEJSON, MIMECSV, MIMENDJSON, model.ErrNotAcceptable)
+		}
+	default:
+		return nil, fmt.Errorf("unknown format %q (use json, csv or ndjson): %w", format, model.ErrInvalidPayload)
+	}
+
+	if fields := ctx.Query("fields"); fields != "" {
+		for _, name := range strings.Split(fields, ",") {
+			field, ok := lookupRocketField(strings.TrimSpace(name))
+			if !ok {
+				return nil, fmt.Errorf("unknown field %q: %w", name, model.ErrInvalidPayload)
+			}
+			encoder.fields = append(encoder.fields, field)
+		}
+	}
+
+	return encoder, nil
+}
+
+func lookupRocketField(name string) (rocketField, bool) {
+	for _, field := range rocketFields {
+		if field.name == name {
+			return field, true
+		}
+	}
+	return rocketField{}, false
+}
+
+func (e *rocketEncoder) contentType() string {
+	return formatContentTypes[e.format]
+}
+
+// columns returns the selected fields, or every field for full CSV output.
+func (e *rocketEncoder) columns() []rocketField {
+	if e.fields == nil {
+		return rocketFields
+	}
+	return e.fields
+}
+
+// encodeJSON marshals a rocket, restricted to the selected fields if any,
+// keeping the fields in the order they were requested.
+func (e *rocketEncoder) encodeJSON(rocket model.Rocket) ([]byte, error) {
+	if e.fields == nil {
+		return json.Marshal(rocket)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range e.fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		value, err := json.Marshal(field.value(rocket))
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString(strconv.Quote(field.name))
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (e *rocketEncoder) csvRecord(rocket model.Rocket) []string {
+	columns := e.columns()
+	record := make([]string, len(columns))
+	for i, field := range columns {
+		switch value := field.value(rocket).(type) {
+		case *time.Time:
+			if value != nil {
+				record[i] = value.Format(time.RFC3339Nano)
+			}
+		default:
+			record[i] = fmt.Sprint(value)
+		}
+	}
+	return record
+}
+
+// writeRocket writes a single rocket as the whole response body.
+func (e *rocketEncoder) writeRocket(ctx *gin.Context, rocket model.Rocket) {
+	stream := e.stream(ctx, false)
+	if err := stream.write(rocket); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	stream.close()
+}
+
+// rocketStream writes rockets to the response as they are produced. Headers
+// are only sent with the first rocket, so an error before that can still be
+// reported as a problem response.
+type rocketStream struct {
+	ctx     *gin.Context
+	encoder *rocketEncoder
+	list    bool
+	started bool
+	count   int
+	csv     *csv.Writer
+}
+
+func (e *rocketEncoder) stream(ctx *gin.Context, list bool) *rocketStream {
+	return &rocketStream{ctx: ctx, encoder: e, list: list}
+}
+
+func (s *rocketStream) start() {
+	if s.started {
+		return
+	}
+	s.started = true
+	s.ctx.Header("Content-Type", s.encoder.contentType())
+	s.ctx.Status(http.StatusOK)
+
+	switch s.encoder.format {
+	case FormatCSV:
+		s.csv = csv.NewWriter(s.ctx.Writer)
+		columns := s.encoder.columns()
+		header := make([]string, len(columns))
+		for i, field := range columns {
+			header[i] = field.name
+		}
+		_ = s.csv.Write(header)
+	case FormatJSON:
+		if s.list {
+			_, _ = s.ctx.Writer.WriteString("[")
+		}
+	}
+}
+
+func (s *rocketStream) write(rocket model.Rocket) error {
+	s.start()
+
+	var err error
+	switch s.encoder.format {
+	case FormatCSV:
+		if err = s.csv.Write(s.encoder.csvRecord(rocket)); err == nil {
+			s.csv.Flush()
+			err = s.csv.Error()
+		}
+	default:
+		var data []byte
+		if data, err = s.encoder.encodeJSON(rocket); err != nil {
+			return err
+		}
+		if s.list && s.encoder.format == FormatJSON && s.count > 0 {
+			_, _ = s.ctx.Writer.WriteString(",")
+		}
+		if s.encoder.format == FormatNDJSON {
+			data = append(data, '\n')
+		}
+		_, err = s.ctx.Writer.Write(data)
+	}
+	s.count++
+	return err
+}
+
+// close terminates the body; it also sends the headers of an empty result.
+func (s *rocketStream) close() {
+	s.start()
+	switch s.encoder.format {
+	case FormatCSV:
+		s.csv.Flush()
+	case FormatJSON:
+		if s.list {
+			_, _ = s.ctx.Writer.WriteString("]")
+		}
+	}
+}
+
+// fail reports an error that happened while producing the rockets. Once the
+// body has started the status can no longer change, so the response is cut short.
+func (s *rocketStream) fail(err error) {
+	if !s.started {
+		_ = s.ctx.Error(err)
+		return
+	}
+	slog.ErrorContext(s.ctx.Request.Context(), "Streaming rockets failed", "rows", s.count, "error", err)
+	s.ctx.Abort()
+}
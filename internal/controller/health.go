@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/seansa/rocket-challenge/internal/queue"
+	"github.com/seansa/rocket-challenge/internal/service"
+)
+
+// DefaultQueueSaturation is the fraction of the message queue capacity at
+// which the service stops reporting ready.
+const DefaultQueueSaturation = 0.9
+
+// HealthController serves the orchestrator probes. Readiness fails before the
+// queue is full, so traffic is shifted away before MessageHandler has to
+// answer 503.
+type HealthController struct {
+	service      service.Service
+	heartbeats   *service.Heartbeats
+	queue        queue.Queue
+	saturation   float64
+	shuttingDown atomic.Bool
+}
+
+func NewHealthController(service service.Service, heartbeats *service.Heartbeats, q queue.Queue, saturation float64) *HealthController {
+	if saturation <= 0 || saturation > 1 {
+		saturation = DefaultQueueSaturation
+	}
+	return &HealthController{
+		service:    service,
+		heartbeats: heartbeats,
+		queue:      q,
+		saturation: saturation,
+	}
+}
+
+// SetShuttingDown makes readiness fail from now on.
+func (c *HealthController) SetShuttingDown() {
+	c.shuttingDown.Store(true)
+}
+
+// LivenessHandler handles GET requests to the /healthz endpoint.
+// @Summary Liveness probe
+// @Description Reports whether the process is alive and no message processing worker is wedged, i.e. has gone longer than the stall timeout without a heartbeat.
+// @Tags health
+// @Produce json
+// @Success 200 {object} model.HealthReport "Alive"
+// @Failure 503 {object} model.HealthReport "A worker is wedged or no worker is running"
+// @Router /healthz [get]
+func (c *HealthController) LivenessHandler(ctx *gin.Context) {
+	workers := c.heartbeats.Workers()
+
+	running, stalled := 0, 0
+	for _, w := range workers {
+		if w.State == model.WorkerStopped {
+			continue
+		}
+		running++
+		if w.Stalled {
+			stalled++
+		}
+	}
+
+	check := model.HealthCheck{Status: model.HealthOK, Detail: fmt.Sprintf("%d/%d workers healthy", running-stalled, len(workers))}
+	switch {
+	case stalled > 0:
+		check.Status = model.HealthFailing
+		check.Detail = fmt.Sprintf("%d/%d workers wedged", stalled, len(workers))
+	case running == 0:
+		check.Status = model.HealthFailing
+		check.Detail = "no worker is running"
+	}
+
+	report := newHealthReport(map[string]model.HealthCheck{"workers": check})
+	report.Workers = workers
+	writeHealthReport(ctx, report)
+}
+
+// ReadinessHandler handles GET requests to the /readyz endpoint.
+// @Summary Readiness probe
+// @Description Reports whether the service should receive traffic: the repository is reachable, the message queue is below its saturation threshold and the server is not shutting down.
+// @Tags health
+// @Produce json
+// @Success 200 {object} model.HealthReport "Ready"
+// @Failure 503 {object} model.HealthReport "Not ready"
+// @Router /readyz [get]
+func (c *HealthController) ReadinessHandler(ctx *gin.Context) {
+	checks := make(map[string]model.HealthCheck, 3)
+
+	checks["repository"] = model.HealthCheck{Status: model.HealthOK}
+	if err := c.service.Ping(); err != nil {
+		checks["repository"] = model.HealthCheck{Status: model.HealthFailing, Detail: err.Error()}
+	}
+
+	depth, capacity := c.queue.Len(), c.queue.Cap()
+	usage := 1.0
+	if capacity > 0 {
+		usage = float64(depth) / float64(capacity)
+	}
+	queue := model.HealthCheck{Status: model.HealthOK, Detail: fmt.Sprintf("%d/%d messages queued (%.1f%%)", depth, capacity, usage*100)}
+	if usage >= c.saturation {
+		queue.Status = model.HealthFailing
+	}
+	checks["queue"] = queue
+
+	checks["shutdown"] = model.HealthCheck{Status: model.HealthOK}
+	if c.shuttingDown.Load() {
+		checks["shutdown"] = model.HealthCheck{Status: model.HealthFailing, Detail: "server is shutting down"}
+	}
+
+	writeHealthReport(ctx, newHealthReport(checks))
+}
+
+func newHealthReport(checks map[string]model.HealthCheck) model.HealthReport {
+	report := model.HealthReport{Status: model.HealthOK, Checks: checks}
+	for _, check := range checks {
+		if check.Status != model.HealthOK {
+			report.Status = model.HealthFailing
+		}
+	}
+	return report
+}
+
+func writeHealthReport(ctx *gin.Context, report model.HealthReport) {
+	status := http.StatusOK
+	if report.Status != model.HealthOK {
+		status = http.StatusServiceUnavailable
+	}
+	ctx.JSON(status, report)
+}
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/seansa/rocket-challenge/internal/queue"
+	"github.com/seansa/rocket-challenge/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupHealthRouter(controller *HealthController) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/healthz", controller.LivenessHandler)
+	r.GET("/readyz", controller.ReadinessHandler)
+	return r
+}
+
+func getHealthReport(t *testing.T, router *gin.Engine, path string, status int) model.HealthReport {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	require.Equal(t, status, w.Code, w.Body.String())
+
+	var report model.HealthReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	return report
+}
+
+// TestLivenessHandler tests /healthz with running workers and without any.
+func TestLivenessHandler(t *testing.T) {
+	mockService := new(MockRocketService)
+	q := queue.NewMemory(0, queue.Lanes{})
+	defer q.Close()
+
+	heartbeats := service.NewHeartbeats(time.Minute)
+	router := setupHealthRouter(NewHealthController(mockService, heartbeats, q, 0))
+
+	report := getHealthReport(t, router, "/healthz", http.StatusServiceUnavailable)
+	assert.Equal(t, model.HealthFailing, report.Checks["workers"].Status)
+	assert.Equal(t, "no worker is running", report.Checks["workers"].Detail)
+
+	require.NoError(t, service.NewWorkerPool(q, mockService, heartbeats, 0).Resize(2))
+	require.Eventually(t, func() bool { return len(heartbeats.Workers()) == 2 }, time.Second, time.Millisecond)
+
+	report = getHealthReport(t, router, "/healthz", http.StatusOK)
+	assert.Equal(t, model.HealthOK, report.Status)
+	assert.Equal(t, "2/2 workers healthy", report.Checks["workers"].Detail)
+	require.Len(t, report.Workers, 2)
+	assert.Equal(t, model.WorkerIdle, report.Workers[0].State)
+}
+
+// TestReadinessHandler tests each readiness check failing on its own.
+func TestReadinessHandler(t *testing.T) {
+	mockService := new(MockRocketService)
+	q := queue.NewMemory(4, queue.Lanes{})
+	controller := NewHealthController(mockService, service.NewHeartbeats(0), q, 0.5)
+	router := setupHealthRouter(controller)
+
+	mockService.On("Ping").Return(nil).Once()
+	report := getHealthReport(t, router, "/readyz", http.StatusOK)
+	assert.Equal(t, model.HealthOK, report.Status)
+	assert.Equal(t, "0/4 messages queued (0.0%)", report.Checks["queue"].Detail)
+
+	mockService.On("Ping").Return(errors.New("connection refused")).Once()
+	report = getHealthReport(t, router, "/readyz", http.StatusServiceUnavailable)
+	assert.Equal(t, model.HealthFailing, report.Checks["repository"].Status)
+	assert.Contains(t, report.Checks["repository"].Detail, "connection refused")
+	assert.Equal(t, model.HealthOK, report.Checks["queue"].Status)
+
+	// Saturated at 2/4 with a threshold of 0.5, before MessageHandler would reject.
+	require.NoError(t, q.Enqueue(context.Background(), model.IncomingMessage{}))
+	require.NoError(t, q.Enqueue(context.Background(), model.IncomingMessage{}))
+	mockService.On("Ping").Return(nil)
+	report = getHealthReport(t, router, "/readyz", http.StatusServiceUnavailable)
+	assert.Equal(t, model.HealthFailing, report.Checks["queue"].Status)
+	assert.Equal(t, model.HealthOK, report.Checks["repository"].Status)
+	(<-q.Deliveries()).Ack()
+	(<-q.Deliveries()).Ack()
+
+	controller.SetShuttingDown()
+	report = getHealthReport(t, router, "/readyz", http.StatusServiceUnavailable)
+	assert.Equal(t, model.HealthFailing, report.Checks["shutdown"].Status)
+	assert.Equal(t, model.HealthOK, report.Checks["queue"].Status)
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seansa/rocket-challenge/internal/logging"
+)
+
+// RequestIDHeader carries the correlation ID of a request. A well-formed ID
+// sent by the client is kept, so that producers can follow their own IDs
+// through the logs; otherwise one is generated. It is echoed in the response.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds client-supplied correlation IDs.
+const maxRequestIDLength = 128
+
+// RequestID returns a middleware that attaches the request's correlation ID to
+// its context, where the logger and the handlers find it.
+func RequestID() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = logging.NewRequestID()
+		}
+		ctx.Request = ctx.Request.WithContext(logging.WithRequestID(ctx.Request.Context(), id))
+		ctx.Header(RequestIDHeader, id)
+		ctx.Next()
+	}
+}
+
+// validRequestID accepts printable ASCII without spaces, so that client IDs
+// cannot break log lines.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// requestID returns the correlation ID set by RequestID, or a new one when the
+// middleware is not installed.
+func requestID(ctx *gin.Context) string {
+	if id := logging.RequestID(ctx.Request.Context()); id != "" {
+		return id
+	}
+	return logging.NewRequestID()
+}
+
+// AccessLog returns a middleware that logs every request once it is served.
+func AccessLog() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+		slog.InfoContext(ctx.Request.Context(), "HTTP request",
+			"method", ctx.Request.Method,
+			"path", ctx.Request.URL.Path,
+			"status", ctx.Writer.Status(),
+			"duration", time.Since(start),
+			"bytes", ctx.Writer.Size(),
+			"client_ip", ctx.ClientIP(),
+		)
+	}
+}
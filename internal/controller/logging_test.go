@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seansa/rocket-challenge/internal/logging"
+	"github.com/seansa/rocket-challenge/internal/queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const loggingTestMessage = `{"metadata":{"channel":"rocket-a","messageNumber":1,"messageTime":"2022-02-02T19:39:05Z","messageType":"RocketSpeedIncreased"},"message":{"by":100}}`
+
+// TestMessageHandler_RequestID tests that the correlation ID is kept or
+// generated, echoed, and travels with the message.
+func TestMessageHandler_RequestID(t *testing.T) {
+	q := queue.NewMemory(3, queue.Lanes{})
+	router := setupRouter(new(MockRocketService), q)
+
+	for _, tc := range []struct {
+		header   string
+		expected string
+	}{
+		{header: "producer-42", expected: "producer-42"},
+		{header: ""},
+		{header: "has spaces\r\ninjected: true"},
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader(loggingTestMessage))
+		req.Header.Set(RequestIDHeader, tc.header)
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusAccepted, w.Code)
+
+		id := w.Header().Get(RequestIDHeader)
+		if tc.expected != "" {
+			assert.Equal(t, tc.expected, id)
+		} else {
+			assert.Len(t, id, 32, "a new ID replaces a missing or malformed one")
+		}
+
+		var body map[string]string
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, id, body["requestId"])
+		delivery := <-q.Deliveries()
+		assert.Equal(t, id, delivery.Message.RequestID)
+		delivery.Ack()
+	}
+
+	// Errors carry the ID too.
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader("{"))
+	req.Header.Set(RequestIDHeader, "producer-43")
+	router.ServeHTTP(w, req)
+	assertProblem(t, w, http.StatusBadRequest, CodeInvalidPayload)
+	assert.Equal(t, "producer-43", w.Header().Get(RequestIDHeader))
+}
+
+// TestAccessLog tests that requests are logged with their correlation ID.
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, "info", logging.FormatJSON)
+	require.NoError(t, err)
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	defer slog.SetDefault(previous)
+
+	router := setupRouter(new(MockRocketService), queue.NewMemory(1, queue.Lanes{}))
+	router.Use(AccessLog())
+	router.GET("/logged", func(ctx *gin.Context) { ctx.Status(http.StatusNoContent) })
+
+	req := httptest.NewRequest(http.MethodGet, "/logged", nil)
+	req.Header.Set(RequestIDHeader, "req-7")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "HTTP request", record["msg"])
+	assert.Equal(t, "req-7", record[logging.RequestIDKey])
+	assert.Equal(t, "/logged", record["path"])
+	assert.Equal(t, float64(http.StatusNoContent), record["status"])
+}
@@ -0,0 +1,18 @@
+package controller
+
+import "github.com/seansa/rocket-challenge/internal/metrics"
+
+// Results of POST /messages as counted in rocket_messages_received_total.
+const (
+	resultAccepted     = "accepted"
+	resultRejected     = "rejected"
+	resultInvalid      = "invalid"
+	resultUnauthorized = "unauthorized"
+	resultThrottled    = "throttled"
+)
+
+var receivedMessages = metrics.Default.NewCounterVec("rocket_messages_received_total",
+	"Messages received on POST /messages: accepted into the queue, rejected with 503 because it was full, invalid, unauthorized, or throttled with 429.", "result")
+
+var throttledMessages = metrics.Default.NewCounterVec("rocket_messages_throttled_total",
+	"Messages rejected with 429, by the rate limit that was exceeded (channel or client).", "scope")
@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/seansa/rocket-challenge/internal/service"
+)
+
+// streamKeepAliveInterval is how often an SSE comment is sent on idle streams
+// so that proxies do not time the connection out.
+var streamKeepAliveInterval = 15 * time.Second
+
+// StreamRocketsHandler handles GET requests to /rockets/stream and /rockets/{channel}/stream.
+// @Summary Stream rocket state changes
+// @Description Pushes a Server-Sent Event ("rocket_updated") with the new rocket state and the message that caused it every time a rocket changes, and "contact_lost" / "contact_restored" events when a rocket goes silent and is heard from again. When the client falls behind, or resumes (Last-Event-ID) from an event that is no longer retained, a "resync" event is sent and the client should re-read GET /rockets.
+// @Tags rockets
+// @Produce text/event-stream
+// @Param Last-Event-ID header string false "Resume after this event ID"
+// @Success 200 {object} model.RocketEvent "Stream of rocket events"
+// @Failure 400 {object} model.Problem "Invalid Last-Event-ID (INVALID_PAYLOAD)"
+// @Router /rockets/stream [get]
+func (c *RocketController) StreamRocketsHandler(ctx *gin.Context) {
+	c.streamEvents(ctx, nil)
+}
+
+// StreamRocketStateHandler handles GET requests to the /rockets/{channel}/stream endpoint.
+// @Summary Stream state changes of a single rocket
+// @Description Same as /rockets/stream, restricted to the rocket on the given channel.
+// @Tags rockets
+// @Produce text/event-stream
+// @Param channel path string true "Rocket Channel ID"
+// @Param Last-Event-ID header string false "Resume after this event ID"
+// @Success 200 {object} model.RocketEvent "Stream of rocket events"
+// @Failure 400 {object} model.Problem "Invalid Last-Event-ID (INVALID_PAYLOAD)"
+// @Router /rockets/{channel}/stream [get]
+func (c *RocketController) StreamRocketStateHandler(ctx *gin.Context) {
+	channel := ctx.Param("channel")
+	c.streamEvents(ctx, func(event model.RocketEvent) bool {
+		return event.Rocket.Channel == channel
+	})
+}
+
+func (c *RocketController) streamEvents(ctx *gin.Context, filter service.EventFilter) {
+	var sub *service.Subscription
+	if lastEventID := ctx.GetHeader("Last-Event-ID"); lastEventID != "" {
+		id, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			_ = ctx.Error(fmt.Errorf("invalid Last-Event-ID %q: %w", lastEventID, model.ErrInvalidPayload))
+			return
+		}
+		sub = c.service.Events().SubscribeFrom(filter, id)
+	} else {
+		sub = c.service.Events().Subscribe(filter)
+	}
+	defer sub.Close()
+
+	ctx.Header("Content-Type", sse.ContentType)
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+	ctx.Writer.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-ctx.Request.Context().Done():
+			return
+		case event, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			if err := writeSSEvent(ctx, event); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := ctx.Writer.WriteString(": keep-alive\n\n"); err != nil {
+				return
+			}
+		}
+		ctx.Writer.Flush()
+	}
+}
+
+func writeSSEvent(ctx *gin.Context, event model.RocketEvent) error {
+	sseEvent := sse.Event{
+		Event: string(event.Kind),
+		Data:  event,
+	}
+	if event.ID != 0 {
+		sseEvent.Id = strconv.FormatUint(event.ID, 10)
+	}
+	return sse.Encode(ctx.Writer, sseEvent)
+}
@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/seansa/rocket-challenge/internal/queue"
+	"github.com/seansa/rocket-challenge/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sseEvent struct {
+	id    string
+	event string
+	data  string
+}
+
+// readSSEvents reads n events from an SSE stream, skipping comments.
+func readSSEvents(t *testing.T, reader *bufio.Reader, n int) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	current := sseEvent{}
+	for len(events) < n {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "":
+			if current.event != "" {
+				events = append(events, current)
+			}
+			current = sseEvent{}
+		case strings.HasPrefix(line, "id:"):
+			current.id = strings.TrimSpace(strings.TrimPrefix(line, "id:"))
+		case strings.HasPrefix(line, "event:"):
+			current.event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			current.data = strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		}
+	}
+	return events
+}
+
+func openStream(t *testing.T, server *httptest.Server, path string, lastEventID string) (*bufio.Reader, context.CancelFunc) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+path, nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/event-stream")
+	t.Cleanup(func() { resp.Body.Close() })
+	return bufio.NewReader(resp.Body), cancel
+}
+
+func launchMessage(channel string, number int) model.IncomingMessage {
+	return model.IncomingMessage{
+		Metadata: model.Metadata{
+			Channel:       channel,
+			MessageNumber: number,
+			MessageTime:   time.Now(),
+			MessageType:   model.RocketLaunched,
+		},
+		Message: json.RawMessage(`{"type":"Falcon-9","launchSpeed":500,"mission":"ARTEMIS"}`),
+	}
+}
+
+// TestStreamRocketStateHandler_FiltersByChannel tests that a channel stream only receives that rocket's events.
+func TestStreamRocketStateHandler_FiltersByChannel(t *testing.T) {
+	publisher := service.NewPublisher(16, 16)
+	mockService := new(MockRocketService)
+	mockService.On("Events").Return(publisher)
+	server := httptest.NewServer(setupRouter(mockService, queue.NewMemory(0, queue.Lanes{})))
+	defer server.Close()
+
+	reader, cancel := openStream(t, server, "/rockets/rocket-b/stream", "")
+	defer cancel()
+
+	publisher.Publish(model.Rocket{Channel: "rocket-a", Speed: 100}, launchMessage("rocket-a", 1))
+	publisher.Publish(model.Rocket{Channel: "rocket-b", Speed: 200}, launchMessage("rocket-b", 1))
+
+	events := readSSEvents(t, reader, 1)
+	assert.Equal(t, "2", events[0].id)
+	assert.Equal(t, string(model.EventRocketUpdated), events[0].event)
+
+	var event model.RocketEvent
+	require.NoError(t, json.Unmarshal([]byte(events[0].data), &event))
+	assert.Equal(t, "rocket-b", event.Rocket.Channel)
+	assert.Equal(t, 200, event.Rocket.Speed)
+	assert.Equal(t, model.RocketLaunched, event.Message.Metadata.MessageType)
+}
+
+// TestStreamRocketsHandler_ResumesFromLastEventID tests Last-Event-ID resumption and the resync marker.
+func TestStreamRocketsHandler_ResumesFromLastEventID(t *testing.T) {
+	publisher := service.NewPublisher(2, 16)
+	mockService := new(MockRocketService)
+	mockService.On("Events").Return(publisher)
+	server := httptest.NewServer(setupRouter(mockService, queue.NewMemory(0, queue.Lanes{})))
+	defer server.Close()
+
+	for i := 1; i <= 3; i++ {
+		publisher.Publish(model.Rocket{Channel: "rocket-a", Speed: i}, launchMessage("rocket-a", i))
+	}
+
+	reader, cancel := openStream(t, server, "/rockets/stream", "2")
+	events := readSSEvents(t, reader, 1)
+	assert.Equal(t, "3", events[0].id)
+	cancel()
+
+	// Event 1 is no longer retained, so resuming after it requires a resync.
+	reader, cancel = openStream(t, server, "/rockets/stream", "0")
+	defer cancel()
+	events = readSSEvents(t, reader, 3)
+	assert.Equal(t, string(model.EventResync), events[0].event)
+	assert.Empty(t, events[0].id)
+	assert.Equal(t, "2", events[1].id)
+	assert.Equal(t, "3", events[2].id)
+}
+
+// TestStreamRocketsHandler_InvalidLastEventID tests that a malformed Last-Event-ID is rejected.
+func TestStreamRocketsHandler_InvalidLastEventID(t *testing.T) {
+	mockService := new(MockRocketService)
+	router := setupRouter(mockService, queue.NewMemory(0, queue.Lanes{}))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/rockets/stream", nil)
+	req.Header.Set("Last-Event-ID", "not-a-number")
+	router.ServeHTTP(w, req)
+
+	assertProblem(t, w, http.StatusBadRequest, CodeInvalidPayload)
+	mockService.AssertNotCalled(t, "Events")
+}
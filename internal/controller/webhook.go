@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/seansa/rocket-challenge/internal/webhook"
+)
+
+type WebhookController struct {
+	registry webhook.Registry
+}
+
+func NewWebhookController(registry webhook.Registry) *WebhookController {
+	return &WebhookController{
+		registry: registry,
+	}
+}
+
+// CreateWebhookHandler handles POST requests to the /webhooks endpoint.
+// @Summary Register a webhook
+// @Description Registers an endpoint that is called on matching rocket state changes. Events: launched, speed_changed, exploded, mission_changed, contact_lost, contact_restored or "*". Optional channels restrict deliveries to those rockets. Payloads are signed with HMAC-SHA256 in the X-Signature header ("t=<unix>,v1=<hex>" over "<t>.<body>"); the secret is generated when omitted and only returned by this call.
+// @Tags webhooks
+// @Accept json
+// @Produce json,application/problem+json
+// @Security AdminToken
+// @Param webhook body model.WebhookRequest true "Webhook registration"
+// @Success 201 {object} model.Webhook "Registered webhook, including its secret"
+// @Failure 400 {object} model.Problem "Invalid registration, or a loopback, link-local or private target that is not allowed (INVALID_PAYLOAD)"
+// @Failure 401 {object} model.Problem "Missing or invalid admin token (UNAUTHORIZED)"
+// @Failure 409 {object} model.Problem "URL already registered (CONFLICT)"
+// @Router /webhooks [post]
+func (c *WebhookController) CreateWebhookHandler(ctx *gin.Context) {
+	var req model.WebhookRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		_ = ctx.Error(fmt.Errorf("invalid webhook registration: %w: %w", model.ErrInvalidPayload, err))
+		return
+	}
+
+	hook, err := c.registry.Register(req)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, hook)
+}
+
+// ListWebhooksHandler handles GET requests to the /webhooks endpoint.
+// @Summary List webhooks
+// @Description Returns every registered webhook (without secrets), oldest first.
+// @Tags webhooks
+// @Produce json,application/problem+json
+// @Security AdminToken
+// @Success 200 {array} model.Webhook "Registered webhooks"
+// @Failure 401 {object} model.Problem "Missing or invalid admin token (UNAUTHORIZED)"
+// @Router /webhooks [get]
+func (c *WebhookController) ListWebhooksHandler(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.registry.List())
+}
+
+// GetWebhookHandler handles GET requests to the /webhooks/{id} endpoint.
+// @Summary Get a webhook
+// @Tags webhooks
+// @Produce json,application/problem+json
+// @Security AdminToken
+// @Param id path string true "Webhook ID"
+// @Success 200 {object} model.Webhook "Webhook"
+// @Failure 401 {object} model.Problem "Missing or invalid admin token (UNAUTHORIZED)"
+// @Failure 404 {object} model.Problem "Webhook not found (NOT_FOUND)"
+// @Router /webhooks/{id} [get]
+func (c *WebhookController) GetWebhookHandler(ctx *gin.Context) {
+	hook, err := c.registry.Get(ctx.Param("id"))
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, hook)
+}
+
+// DeleteWebhookHandler handles DELETE requests to the /webhooks/{id} endpoint.
+// @Summary Delete a webhook
+// @Tags webhooks
+// @Produce application/problem+json
+// @Security AdminToken
+// @Param id path string true "Webhook ID"
+// @Success 204 "Webhook deleted"
+// @Failure 401 {object} model.Problem "Missing or invalid admin token (UNAUTHORIZED)"
+// @Failure 404 {object} model.Problem "Webhook not found (NOT_FOUND)"
+// @Router /webhooks/{id} [delete]
+func (c *WebhookController) DeleteWebhookHandler(ctx *gin.Context) {
+	if err := c.registry.Delete(ctx.Param("id")); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// EnableWebhookHandler handles POST requests to the /webhooks/{id}/enable endpoint.
+// @Summary Re-enable a webhook
+// @Description Re-enables a webhook that was disabled automatically after repeated failed deliveries.
+// @Tags webhooks
+// @Produce json,application/problem+json
+// @Security AdminToken
+// @Param id path string true "Webhook ID"
+// @Success 200 {object} model.Webhook "Webhook"
+// @Failure 401 {object} model.Problem "Missing or invalid admin token (UNAUTHORIZED)"
+// @Failure 404 {object} model.Problem "Webhook not found (NOT_FOUND)"
+// @Router /webhooks/{id}/enable [post]
+func (c *WebhookController) EnableWebhookHandler(ctx *gin.Context) {
+	hook, err := c.registry.Enable(ctx.Param("id"))
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, hook)
+}
+
+// GetWebhookDeliveriesHandler handles GET requests to the /webhooks/{id}/deliveries endpoint.
+// @Summary Get the delivery log of a webhook
+// @Description Returns the most recent delivery attempts, newest first.
+// @Tags webhooks
+// @Produce json,application/problem+json
+// @Security AdminToken
+// @Param id path string true "Webhook ID"
+// @Success 200 {array} model.WebhookDelivery "Delivery attempts"
+// @Failure 401 {object} model.Problem "Missing or invalid admin token (UNAUTHORIZED)"
+// @Failure 404 {object} model.Problem "Webhook not found (NOT_FOUND)"
+// @Router /webhooks/{id}/deliveries [get]
+func (c *WebhookController) GetWebhookDeliveriesHandler(ctx *gin.Context) {
+	deliveries, err := c.registry.Deliveries(ctx.Param("id"))
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, deliveries)
+}
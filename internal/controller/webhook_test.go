@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/seansa/rocket-challenge/internal/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupWebhookRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(ErrorHandler())
+
+	dispatcher := webhook.NewDispatcher(webhook.DefaultOptions())
+	controller := NewWebhookController(dispatcher)
+	r.POST("/webhooks", controller.CreateWebhookHandler)
+	r.GET("/webhooks", controller.ListWebhooksHandler)
+	r.GET("/webhooks/:id", controller.GetWebhookHandler)
+	r.DELETE("/webhooks/:id", controller.DeleteWebhookHandler)
+	r.GET("/webhooks/:id/deliveries", controller.GetWebhookDeliveriesHandler)
+	return r
+}
+
+// TestWebhookHandlers_Lifecycle tests registering, listing, reading and deleting a webhook.
+func TestWebhookHandlers_Lifecycle(t *testing.T) {
+	router := setupWebhookRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/webhooks", bytes.NewBufferString(`{"url":"http://example.com/hook","events":["exploded"],"channels":["rocket-a"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var created model.Webhook
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.NotEmpty(t, created.ID)
+	assert.NotEmpty(t, created.Secret)
+	assert.True(t, created.Enabled)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/webhooks/"+created.ID, nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), created.Secret)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/webhooks", nil)
+	router.ServeHTTP(w, req)
+	var hooks []model.Webhook
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &hooks))
+	assert.Len(t, hooks, 1)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/webhooks/"+created.ID, nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/webhooks/"+created.ID+"/deliveries", nil)
+	router.ServeHTTP(w, req)
+	assertProblem(t, w, http.StatusNotFound, CodeNotFound)
+}
+
+// TestCreateWebhookHandler_Invalid tests that invalid registrations are rejected.
+func TestCreateWebhookHandler_Invalid(t *testing.T) {
+	router := setupWebhookRouter()
+
+	for _, body := range []string{`{"events":["exploded"]}`, `{"url":"ftp://example.com","events":["exploded"]}`} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/webhooks", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		assertProblem(t, w, http.StatusBadRequest, CodeInvalidPayload)
+	}
+}
@@ -0,0 +1,309 @@
+package controller
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/seansa/rocket-challenge/internal/service"
+)
+
+// WebSocket message types. Clients send subscribe, unsubscribe and ping; the
+// server answers with snapshot, update, pong and error.
+const (
+	WSTypeSubscribe   = "subscribe"
+	WSTypeUnsubscribe = "unsubscribe"
+	WSTypePing        = "ping"
+	WSTypeSnapshot    = "snapshot"
+	WSTypeUpdate      = "update"
+	WSTypePong        = "pong"
+	WSTypeError       = "error"
+)
+
+// wsWildcard subscribes to every rocket when used as a channel, mission or type.
+const wsWildcard = "*"
+
+const (
+	wsWriteWait  = 10 * time.Second
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = wsPongWait * 9 / 10
+)
+
+// WSClientMessage is a message sent by a WebSocket client. Subscribe and
+// unsubscribe add or remove selectors; a rocket matches the subscription when
+// any of its channel, mission or type is selected.
+type WSClientMessage struct {
+	Type     string   `json:"type"`
+	ID       string   `json:"id,omitempty"`
+	Channels []string `json:"channels,omitempty"`
+	Missions []string `json:"missions,omitempty"`
+	Types    []string `json:"types,omitempty"`
+}
+
+// WSServerMessage is a message sent by the server. Snapshot carries the rockets
+// matching the subscription and the last event ID they reflect; update carries a
+// single state change. ID echoes the client message being answered.
+type WSServerMessage struct {
+	Type        string             `json:"type"`
+	ID          string             `json:"id,omitempty"`
+	Rockets     []model.Rocket     `json:"rockets,omitempty"`
+	LastEventID uint64             `json:"lastEventId,omitempty"`
+	Event       *model.RocketEvent `json:"event,omitempty"`
+	Error       string             `json:"error,omitempty"`
+}
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// SetAllowedOrigins lets browser pages from origins, besides the API's own,
+// open WebSockets. "*" allows any origin. Without it, cross-origin pages are
+// refused so that they cannot use a visitor's browser to subscribe.
+func (c *RocketController) SetAllowedOrigins(origins []string) {
+	c.allowedOrigins = origins
+}
+
+// checkOrigin accepts requests from the API's own host and from allowed
+// origins. Requests without an Origin header do not come from a browser and
+// are accepted as well.
+func (c *RocketController) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, allowed := range c.allowedOrigins {
+		if allowed == wsWildcard || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// wsSelection is the set of selectors a WebSocket client is subscribed to,
+// and the rockets the client holds because of them.
+type wsSelection struct {
+	mutex    sync.RWMutex
+	channels map[string]bool
+	missions map[string]bool
+	types    map[string]bool
+	// sent holds the channels of the matching rockets the client was last
+	// sent. Their next event is forwarded even when the rocket no longer
+	// matches, for example after an explosion aborted its mission, so that
+	// the client sees it leave the selection.
+	sent map[string]bool
+}
+
+func newWSSelection() *wsSelection {
+	return &wsSelection{
+		channels: make(map[string]bool),
+		missions: make(map[string]bool),
+		types:    make(map[string]bool),
+		sent:     make(map[string]bool),
+	}
+}
+
+func (s *wsSelection) update(msg WSClientMessage, subscribe bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	apply := func(set map[string]bool, values []string) {
+		for _, v := range values {
+			if subscribe {
+				set[v] = true
+			} else {
+				delete(set, v)
+			}
+		}
+	}
+	apply(s.channels, msg.Channels)
+	apply(s.missions, msg.Missions)
+	apply(s.types, msg.Types)
+}
+
+func (s *wsSelection) matches(rocket model.Rocket) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.matchesLocked(rocket)
+}
+
+// wants reports whether a change of rocket is forwarded: the rocket matches
+// now or did in the last state the client was sent.
+func (s *wsSelection) wants(rocket model.Rocket) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.matchesLocked(rocket) || s.sent[rocket.Channel]
+}
+
+// track records that the client was sent rocket.
+func (s *wsSelection) track(rocket model.Rocket) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.matchesLocked(rocket) {
+		s.sent[rocket.Channel] = true
+	} else {
+		delete(s.sent, rocket.Channel)
+	}
+}
+
+// reset records that the client was sent a snapshot of rockets.
+func (s *wsSelection) reset(rockets []model.Rocket) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.sent = make(map[string]bool, len(rockets))
+	for _, rocket := range rockets {
+		s.sent[rocket.Channel] = true
+	}
+}
+
+func (s *wsSelection) matchesLocked(rocket model.Rocket) bool {
+	return s.channels[wsWildcard] || s.missions[wsWildcard] || s.types[wsWildcard] ||
+		s.channels[rocket.Channel] ||
+		(rocket.Mission != "" && s.missions[rocket.Mission]) ||
+		(rocket.Type != "" && s.types[rocket.Type])
+}
+
+// WebSocketHandler handles WebSocket connections on the /ws endpoint.
+// @Summary Subscribe to rocket updates over WebSocket
+// @Description Upgrades to a WebSocket. Clients send {"type":"subscribe"|"unsubscribe","channels":[],"missions":[],"types":[]} ("*" selects everything) and {"type":"ping"}. After each subscription change the server sends a "snapshot" of the matching rockets, followed by an "update" for every matching state change, including the one after which a rocket no longer matches. A new snapshot is also sent if the client falls behind.
+// @Tags rockets
+// @Param message body WSClientMessage false "Client message (sent over the socket)"
+// @Success 101 {object} WSServerMessage "Server message (sent over the socket)"
+// @Router /ws [get]
+func (c *RocketController) WebSocketHandler(ctx *gin.Context) {
+	upgrader := wsUpgrader
+	upgrader.CheckOrigin = c.checkOrigin
+	conn, err := upgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+	if err != nil {
+		// The upgrader has already written an HTTP error response.
+		slog.WarnContext(ctx.Request.Context(), "WebSocket upgrade failed", "error", err)
+		return
+	}
+	defer conn.Close()
+
+	selection := newWSSelection()
+	sub := c.service.Events().Subscribe(func(event model.RocketEvent) bool {
+		return selection.wants(*event.Rocket)
+	})
+	defer sub.Close()
+
+	requests := make(chan WSClientMessage)
+	done := make(chan struct{})
+	stop := make(chan struct{})
+	defer close(stop)
+	go c.readWebSocket(conn, requests, done, stop)
+
+	c.writeWebSocket(conn, selection, sub, requests, done)
+}
+
+// readWebSocket decodes client messages and forwards them to the writer loop,
+// which owns the connection for writing. It closes done when the client goes
+// away and gives up as soon as stop is closed by the writer.
+func (c *RocketController) readWebSocket(conn *websocket.Conn, requests chan<- WSClientMessage, done chan<- struct{}, stop <-chan struct{}) {
+	defer close(done)
+
+	conn.SetReadLimit(64 * 1024)
+	_ = conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(wsPongWait))
+
+		var msg WSClientMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			// An empty type is answered with an error by the writer loop.
+			msg = WSClientMessage{}
+		}
+		select {
+		case requests <- msg:
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (c *RocketController) writeWebSocket(conn *websocket.Conn, selection *wsSelection, sub *service.Subscription, requests <-chan WSClientMessage, done <-chan struct{}) {
+	ping := time.NewTicker(wsPingPeriod)
+	defer ping.Stop()
+
+	var snapshotEventID uint64
+	send := func(msg WSServerMessage) bool {
+		_ = conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+		return conn.WriteJSON(msg) == nil
+	}
+	sendSnapshot := func(id string) bool {
+		snapshot, err := c.wsSnapshot(selection, id)
+		if err != nil {
+			return send(WSServerMessage{Type: WSTypeError, ID: id, Error: err.Error()})
+		}
+		snapshotEventID = snapshot.LastEventID
+		selection.reset(snapshot.Rockets)
+		return send(snapshot)
+	}
+
+	for {
+		ok := true
+		select {
+		case <-done:
+			return
+		case req := <-requests:
+			switch req.Type {
+			case WSTypeSubscribe, WSTypeUnsubscribe:
+				selection.update(req, req.Type == WSTypeSubscribe)
+				ok = sendSnapshot(req.ID)
+			case WSTypePing:
+				ok = send(WSServerMessage{Type: WSTypePong, ID: req.ID})
+			default:
+				ok = send(WSServerMessage{Type: WSTypeError, ID: req.ID, Error: "unknown or malformed message type"})
+			}
+		case event, open := <-sub.Events():
+			switch {
+			case !open:
+				return
+			case event.Kind == model.EventResync:
+				ok = sendSnapshot("")
+			case event.ID > snapshotEventID:
+				selection.track(*event.Rocket)
+				ok = send(WSServerMessage{Type: WSTypeUpdate, Event: &event})
+			}
+		case <-ping.C:
+			_ = conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			ok = conn.WriteMessage(websocket.PingMessage, nil) == nil
+		}
+		if !ok {
+			return
+		}
+	}
+}
+
+// wsSnapshot returns the rockets currently matching selection. Events published
+// up to LastEventID are already reflected in it.
+func (c *RocketController) wsSnapshot(selection *wsSelection, id string) (WSServerMessage, error) {
+	lastEventID := c.service.Events().LastEventID()
+	rockets, err := c.service.GetAllRocketStates()
+	if err != nil {
+		return WSServerMessage{}, err
+	}
+
+	matching := make([]model.Rocket, 0, len(rockets))
+	for _, rocket := range rockets {
+		if selection.matches(rocket) {
+			matching = append(matching, rocket)
+		}
+	}
+	return WSServerMessage{Type: WSTypeSnapshot, ID: id, Rockets: matching, LastEventID: lastEventID}, nil
+}
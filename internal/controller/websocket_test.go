@@ -0,0 +1,174 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/seansa/rocket-challenge/internal/queue"
+	"github.com/seansa/rocket-challenge/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func dialWebSocket(t *testing.T, server *httptest.Server) *websocket.Conn {
+	t.Helper()
+	conn, resp, err := dialWebSocketFrom(server, "")
+	require.NoError(t, err)
+	resp.Body.Close()
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// dialWebSocketFrom opens /ws as a browser page of origin would, or as a
+// non-browser client when origin is empty.
+func dialWebSocketFrom(server *httptest.Server, origin string) (*websocket.Conn, *http.Response, error) {
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	header := http.Header{}
+	if origin != "" {
+		header.Set("Origin", origin)
+	}
+	return websocket.DefaultDialer.Dial(url, header)
+}
+
+func readWSMessage(t *testing.T, conn *websocket.Conn) WSServerMessage {
+	t.Helper()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	var msg WSServerMessage
+	require.NoError(t, conn.ReadJSON(&msg))
+	return msg
+}
+
+// TestWebSocketHandler_SnapshotThenUpdates tests the subscribe flow: snapshot first, then matching updates only.
+func TestWebSocketHandler_SnapshotThenUpdates(t *testing.T) {
+	publisher := service.NewPublisher(16, 16)
+	mockService := new(MockRocketService)
+	mockService.On("Events").Return(publisher)
+	mockService.On("GetAllRocketStates").Return([]model.Rocket{
+		{Channel: "rocket-a", Type: "Falcon-9", Mission: "ARTEMIS"},
+		{Channel: "rocket-b", Type: "Falcon-Heavy", Mission: "APOLLO"},
+		{Channel: "rocket-c", Type: "Falcon-9", Mission: "GEMINI"},
+	}, nil)
+	server := httptest.NewServer(setupRouter(mockService, queue.NewMemory(0, queue.Lanes{})))
+	defer server.Close()
+	conn := dialWebSocket(t, server)
+
+	require.NoError(t, conn.WriteJSON(WSClientMessage{Type: WSTypeSubscribe, ID: "1", Missions: []string{"ARTEMIS"}, Channels: []string{"rocket-b"}}))
+	snapshot := readWSMessage(t, conn)
+	assert.Equal(t, WSTypeSnapshot, snapshot.Type)
+	assert.Equal(t, "1", snapshot.ID)
+	require.Len(t, snapshot.Rockets, 2)
+	assert.Equal(t, "rocket-a", snapshot.Rockets[0].Channel)
+	assert.Equal(t, "rocket-b", snapshot.Rockets[1].Channel)
+
+	publisher.Publish(model.Rocket{Channel: "rocket-c", Mission: "GEMINI"}, launchMessage("rocket-c", 2))
+	publisher.Publish(model.Rocket{Channel: "rocket-b", Mission: "APOLLO", Speed: 900}, launchMessage("rocket-b", 2))
+
+	update := readWSMessage(t, conn)
+	assert.Equal(t, WSTypeUpdate, update.Type)
+	require.NotNil(t, update.Event)
+	assert.Equal(t, "rocket-b", update.Event.Rocket.Channel)
+	assert.Equal(t, 900, update.Event.Rocket.Speed)
+
+	require.NoError(t, conn.WriteJSON(WSClientMessage{Type: WSTypePing, ID: "2"}))
+	pong := readWSMessage(t, conn)
+	assert.Equal(t, WSTypePong, pong.Type)
+	assert.Equal(t, "2", pong.ID)
+}
+
+// TestWebSocketHandler_Unsubscribe tests that unsubscribed selectors stop receiving updates.
+func TestWebSocketHandler_Unsubscribe(t *testing.T) {
+	publisher := service.NewPublisher(16, 16)
+	mockService := new(MockRocketService)
+	mockService.On("Events").Return(publisher)
+	mockService.On("GetAllRocketStates").Return([]model.Rocket{{Channel: "rocket-a", Type: "Falcon-9"}}, nil)
+	server := httptest.NewServer(setupRouter(mockService, queue.NewMemory(0, queue.Lanes{})))
+	defer server.Close()
+	conn := dialWebSocket(t, server)
+
+	require.NoError(t, conn.WriteJSON(WSClientMessage{Type: WSTypeSubscribe, Types: []string{"Falcon-9"}}))
+	assert.Len(t, readWSMessage(t, conn).Rockets, 1)
+
+	require.NoError(t, conn.WriteJSON(WSClientMessage{Type: WSTypeUnsubscribe, Types: []string{"Falcon-9"}}))
+	assert.Empty(t, readWSMessage(t, conn).Rockets)
+
+	publisher.Publish(model.Rocket{Channel: "rocket-a", Type: "Falcon-9"}, launchMessage("rocket-a", 2))
+	require.NoError(t, conn.WriteJSON(WSClientMessage{Type: WSTypePing}))
+	// The pong arrives first because the update was filtered out.
+	assert.Equal(t, WSTypePong, readWSMessage(t, conn).Type)
+}
+
+// TestWebSocketHandler_LeavingSelection tests that a client subscribed by
+// mission gets the update that takes a rocket out of the mission, and no
+// update after it.
+func TestWebSocketHandler_LeavingSelection(t *testing.T) {
+	publisher := service.NewPublisher(16, 16)
+	mockService := new(MockRocketService)
+	mockService.On("Events").Return(publisher)
+	mockService.On("GetAllRocketStates").Return([]model.Rocket{{Channel: "rocket-a", Mission: "ARTEMIS"}}, nil)
+	server := httptest.NewServer(setupRouter(mockService, queue.NewMemory(0, queue.Lanes{})))
+	defer server.Close()
+	conn := dialWebSocket(t, server)
+
+	require.NoError(t, conn.WriteJSON(WSClientMessage{Type: WSTypeSubscribe, Missions: []string{"ARTEMIS"}}))
+	assert.Len(t, readWSMessage(t, conn).Rockets, 1)
+
+	exploded := launchMessage("rocket-a", 2)
+	exploded.Metadata.MessageType = model.RocketExploded
+	publisher.Publish(model.Rocket{Channel: "rocket-a", Mission: model.Aborted, Exploded: true}, exploded)
+	update := readWSMessage(t, conn)
+	assert.Equal(t, WSTypeUpdate, update.Type)
+	require.NotNil(t, update.Event)
+	assert.True(t, update.Event.Rocket.Exploded)
+	assert.Equal(t, model.Aborted, update.Event.Rocket.Mission)
+
+	publisher.Publish(model.Rocket{Channel: "rocket-a", Mission: model.Aborted, Exploded: true}, launchMessage("rocket-a", 3))
+	require.NoError(t, conn.WriteJSON(WSClientMessage{Type: WSTypePing}))
+	assert.Equal(t, WSTypePong, readWSMessage(t, conn).Type, "the rocket left the selection")
+}
+
+// TestWebSocketHandler_MalformedMessage tests that malformed client messages get an error reply.
+func TestWebSocketHandler_MalformedMessage(t *testing.T) {
+	mockService := new(MockRocketService)
+	mockService.On("Events").Return(service.NewPublisher(16, 16))
+	server := httptest.NewServer(setupRouter(mockService, queue.NewMemory(0, queue.Lanes{})))
+	defer server.Close()
+	conn := dialWebSocket(t, server)
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{not json`)))
+	msg := readWSMessage(t, conn)
+	assert.Equal(t, WSTypeError, msg.Type)
+	assert.NotEmpty(t, msg.Error)
+}
+
+// TestWebSocketHandler_Origins tests that browser pages can only open /ws from
+// the API's own origin or an allowed one.
+func TestWebSocketHandler_Origins(t *testing.T) {
+	mockService := new(MockRocketService)
+	mockService.On("Events").Return(service.NewPublisher(16, 16))
+	controller := NewRocketController(mockService, queue.NewMemory(0, queue.Lanes{}))
+	controller.SetAllowedOrigins([]string{"https://dashboard.example/"})
+	router := setupRouter(mockService, queue.NewMemory(0, queue.Lanes{}))
+	router.GET("/allowed/ws", controller.WebSocketHandler)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	_, resp, err := dialWebSocketFrom(server, "https://evil.example")
+	require.Error(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	conn, resp, err := dialWebSocketFrom(server, server.URL)
+	require.NoError(t, err, "same origin")
+	resp.Body.Close()
+	conn.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/allowed/ws"
+	conn, resp, err = websocket.DefaultDialer.Dial(url, http.Header{"Origin": {"https://DASHBOARD.example"}})
+	require.NoError(t, err, "allowed origin")
+	resp.Body.Close()
+	conn.Close()
+}
@@ -0,0 +1,154 @@
+// Package keyring holds the shared secrets producers use to sign the messages
+// they POST, and verifies those signatures.
+//
+// Secrets are read from a keyfile with one "<pattern> <secret>" pair per line.
+// A pattern is either an exact channel or a path.Match glob covering a group
+// of channels, such as "193270a9-*" or "*". Exact channels win over globs, and
+// globs are tried in file order. Blank lines and lines starting with # are
+// ignored.
+package keyring
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+type globKey struct {
+	pattern string
+	secret  []byte
+}
+
+type keys struct {
+	exact map[string][]byte
+	globs []globKey
+}
+
+// Keyring is a keyfile loaded in memory. It is safe for concurrent use and can
+// be reloaded while in use.
+type Keyring struct {
+	path    string
+	mutex   sync.RWMutex
+	keys    keys
+	modTime time.Time
+	size    int64
+}
+
+// Load reads the keyfile at path.
+func Load(path string) (*Keyring, error) {
+	k := &Keyring{path: path}
+	if err := k.Reload(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Reload re-reads the keyfile. On error the previous keys stay in place.
+func (k *Keyring) Reload() error {
+	info, err := os.Stat(k.path)
+	if err != nil {
+		return fmt.Errorf("keyfile %s: %w", k.path, err)
+	}
+	data, err := os.ReadFile(k.path)
+	if err != nil {
+		return fmt.Errorf("keyfile %s: %w", k.path, err)
+	}
+	parsed, err := parse(data)
+	if err != nil {
+		return fmt.Errorf("keyfile %s: %w", k.path, err)
+	}
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	k.keys = parsed
+	k.modTime = info.ModTime()
+	k.size = info.Size()
+	return nil
+}
+
+func parse(data []byte) (keys, error) {
+	parsed := keys{exact: make(map[string][]byte)}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return keys{}, fmt.Errorf("line %d: expected \"<channel or glob> <secret>\"", n)
+		}
+		pattern, secret := fields[0], []byte(fields[1])
+
+		if !strings.ContainsAny(pattern, `*?[\`) {
+			if _, exists := parsed.exact[pattern]; exists {
+				return keys{}, fmt.Errorf("line %d: duplicate channel %s", n, pattern)
+			}
+			parsed.exact[pattern] = secret
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return keys{}, fmt.Errorf("line %d: invalid glob %q: %w", n, pattern, err)
+		}
+		parsed.globs = append(parsed.globs, globKey{pattern: pattern, secret: secret})
+	}
+	return parsed, scanner.Err()
+}
+
+// Secret returns the secret for channel.
+func (k *Keyring) Secret(channel string) ([]byte, bool) {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	if secret, exists := k.keys.exact[channel]; exists {
+		return secret, true
+	}
+	for _, glob := range k.keys.globs {
+		if matched, _ := path.Match(glob.pattern, channel); matched {
+			return glob.secret, true
+		}
+	}
+	return nil, false
+}
+
+// changed reports whether the keyfile was modified since it was last loaded.
+func (k *Keyring) changed() bool {
+	info, err := os.Stat(k.path)
+	if err != nil {
+		return false
+	}
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	return !info.ModTime().Equal(k.modTime) || info.Size() != k.size
+}
+
+// Watch polls the keyfile every interval until ctx is done and reloads it when
+// it changes, so secrets can be rotated without a restart.
+func (k *Keyring) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !k.changed() {
+				continue
+			}
+			if err := k.Reload(); err != nil {
+				slog.Error("Keeping previous ingestion keys, reload failed", "error", err)
+				continue
+			}
+			slog.Info("Reloaded ingestion keys", "keyfile", k.path)
+		}
+	}
+}
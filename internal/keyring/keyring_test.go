@@ -0,0 +1,135 @@
+package keyring
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/seansa/rocket-challenge/internal/signature"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeKeyfile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keys")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+// replaceKeyfile rewrites the keyfile atomically, as operators should, so the
+// watcher never sees it half-written.
+func replaceKeyfile(t *testing.T, path, content string) {
+	t.Helper()
+	tmp := path + ".tmp"
+	require.NoError(t, os.WriteFile(tmp, []byte(content), 0o600))
+	require.NoError(t, os.Rename(tmp, path))
+}
+
+// TestLoad tests exact channels, globs in file order and comments.
+func TestLoad(t *testing.T) {
+	keys, err := Load(writeKeyfile(t, `
+# exact channels win over globs
+193270a9-c9cf-404a-8f83-838e71d9ae67 exact-secret
+193270a9-* group-secret
+* fallback-secret
+`))
+	require.NoError(t, err)
+
+	secret, ok := keys.Secret("193270a9-c9cf-404a-8f83-838e71d9ae67")
+	assert.True(t, ok)
+	assert.Equal(t, "exact-secret", string(secret))
+
+	secret, _ = keys.Secret("193270a9-0000")
+	assert.Equal(t, "group-secret", string(secret))
+
+	secret, _ = keys.Secret("other")
+	assert.Equal(t, "fallback-secret", string(secret))
+}
+
+// TestLoad_Invalid tests keyfile validation errors.
+func TestLoad_Invalid(t *testing.T) {
+	for name, content := range map[string]string{
+		"missing secret":    "channel-a\n",
+		"duplicate channel": "channel-a one\nchannel-a two\n",
+		"bad glob":          "[a- secret\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Load(writeKeyfile(t, content))
+			assert.Error(t, err)
+		})
+	}
+
+	_, err := Load(filepath.Join(t.TempDir(), "missing"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// TestWatch tests that a changed keyfile is picked up and an invalid one is ignored.
+func TestWatch(t *testing.T) {
+	path := writeKeyfile(t, "channel-a old\n")
+	keys, err := Load(path)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go keys.Watch(ctx, 5*time.Millisecond)
+
+	replaceKeyfile(t, path, "channel-a rotated\n")
+	assert.Eventually(t, func() bool {
+		secret, _ := keys.Secret("channel-a")
+		return string(secret) == "rotated"
+	}, 2*time.Second, 5*time.Millisecond)
+
+	replaceKeyfile(t, path, "not a valid keyfile line\n")
+	time.Sleep(50 * time.Millisecond)
+	secret, ok := keys.Secret("channel-a")
+	assert.True(t, ok)
+	assert.Equal(t, "rotated", string(secret))
+}
+
+// TestVerifier tests accepted, rejected and replayed signatures.
+func TestVerifier(t *testing.T) {
+	keys, err := Load(writeKeyfile(t, "channel-a secret-a\n"))
+	require.NoError(t, err)
+	verifier := NewVerifier(keys, time.Minute)
+	now := time.Unix(1700000000, 0)
+	verifier.now = func() time.Time { return now }
+
+	body := []byte(`{"metadata":{"channel":"channel-a"}}`)
+	header := signature.Sign([]byte("secret-a"), now, body)
+
+	require.NoError(t, verifier.Verify("channel-a", header, body))
+
+	cases := map[string]struct {
+		channel string
+		header  string
+		cause   error
+	}{
+		"replayed":        {"channel-a", header, nil},
+		"unsigned":        {"channel-a", "", signature.ErrMissing},
+		"malformed":       {"channel-a", "v1=abc", signature.ErrMalformed},
+		"wrong secret":    {"channel-a", signature.Sign([]byte("other"), now, body), signature.ErrMismatch},
+		"expired":         {"channel-a", signature.Sign([]byte("secret-a"), now.Add(-2*time.Minute), body), signature.ErrExpired},
+		"unknown channel": {"channel-b", header, nil},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := verifier.Verify(tc.channel, tc.header, body)
+			assert.ErrorIs(t, err, model.ErrUnauthorized)
+			if tc.cause != nil {
+				assert.True(t, errors.Is(err, tc.cause), err.Error())
+			}
+		})
+	}
+
+	// Once the signature has left the window it is rejected as expired, and it
+	// is forgotten the next time a signature is accepted.
+	now = now.Add(2 * time.Minute)
+	assert.ErrorIs(t, verifier.Verify("channel-a", header, body), signature.ErrExpired)
+	require.NoError(t, verifier.Verify("channel-a", signature.Sign([]byte("secret-a"), now, body), body))
+	assert.Len(t, verifier.seen, 1)
+}
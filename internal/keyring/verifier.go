@@ -0,0 +1,82 @@
+package keyring
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/seansa/rocket-challenge/internal/signature"
+)
+
+// DefaultTolerance is how far a signature timestamp may be from the server clock.
+const DefaultTolerance = 5 * time.Minute
+
+// Verifier checks the signature.Header of incoming messages against the secret
+// of their channel. Besides the timestamp window, it remembers every signature
+// it accepted until that signature expires, so a captured request cannot be
+// replayed within the window either. Producers that retry must sign again.
+type Verifier struct {
+	keys      *Keyring
+	tolerance time.Duration
+	now       func() time.Time
+
+	mutex     sync.Mutex
+	seen      map[string]time.Time
+	lastPrune time.Time
+}
+
+func NewVerifier(keys *Keyring, tolerance time.Duration) *Verifier {
+	if tolerance <= 0 {
+		tolerance = DefaultTolerance
+	}
+	return &Verifier{
+		keys:      keys,
+		tolerance: tolerance,
+		now:       time.Now,
+		seen:      make(map[string]time.Time),
+	}
+}
+
+// Verify returns an error wrapping model.ErrUnauthorized unless header is a
+// fresh, valid signature of body for channel.
+func (v *Verifier) Verify(channel, header string, body []byte) error {
+	secret, ok := v.keys.Secret(channel)
+	if !ok {
+		return fmt.Errorf("no signing key for channel %s: %w", channel, model.ErrUnauthorized)
+	}
+
+	now := v.now()
+	timestamp, err := signature.Verify(secret, header, body, now, v.tolerance)
+	if err != nil {
+		return fmt.Errorf("channel %s: %w: %w", channel, model.ErrUnauthorized, err)
+	}
+
+	// The MAC covers the timestamp and the body, so it identifies the request
+	// however the header is dressed up.
+	key := channel + "/" + strconv.FormatInt(timestamp, 10) + "/" + signature.Compute(secret, timestamp, body)
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.prune(now)
+	if _, replayed := v.seen[key]; replayed {
+		return fmt.Errorf("channel %s: replayed signature: %w", channel, model.ErrUnauthorized)
+	}
+	v.seen[key] = time.Unix(timestamp, 0).Add(v.tolerance)
+	return nil
+}
+
+// prune forgets signatures whose timestamp has left the window, at most once
+// per tolerance period. It must be called with the mutex held.
+func (v *Verifier) prune(now time.Time) {
+	if now.Sub(v.lastPrune) < v.tolerance {
+		return
+	}
+	v.lastPrune = now
+	for key, expires := range v.seen {
+		if now.After(expires) {
+			delete(v.seen, key)
+		}
+	}
+}
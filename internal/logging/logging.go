@@ -0,0 +1,105 @@
+// Package logging configures the log/slog logger the service logs with and
+// carries the correlation ID that ties together the log lines of one message,
+// from the HTTP request that accepted it to the worker that processed it.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+)
+
+// Output formats accepted by New.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// RequestIDKey is the attribute under which the correlation ID is logged.
+const RequestIDKey = "request_id"
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the correlation ID id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the correlation ID carried by ctx, or "".
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewRequestID returns a random 128-bit correlation ID in hex.
+func NewRequestID() string {
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
+
+// ForMessage returns the default logger with the correlation ID, channel and
+// number of msg attached, for logging what happens to it.
+func ForMessage(msg *model.IncomingMessage) *slog.Logger {
+	logger := slog.Default()
+	if msg.RequestID != "" {
+		logger = logger.With(RequestIDKey, msg.RequestID)
+	}
+	return logger.With("channel", msg.Metadata.Channel, "message_number", msg.Metadata.MessageNumber)
+}
+
+// ParseLevel reads "debug", "info", "warn" or "error", case-insensitively.
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q (use debug, info, warn or error)", s)
+	}
+	return level, nil
+}
+
+// New returns a logger writing to w at the given level and format. Records
+// logged with a context, such as slog.InfoContext, get the context's
+// correlation ID attached.
+func New(w io.Writer, level, format string) (*slog.Logger, error) {
+	minimum, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	opts := &slog.HandlerOptions{Level: minimum}
+
+	var handler slog.Handler
+	switch format {
+	case FormatText:
+		handler = slog.NewTextHandler(w, opts)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("invalid log format %q (use %s or %s)", format, FormatText, FormatJSON)
+	}
+	return slog.New(contextHandler{handler}), nil
+}
+
+// contextHandler adds the correlation ID found in the record's context.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		record.AddAttrs(slog.String(RequestIDKey, id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,90 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &record), line)
+		records = append(records, record)
+	}
+	return records
+}
+
+// TestNew tests the level filter, the formats and the correlation ID taken
+// from the context.
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "warn", FormatJSON)
+	require.NoError(t, err)
+
+	ctx := WithRequestID(context.Background(), "req-1")
+	logger.InfoContext(ctx, "filtered")
+	logger.WarnContext(ctx, "kept", "channel", "rocket-a")
+	logger.With("worker", 1).ErrorContext(context.Background(), "no id")
+
+	records := decodeLines(t, &buf)
+	require.Len(t, records, 2)
+	assert.Equal(t, "kept", records[0]["msg"])
+	assert.Equal(t, "req-1", records[0][RequestIDKey])
+	assert.Equal(t, "rocket-a", records[0]["channel"])
+	assert.Equal(t, "no id", records[1]["msg"])
+	assert.NotContains(t, records[1], RequestIDKey)
+	assert.Equal(t, float64(1), records[1]["worker"])
+
+	buf.Reset()
+	logger, err = New(&buf, "DEBUG", FormatText)
+	require.NoError(t, err)
+	logger.DebugContext(ctx, "text")
+	assert.Contains(t, buf.String(), "level=DEBUG msg=text request_id=req-1")
+
+	_, err = New(&buf, "loud", FormatText)
+	assert.ErrorContains(t, err, `invalid log level "loud"`)
+	_, err = New(&buf, "info", "xml")
+	assert.ErrorContains(t, err, `invalid log format "xml"`)
+}
+
+// TestForMessage tests that a message's logger carries its correlation ID.
+func TestForMessage(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "info", FormatJSON)
+	require.NoError(t, err)
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	defer slog.SetDefault(previous)
+
+	msg := model.IncomingMessage{Metadata: model.Metadata{Channel: "rocket-a", MessageNumber: 7}, RequestID: "req-2"}
+	ForMessage(&msg).Info("processed")
+	msg.RequestID = ""
+	ForMessage(&msg).Info("unknown origin")
+
+	records := decodeLines(t, &buf)
+	require.Len(t, records, 2)
+	assert.Equal(t, "req-2", records[0][RequestIDKey])
+	assert.Equal(t, "rocket-a", records[0]["channel"])
+	assert.Equal(t, float64(7), records[0]["message_number"])
+	assert.NotContains(t, records[1], RequestIDKey)
+}
+
+// TestRequestID tests the context helpers and generated IDs.
+func TestRequestID(t *testing.T) {
+	assert.Empty(t, RequestID(context.Background()))
+	assert.Equal(t, "abc", RequestID(WithRequestID(context.Background(), "abc")))
+
+	id := NewRequestID()
+	assert.Len(t, id, 32)
+	assert.NotEqual(t, id, NewRequestID())
+}
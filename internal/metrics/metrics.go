@@ -0,0 +1,399 @@
+// Package metrics is a small, dependency-free implementation of the metric
+// types the service exposes at /metrics in the Prometheus text exposition
+// format (version 0.0.4).
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ContentType is the media type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are latency buckets, in seconds, from 100µs to 10s.
+var DefaultBuckets = []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10}
+
+// Default is the registry every package registers its metrics in and the one
+// served at /metrics.
+var Default = NewRegistry()
+
+// Registry holds metric families and renders them in the text format.
+type Registry struct {
+	mutex    sync.Mutex
+	families map[string]family
+}
+
+type family interface {
+	write(w *bufio.Writer, name string)
+}
+
+type registeredFamily struct {
+	help  string
+	kind  string
+	inner family
+}
+
+func (f registeredFamily) write(w *bufio.Writer, name string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, escapeHelp(f.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, f.kind)
+	f.inner.write(w, name)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]family)}
+}
+
+// register panics on duplicate names, like a mis-wired program should.
+func (r *Registry) register(name, help, kind string, f family) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.families[name]; exists {
+		panic("metrics: duplicate metric " + name)
+	}
+	r.families[name] = registeredFamily{help: help, kind: kind, inner: f}
+}
+
+// Unregister removes a metric family; it is a no-op for unknown names.
+func (r *Registry) Unregister(name string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.families, name)
+}
+
+// WriteText writes every family, sorted by name, in the text exposition format.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mutex.Lock()
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	families := make(map[string]family, len(r.families))
+	for name, f := range r.families {
+		families[name] = f
+	}
+	r.mutex.Unlock()
+
+	sort.Strings(names)
+	bw := bufio.NewWriter(w)
+	for _, name := range names {
+		families[name].write(bw, name)
+	}
+	return bw.Flush()
+}
+
+// Handler serves the registry in the text exposition format.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		_ = r.WriteText(w)
+	})
+}
+
+// Counter is a monotonically increasing value.
+type Counter struct {
+	bits atomic.Uint64
+}
+
+func (r *Registry) NewCounter(name, help string) *Counter {
+	c := &Counter{}
+	r.register(name, help, "counter", c)
+	return c
+}
+
+func (c *Counter) Inc() { c.Add(1) }
+
+// Add increases the counter; negative values are ignored.
+func (c *Counter) Add(v float64) {
+	if v < 0 {
+		return
+	}
+	addFloat(&c.bits, v)
+}
+
+func (c *Counter) Value() float64 { return math.Float64frombits(c.bits.Load()) }
+
+func (c *Counter) write(w *bufio.Writer, name string) {
+	writeSample(w, name, "", c.Value())
+}
+
+// Gauge is a value that can go up and down.
+type Gauge struct {
+	bits atomic.Uint64
+}
+
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	g := &Gauge{}
+	r.register(name, help, "gauge", g)
+	return g
+}
+
+func (g *Gauge) Set(v float64) { g.bits.Store(math.Float64bits(v)) }
+func (g *Gauge) Add(v float64) { addFloat(&g.bits, v) }
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(g.bits.Load())
+}
+
+func (g *Gauge) write(w *bufio.Writer, name string) {
+	writeSample(w, name, "", g.Value())
+}
+
+type gaugeFunc func() float64
+
+func (f gaugeFunc) write(w *bufio.Writer, name string) {
+	writeSample(w, name, "", f())
+}
+
+// NewGaugeFunc registers a gauge whose value is read from fn at scrape time.
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(name, help, "gauge", gaugeFunc(fn))
+}
+
+// NewCounterFunc registers a counter whose value is read from fn at scrape
+// time, for counts kept elsewhere. fn must never decrease.
+func (r *Registry) NewCounterFunc(name, help string, fn func() float64) {
+	r.register(name, help, "counter", gaugeFunc(fn))
+}
+
+type gaugeVecFunc struct {
+	label string
+	fn    func() map[string]float64
+}
+
+func (f gaugeVecFunc) write(w *bufio.Writer, name string) {
+	values := f.fn()
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		writeSample(w, name, formatLabels([]string{f.label}, []string{k}), values[k])
+	}
+}
+
+// NewGaugeVecFunc registers a gauge with one label whose values, keyed by label
+// value, are read from fn at scrape time.
+func (r *Registry) NewGaugeVecFunc(name, help, label string, fn func() map[string]float64) {
+	r.register(name, help, "gauge", gaugeVecFunc{label: label, fn: fn})
+}
+
+// CounterVec is a set of counters partitioned by label values.
+type CounterVec struct {
+	vec[*Counter]
+}
+
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	v := &CounterVec{vec[*Counter]{labels: labels, children: make(map[string]*labeled[*Counter]), newChild: func() *Counter { return &Counter{} }}}
+	r.register(name, help, "counter", v)
+	return v
+}
+
+// WithLabelValues returns the counter for the given label values, in the order
+// the labels were declared.
+func (v *CounterVec) WithLabelValues(values ...string) *Counter {
+	return v.with(values)
+}
+
+func (v *CounterVec) write(w *bufio.Writer, name string) {
+	v.each(func(labels string, c *Counter) {
+		writeSample(w, name, labels, c.Value())
+	})
+}
+
+// Histogram counts observations in cumulative buckets.
+type Histogram struct {
+	upperBounds []float64
+	counts      []atomic.Uint64
+	count       atomic.Uint64
+	sumBits     atomic.Uint64
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	bounds := append([]float64(nil), buckets...)
+	sort.Float64s(bounds)
+	return &Histogram{upperBounds: bounds, counts: make([]atomic.Uint64, len(bounds))}
+}
+
+func (r *Registry) NewHistogram(name, help string, buckets []float64) *Histogram {
+	h := newHistogram(buckets)
+	r.register(name, help, "histogram", h)
+	return h
+}
+
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.upperBounds, v)
+	if i < len(h.counts) {
+		h.counts[i].Add(1)
+	}
+	h.count.Add(1)
+	addFloat(&h.sumBits, v)
+}
+
+// ObserveDuration records the time elapsed since start in seconds.
+func (h *Histogram) ObserveDuration(start time.Time) {
+	h.Observe(time.Since(start).Seconds())
+}
+
+func (h *Histogram) Count() uint64 { return h.count.Load() }
+
+func (h *Histogram) write(w *bufio.Writer, name string) {
+	h.writeLabeled(w, name, nil, nil)
+}
+
+func (h *Histogram) writeLabeled(w *bufio.Writer, name string, labels, values []string) {
+	var cumulative uint64
+	for i, bound := range h.upperBounds {
+		cumulative += h.counts[i].Load()
+		writeSample(w, name+"_bucket", formatLabels(append(labels[:len(labels):len(labels)], "le"), append(values[:len(values):len(values)], formatFloat(bound))), float64(cumulative))
+	}
+	count := h.count.Load()
+	writeSample(w, name+"_bucket", formatLabels(append(labels[:len(labels):len(labels)], "le"), append(values[:len(values):len(values)], "+Inf")), float64(count))
+	writeSample(w, name+"_sum", formatLabels(labels, values), math.Float64frombits(h.sumBits.Load()))
+	writeSample(w, name+"_count", formatLabels(labels, values), float64(count))
+}
+
+// HistogramVec is a set of histograms partitioned by label values.
+type HistogramVec struct {
+	vec[*Histogram]
+}
+
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	v := &HistogramVec{vec[*Histogram]{labels: labels, children: make(map[string]*labeled[*Histogram]), newChild: func() *Histogram { return newHistogram(buckets) }}}
+	r.register(name, help, "histogram", v)
+	return v
+}
+
+func (v *HistogramVec) WithLabelValues(values ...string) *Histogram {
+	return v.with(values)
+}
+
+func (v *HistogramVec) write(w *bufio.Writer, name string) {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	for _, key := range v.sortedKeys() {
+		child := v.children[key]
+		child.metric.writeLabeled(w, name, v.labels, child.values)
+	}
+}
+
+// vec is the label-partitioned storage shared by CounterVec and HistogramVec.
+type vec[M any] struct {
+	mutex    sync.RWMutex
+	labels   []string
+	children map[string]*labeled[M]
+	newChild func() M
+}
+
+type labeled[M any] struct {
+	values []string
+	metric M
+}
+
+func (v *vec[M]) with(values []string) M {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: expected %d label values, got %d", len(v.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+
+	v.mutex.RLock()
+	child, exists := v.children[key]
+	v.mutex.RUnlock()
+	if exists {
+		return child.metric
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if child, exists = v.children[key]; !exists {
+		child = &labeled[M]{values: append([]string(nil), values...), metric: v.newChild()}
+		v.children[key] = child
+	}
+	return child.metric
+}
+
+// sortedKeys must be called with the mutex held.
+func (v *vec[M]) sortedKeys() []string {
+	keys := make([]string, 0, len(v.children))
+	for k := range v.children {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (v *vec[M]) each(fn func(labels string, metric M)) {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	for _, key := range v.sortedKeys() {
+		child := v.children[key]
+		fn(formatLabels(v.labels, child.values), child.metric)
+	}
+}
+
+func addFloat(bits *atomic.Uint64, v float64) {
+	for {
+		old := bits.Load()
+		if bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+func writeSample(w *bufio.Writer, name, labels string, value float64) {
+	w.WriteString(name)
+	w.WriteString(labels)
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	w.WriteByte('\n')
+}
+
+func formatLabels(labels, values []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, label := range labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(label)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabelValue(values[i]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+var (
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabelValue(s string) string { return labelValueEscaper.Replace(s) }
+func escapeHelp(s string) string       { return helpEscaper.Replace(s) }
@@ -0,0 +1,184 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scrape is a parsed exposition: the TYPE of every family and the value of
+// every sample keyed by its name and label set as written.
+type scrape struct {
+	types   map[string]string
+	samples map[string]float64
+}
+
+// parseText parses the text exposition format strictly enough to catch
+// malformed output: every sample must belong to a family declared with HELP
+// and TYPE, and every value must parse as a float.
+func parseText(t *testing.T, r io.Reader) scrape {
+	t.Helper()
+	result := scrape{types: map[string]string{}, samples: map[string]float64{}}
+	helped := map[string]bool{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "# HELP "):
+			fields := strings.SplitN(strings.TrimPrefix(line, "# HELP "), " ", 2)
+			require.Len(t, fields, 2, "HELP line %q", line)
+			helped[fields[0]] = true
+		case strings.HasPrefix(line, "# TYPE "):
+			fields := strings.Fields(strings.TrimPrefix(line, "# TYPE "))
+			require.Len(t, fields, 2, "TYPE line %q", line)
+			require.True(t, helped[fields[0]], "TYPE before HELP for %s", fields[0])
+			require.Contains(t, []string{"counter", "gauge", "histogram"}, fields[1])
+			result.types[fields[0]] = fields[1]
+		default:
+			i := strings.LastIndexByte(line, ' ')
+			require.Positive(t, i, "sample line %q", line)
+			key, raw := line[:i], line[i+1:]
+			value, err := strconv.ParseFloat(raw, 64)
+			require.NoError(t, err, "sample line %q", line)
+
+			name := key
+			if j := strings.IndexByte(key, '{'); j >= 0 {
+				require.True(t, strings.HasSuffix(key, "}"), "unterminated labels in %q", line)
+				name = key[:j]
+			}
+			family := name
+			for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+				if base, ok := strings.CutSuffix(name, suffix); ok && result.types[base] == "histogram" {
+					family = base
+				}
+			}
+			require.Contains(t, result.types, family, "sample %q has no TYPE", line)
+			result.samples[key] = value
+		}
+	}
+	require.NoError(t, scanner.Err())
+	return result
+}
+
+func render(t *testing.T, r *Registry) scrape {
+	t.Helper()
+	var buf strings.Builder
+	require.NoError(t, r.WriteText(&buf))
+	return parseText(t, strings.NewReader(buf.String()))
+}
+
+// TestCounterAndGauge tests counters, gauges and gauge funcs.
+func TestCounterAndGauge(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("test_total", "A counter.")
+	g := r.NewGauge("test_gauge", "A gauge.")
+	r.NewGaugeFunc("test_func", "A gauge func.", func() float64 { return 42 })
+	r.NewCounterFunc("test_func_total", "A counter func.", func() float64 { return 5 })
+
+	c.Inc()
+	c.Add(2.5)
+	c.Add(-1) // ignored
+	g.Set(10)
+	g.Add(-3)
+
+	s := render(t, r)
+	assert.Equal(t, "counter", s.types["test_total"])
+	assert.Equal(t, "gauge", s.types["test_gauge"])
+	assert.Equal(t, 3.5, s.samples["test_total"])
+	assert.Equal(t, 7.0, s.samples["test_gauge"])
+	assert.Equal(t, 42.0, s.samples["test_func"])
+	assert.Equal(t, "counter", s.types["test_func_total"])
+	assert.Equal(t, 5.0, s.samples["test_func_total"])
+}
+
+// TestVecLabels tests label rendering, ordering and escaping.
+func TestVecLabels(t *testing.T) {
+	r := NewRegistry()
+	v := r.NewCounterVec("test_total", "Labelled.", "status", "worker")
+	v.WithLabelValues("ok", "1").Inc()
+	v.WithLabelValues("ok", "1").Inc()
+	v.WithLabelValues(`a "quoted"\ value`+"\n", "2").Inc()
+	r.NewGaugeVecFunc("test_state", "By state.", "state", func() map[string]float64 {
+		return map[string]float64{"b": 2, "a": 1}
+	})
+
+	var buf strings.Builder
+	require.NoError(t, r.WriteText(&buf))
+	out := buf.String()
+	assert.Contains(t, out, `test_total{status="a \"quoted\"\\ value\n",worker="2"} 1`)
+	assert.Less(t, strings.Index(out, `test_state{state="a"}`), strings.Index(out, `test_state{state="b"}`))
+
+	s := parseText(t, strings.NewReader(out))
+	assert.Equal(t, 2.0, s.samples[`test_total{status="ok",worker="1"}`])
+	assert.Panics(t, func() { v.WithLabelValues("only-one") })
+}
+
+// TestHistogram tests cumulative buckets, sum and count.
+func TestHistogram(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogram("test_seconds", "Latency.", []float64{1, 0.1})
+	hv := r.NewHistogramVec("test_op_seconds", "Latency by op.", []float64{1}, "op")
+	for _, v := range []float64{0.05, 0.1, 0.5, 2} {
+		h.Observe(v)
+	}
+	hv.WithLabelValues("get").Observe(0.5)
+
+	s := render(t, r)
+	assert.Equal(t, "histogram", s.types["test_seconds"])
+	assert.Equal(t, 2.0, s.samples[`test_seconds_bucket{le="0.1"}`], "upper bounds are inclusive")
+	assert.Equal(t, 3.0, s.samples[`test_seconds_bucket{le="1"}`])
+	assert.Equal(t, 4.0, s.samples[`test_seconds_bucket{le="+Inf"}`])
+	assert.Equal(t, 4.0, s.samples["test_seconds_count"])
+	assert.InDelta(t, 2.65, s.samples["test_seconds_sum"], 1e-9)
+	assert.Equal(t, 1.0, s.samples[`test_op_seconds_bucket{op="get",le="1"}`])
+	assert.Equal(t, 1.0, s.samples[`test_op_seconds_count{op="get"}`])
+}
+
+// TestConcurrentUpdates tests that concurrent updates are not lost.
+func TestConcurrentUpdates(t *testing.T) {
+	r := NewRegistry()
+	v := r.NewCounterVec("test_total", "Concurrent.", "worker")
+	h := r.NewHistogram("test_seconds", "Concurrent.", DefaultBuckets)
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 1000 {
+				v.WithLabelValues(strconv.Itoa(i % 2)).Add(0.5)
+				h.Observe(0.001)
+			}
+		}()
+	}
+	wg.Wait()
+
+	s := render(t, r)
+	assert.Equal(t, 2000.0, s.samples[`test_total{worker="0"}`])
+	assert.Equal(t, 8000.0, s.samples["test_seconds_count"])
+}
+
+// TestRegistry tests duplicate registration and the HTTP handler.
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("test_total", "A counter.").Inc()
+	assert.Panics(t, func() { r.NewGauge("test_total", "Duplicate.") })
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+	assert.Equal(t, 1.0, parseText(t, w.Body).samples["test_total"])
+
+	r.Unregister("test_total")
+	assert.Empty(t, render(t, r).samples)
+}
@@ -0,0 +1,38 @@
+package model
+
+import "errors"
+
+// Sentinel errors shared by the repository, service and controller layers.
+// Callers should wrap them with context (fmt.Errorf("...: %w", ErrX)) and
+// match them with errors.Is; the HTTP layer maps each one to a stable
+// problem code.
+var (
+	ErrNotFound       = errors.New("not found")
+	ErrInvalidPayload = errors.New("invalid payload")
+	ErrConflict       = errors.New("conflict")
+	ErrQueueFull      = errors.New("message queue full")
+	ErrNotAcceptable  = errors.New("not acceptable")
+	ErrUnauthorized   = errors.New("unauthorized")
+	ErrRateLimited    = errors.New("rate limit exceeded")
+)
+
+// Problem represents an RFC 7807 "application/problem+json" error response.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+	// Queue is set on QUEUE_FULL problems so that producers can back off.
+	Queue *QueueStatus `json:"queue,omitempty"`
+}
+
+// QueueStatus describes the message queue when it rejects a message.
+type QueueStatus struct {
+	Depth    int `json:"depth"`
+	Capacity int `json:"capacity"`
+	// DrainRate is how many messages per second the workers recently took
+	// off the queue.
+	DrainRate float64 `json:"drainRate"`
+}
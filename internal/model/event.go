@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+type EventKind string
+
+const (
+	// EventRocketUpdated is published every time a message changes a rocket's state.
+	EventRocketUpdated EventKind = "rocket_updated"
+	// EventContactLost is published when a rocket is flagged contactLost. It
+	// carries no message.
+	EventContactLost EventKind = "contact_lost"
+	// EventContactRestored is published when a message from a rocket flagged
+	// contactLost arrives, ahead of the rocket_updated event of that message.
+	EventContactRestored EventKind = "contact_restored"
+	// EventResync tells a subscriber that events were dropped (it was too slow,
+	// or asked to resume from an event that is no longer retained) and that it
+	// should re-read the current state before applying further updates.
+	EventResync EventKind = "resync"
+)
+
+// RocketEvent describes a rocket state change together with the message that
+// caused it. Resync markers carry no rocket or message, and contact lost
+// events no message.
+type RocketEvent struct {
+	ID      uint64           `json:"id,omitempty"`
+	Kind    EventKind        `json:"kind"`
+	Time    time.Time        `json:"time"`
+	Rocket  *Rocket          `json:"rocket,omitempty"`
+	Message *IncomingMessage `json:"message,omitempty"`
+}
@@ -0,0 +1,81 @@
+package model
+
+import "time"
+
+// Health check results.
+const (
+	HealthOK      = "ok"
+	HealthFailing = "failing"
+)
+
+// Worker states reported by the liveness probe.
+const (
+	WorkerIdle    = "idle"
+	WorkerBusy    = "busy"
+	WorkerStopped = "stopped"
+)
+
+// HealthCheck is the result of a single probe check.
+type HealthCheck struct {
+	Status string `json:"status" example:"ok"`
+	Detail string `json:"detail,omitempty" example:"12/1000 messages queued (1.2%)"`
+}
+
+// HealthReport is the body of /healthz and /readyz. Status is failing as soon
+// as one check fails.
+type HealthReport struct {
+	Status  string                 `json:"status" example:"ok"`
+	Checks  map[string]HealthCheck `json:"checks"`
+	Workers []WorkerHealth         `json:"workers,omitempty"`
+}
+
+// WorkerHealth is the last heartbeat of a message processing worker.
+type WorkerHealth struct {
+	ID            int        `json:"id"`
+	State         string     `json:"state" example:"idle"`
+	LastHeartbeat time.Time  `json:"lastHeartbeat"`
+	BusySince     *time.Time `json:"busySince,omitempty"`
+	Stalled       bool       `json:"stalled"`
+}
+
+// WorkerPoolStatus describes the message processing worker pool.
+type WorkerPoolStatus struct {
+	Count     int              `json:"count" example:"5"`
+	Max       int              `json:"max" example:"64"`
+	Autoscale *AutoscaleStatus `json:"autoscale,omitempty"`
+	Workers   []WorkerHealth   `json:"workers"`
+}
+
+// AutoscaleStatus is the autoscaler configuration, present when it runs.
+type AutoscaleStatus struct {
+	Min       int `json:"min"`
+	Max       int `json:"max"`
+	HighWater int `json:"highWater"`
+}
+
+// WorkerPoolRequest sets the number of workers.
+type WorkerPoolRequest struct {
+	Count int `json:"count" binding:"required,min=1" example:"8"`
+}
+
+// Processing states reported by GET /admin/processing.
+const (
+	ProcessingRunning = "running"
+	ProcessingPaused  = "paused"
+)
+
+// ProcessingStatus describes whether the workers take messages off the queue.
+type ProcessingStatus struct {
+	Status string `json:"status" example:"paused"`
+	// PausedSince is set while processing is paused as a whole.
+	PausedSince *time.Time `json:"pausedSince,omitempty"`
+	// PausedChannels lists the channels paused on their own.
+	PausedChannels []ChannelPause `json:"pausedChannels"`
+	Queue          QueueStatus    `json:"queue"`
+}
+
+// ChannelPause is a channel whose messages are held back in the queue.
+type ChannelPause struct {
+	Channel     string    `json:"channel" example:"193270a9-c9cf-404a-8f83-838e71d9ae67"`
+	PausedSince time.Time `json:"pausedSince"`
+}
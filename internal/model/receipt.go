@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+// Receipt states. A receipt starts queued, moves to processing when a worker
+// picks the message up, and ends in one of the other states.
+const (
+	ReceiptQueued     = "queued"
+	ReceiptProcessing = "processing"
+	ReceiptProcessed  = "processed"
+	ReceiptIgnoredOld = "ignored_old"
+	ReceiptDuplicate  = "duplicate"
+	ReceiptFailed     = "failed"
+)
+
+// Receipt tracks an accepted message through its lifecycle, so that producers
+// can find out what happened to it after the 202 response.
+type Receipt struct {
+	ID            string     `json:"id" example:"9f86d081884c7d659a2feaa0c55ad015"`
+	RequestID     string     `json:"requestId,omitempty"`
+	Channel       string     `json:"channel" example:"193270a9-c9cf-404a-8f83-838e71d9ae67"`
+	MessageNumber int        `json:"messageNumber" example:"7"`
+	Status        string     `json:"status" example:"processed" enums:"queued,processing,processed,ignored_old,duplicate,failed"`
+	Error         string     `json:"error,omitempty"`
+	QueuedAt      time.Time  `json:"queuedAt"`
+	ProcessingAt  *time.Time `json:"processingAt,omitempty"`
+	CompletedAt   *time.Time `json:"completedAt,omitempty"`
+}
@@ -9,6 +9,11 @@ import (
 type IncomingMessage struct {
 	Metadata Metadata        `json:"metadata"`
 	Message  json.RawMessage `json:"message"`
+	// RequestID correlates the log lines of the message from ingestion to
+	// processing. It is assigned on arrival and is not part of the payload.
+	RequestID string `json:"-"`
+	// ReceiptID identifies the message's Receipt, when one was issued.
+	ReceiptID string `json:"-"`
 }
 
 // Metadata represents the 'metadata' section of a rocket message.
@@ -3,7 +3,7 @@ package model
 import (
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 )
 
@@ -17,20 +17,37 @@ const (
 	RocketMissionChanged MessageType = "RocketMissionChanged"
 )
 
+// MessageTypes lists every known message type.
+var MessageTypes = []MessageType{RocketLaunched, RocketSpeedIncreased, RocketSpeedDecreased, RocketExploded, RocketMissionChanged}
+
 const (
 	Aborted string = "ABORTED"
 )
 
+// Lifecycle statuses of a rocket, as reported by Rocket.Status.
+const (
+	StatusAwaitingLaunch = "awaiting_launch"
+	StatusInFlight       = "in_flight"
+	StatusExploded       = "exploded"
+)
+
 // Rocket represents the current state of a rocket.
 type Rocket struct {
-	Channel         string    `json:"channel"`
-	Type            string    `json:"type,omitempty"`
-	Speed           int       `json:"speed"`
-	Mission         string    `json:"mission,omitempty"`
-	Exploded        bool      `json:"exploded"`
-	ExplosionReason string    `json:"explosionReason,omitempty"`
-	MessageNumber   int       `json:"-"`
-	MessageTime     time.Time `json:"-"`
+	Channel         string `json:"channel"`
+	Type            string `json:"type,omitempty"`
+	Speed           int    `json:"speed"`
+	Mission         string `json:"mission,omitempty"`
+	Exploded        bool   `json:"exploded"`
+	ExplosionReason string `json:"explosionReason,omitempty"`
+	// ContactLost is set once the rocket has been silent for longer than the
+	// configured threshold, from ContactLostSince, and cleared by its next
+	// message.
+	ContactLost      bool       `json:"contactLost"`
+	ContactLostSince *time.Time `json:"contactLostSince,omitempty"`
+	MessageNumber    int        `json:"-"`
+	MessageTime      time.Time  `json:"-"`
+	// ReceivedAt is when the rocket was last heard from.
+	ReceivedAt time.Time `json:"-"`
 }
 
 // NewRocket creates a new Rocket instance with default values.
@@ -46,6 +63,19 @@ func (r Rocket) GetKey() string {
 	return r.Channel
 }
 
+// Status returns the lifecycle status of the rocket. A rocket is awaiting launch
+// until a RocketLaunched message has set its type.
+func (r Rocket) Status() string {
+	switch {
+	case r.Exploded:
+		return StatusExploded
+	case r.Type == "":
+		return StatusAwaitingLaunch
+	default:
+		return StatusInFlight
+	}
+}
+
 // UpdateState Contains the logic for each message type.
 func (r *Rocket) UpdateState(messageType MessageType, messageData []byte) error {
 	switch messageType {
@@ -59,14 +89,14 @@ func (r *Rocket) UpdateState(messageType MessageType, messageData []byte) error
 		r.Mission = launchMsg.Mission
 		r.Exploded = false
 		r.ExplosionReason = ""
-		log.Printf("Rocket %s launched: Type=%s, Speed=%d, Mission=%s", r.Channel, r.Type, r.Speed, r.Mission)
+		slog.Debug("Rocket launched", "channel", r.Channel, "type", r.Type, "speed", r.Speed, "mission", r.Mission)
 	case RocketSpeedIncreased:
 		var speedMsg SpeedChangedMessage
 		if err := json.Unmarshal(messageData, &speedMsg); err != nil {
 			return fmt.Errorf("unmarshal error - RocketSpeedIncreasedMessage: %w", err)
 		}
 		r.Speed += speedMsg.By
-		log.Printf("Rocket %s speed increased by %d to %d", r.Channel, speedMsg.By, r.Speed)
+		slog.Debug("Rocket speed increased", "channel", r.Channel, "by", speedMsg.By, "speed", r.Speed)
 	case RocketSpeedDecreased:
 		var speedMsg SpeedChangedMessage
 		if err := json.Unmarshal(messageData, &speedMsg); err != nil {
@@ -74,7 +104,7 @@ func (r *Rocket) UpdateState(messageType MessageType, messageData []byte) error
 		}
 		r.Speed -= speedMsg.By
 
-		log.Printf("Rocket %s speed decreased by %d to %d", r.Channel, speedMsg.By, r.Speed)
+		slog.Debug("Rocket speed decreased", "channel", r.Channel, "by", speedMsg.By, "speed", r.Speed)
 	case RocketExploded:
 		var explodedMsg RocketExplodedMessage
 		if err := json.Unmarshal(messageData, &explodedMsg); err != nil {
@@ -84,16 +114,16 @@ func (r *Rocket) UpdateState(messageType MessageType, messageData []byte) error
 		r.ExplosionReason = explodedMsg.Reason
 		r.Speed = 0         // Speed becomes 0 upon explosion
 		r.Mission = Aborted // Mission is aborted
-		log.Printf("Rocket %s exploded! Reason: %s", r.Channel, r.ExplosionReason)
+		slog.Debug("Rocket exploded", "channel", r.Channel, "reason", r.ExplosionReason)
 	case RocketMissionChanged:
 		var missionMsg MissionChangedMessage
 		if err := json.Unmarshal(messageData, &missionMsg); err != nil {
 			return fmt.Errorf("unmarshal error - RocketMissionChangedMessage: %w", err)
 		}
 		r.Mission = missionMsg.NewMission
-		log.Printf("Rocket %s mission changed to %s", r.Channel, r.Mission)
+		slog.Debug("Rocket mission changed", "channel", r.Channel, "mission", r.Mission)
 	default:
-		log.Printf("Unknown message type received for %s: %s", r.Channel, messageType)
+		slog.Warn("Unknown message type", "channel", r.Channel, "message_type", messageType)
 	}
 	return nil
 }
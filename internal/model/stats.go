@@ -0,0 +1,21 @@
+package model
+
+// FleetStats is the aggregate view of the whole fleet returned by GET /stats.
+type FleetStats struct {
+	TotalRockets     int            `json:"totalRockets"`
+	ByStatus         map[string]int `json:"byStatus"`
+	ByType           map[string]int `json:"byType"`
+	ByMission        map[string]int `json:"byMission"`
+	AverageSpeed     float64        `json:"averageSpeed"`
+	MaxSpeed         int            `json:"maxSpeed"`
+	ExplosionReasons map[string]int `json:"explosionReasons"`
+	Ingestion        IngestionStats `json:"ingestion"`
+}
+
+// IngestionStats counts the outcome of every message handed to the service.
+type IngestionStats struct {
+	Processed  int64 `json:"processed"`
+	IgnoredOld int64 `json:"ignoredOld"`
+	Duplicates int64 `json:"duplicates"`
+	Errors     int64 `json:"errors"`
+}
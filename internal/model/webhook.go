@@ -0,0 +1,86 @@
+package model
+
+import "time"
+
+// Webhook event filters. A webhook subscribes to one or more of them.
+const (
+	WebhookEventLaunched        = "launched"
+	WebhookEventSpeedChanged    = "speed_changed"
+	WebhookEventExploded        = "exploded"
+	WebhookEventMissionChanged  = "mission_changed"
+	WebhookEventContactLost     = "contact_lost"
+	WebhookEventContactRestored = "contact_restored"
+	WebhookEventAll             = "*"
+)
+
+// WebhookEventOf returns the webhook event filter matching event.
+func WebhookEventOf(event RocketEvent) string {
+	switch event.Kind {
+	case EventContactLost:
+		return WebhookEventContactLost
+	case EventContactRestored:
+		return WebhookEventContactRestored
+	}
+	if event.Message == nil {
+		return ""
+	}
+	return WebhookEventFor(event.Message.Metadata.MessageType)
+}
+
+// WebhookEventFor returns the webhook event filter matching a message type, or
+// an empty string for unknown types.
+func WebhookEventFor(messageType MessageType) string {
+	switch messageType {
+	case RocketLaunched:
+		return WebhookEventLaunched
+	case RocketSpeedIncreased, RocketSpeedDecreased:
+		return WebhookEventSpeedChanged
+	case RocketExploded:
+		return WebhookEventExploded
+	case RocketMissionChanged:
+		return WebhookEventMissionChanged
+	default:
+		return ""
+	}
+}
+
+// WebhookRequest is the body of POST /webhooks. When Secret is empty one is
+// generated and returned once in the response.
+type WebhookRequest struct {
+	URL      string   `json:"url" binding:"required"`
+	Events   []string `json:"events" binding:"required"`
+	Channels []string `json:"channels,omitempty"`
+	Secret   string   `json:"secret,omitempty"`
+}
+
+// Webhook is a registered outbound webhook endpoint.
+type Webhook struct {
+	ID                  string     `json:"id"`
+	URL                 string     `json:"url"`
+	Events              []string   `json:"events"`
+	Channels            []string   `json:"channels,omitempty"`
+	Secret              string     `json:"secret,omitempty"`
+	Enabled             bool       `json:"enabled"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	CreatedAt           time.Time  `json:"createdAt"`
+	DisabledAt          *time.Time `json:"disabledAt,omitempty"`
+}
+
+// WebhookPayload is the JSON body POSTed to webhook endpoints.
+type WebhookPayload struct {
+	Event string      `json:"event"`
+	Data  RocketEvent `json:"data"`
+}
+
+// WebhookDelivery records one delivery attempt to a webhook endpoint.
+type WebhookDelivery struct {
+	ID         string    `json:"id"`
+	EventID    uint64    `json:"eventId"`
+	Event      string    `json:"event"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Success    bool      `json:"success"`
+	DurationMs int64     `json:"durationMs"`
+	Time       time.Time `json:"time"`
+}
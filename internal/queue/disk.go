@@ -0,0 +1,450 @@
+package queue
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+)
+
+// Disk queue defaults.
+const (
+	DefaultSegmentSize    = 16 * 1024 * 1024
+	DefaultCommitInterval = 100 * time.Millisecond
+	DefaultMaxAttempts    = 5
+	DefaultRetryBackoff   = time.Second
+)
+
+const (
+	segmentSuffix = ".seg"
+	offsetFile    = "offset"
+	// DeadLetterFile, in the queue's directory, receives the messages that
+	// failed every attempt, one JSON object per line, as replay reads them.
+	DeadLetterFile = "dead-letter.jsonl"
+	// recordHeaderSize is the length and CRC-32 that precede every record.
+	recordHeaderSize = 8
+	maxRecordSize    = 16 * 1024 * 1024
+)
+
+// DiskOptions configure OpenDisk.
+type DiskOptions struct {
+	// Capacity is the number of messages waiting for a worker above which
+	// enqueueing fails.
+	Capacity int
+	// SegmentSize is the size in bytes after which a new segment file is
+	// started. Segments are deleted once every message in them is acknowledged.
+	SegmentSize int64
+	// Sync makes every enqueue wait for the message to reach the disk, so
+	// that it also survives a power failure, not only a crash.
+	Sync bool
+	// CommitInterval is how often the acknowledged offset is persisted. Up to
+	// that much acknowledged work is delivered again after a crash.
+	CommitInterval time.Duration
+	// MaxAttempts is how often a message is delivered before it is moved to
+	// the dead-letter file when every delivery is nacked. The attempts are
+	// RetryBackoff apart, doubling up to a minute.
+	MaxAttempts  int
+	RetryBackoff time.Duration
+	// Lanes are the priority lanes messages wait in.
+	Lanes Lanes
+}
+
+// entry is the record written for a message. It keeps the correlation and
+// receipt IDs that the message's JSON leaves out.
+type entry struct {
+	Metadata  model.Metadata  `json:"metadata"`
+	Message   json.RawMessage `json:"message"`
+	RequestID string          `json:"requestId,omitempty"`
+	ReceiptID string          `json:"receiptId,omitempty"`
+}
+
+type segment struct {
+	base uint64
+	path string
+}
+
+// Disk is a Queue persisted as a segmented append-only log in a directory.
+// Every message gets the next offset in the log. The consumer offset, the
+// first offset not yet acknowledged, is stored next to the segments, and
+// everything from it on is delivered again when the queue is reopened.
+//
+// Messages are acknowledged out of order by concurrent workers, so the
+// consumer offset only moves past a message once it and every message before
+// it are acknowledged. A nacked message is delivered again, and after
+// MaxAttempts it is appended to the dead-letter file and counts as
+// acknowledged, so that it never holds the offset back for long.
+type Disk struct {
+	dispatcher
+
+	dir  string
+	opts DiskOptions
+
+	// The fields below are guarded by the dispatcher's mutex.
+	segments   []segment
+	active     *os.File
+	activeSize int64
+	// acked holds acknowledged offsets above committed.
+	acked     map[uint64]bool
+	committed uint64
+	persisted uint64
+
+	wg sync.WaitGroup
+}
+
+// OpenDisk opens or creates the queue in dir and queues every message that
+// was not acknowledged when it was last closed.
+func OpenDisk(dir string, opts DiskOptions) (*Disk, error) {
+	if opts.SegmentSize <= 0 {
+		opts.SegmentSize = DefaultSegmentSize
+	}
+	if opts.CommitInterval <= 0 {
+		opts.CommitInterval = DefaultCommitInterval
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = DefaultRetryBackoff
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	q := &Disk{
+		dir:   dir,
+		opts:  opts,
+		acked: make(map[uint64]bool),
+	}
+	q.init(opts.Capacity, opts.Lanes)
+	q.store = q.append
+	q.settled = q.ack
+	q.retries, q.retryBackoff = opts.MaxAttempts-1, opts.RetryBackoff
+	q.giveUp = q.deadLetter
+	if err := q.recover(); err != nil {
+		return nil, err
+	}
+	if waiting := q.sched.len(); waiting > 0 {
+		slog.Info("Redelivering unacknowledged messages", "dir", dir, "messages", waiting, "from_offset", q.committed)
+	}
+
+	q.wg.Add(2)
+	go func() {
+		defer q.wg.Done()
+		q.pump()
+	}()
+	go q.commitLoop()
+	return q, nil
+}
+
+// recover reads the consumer offset and the segments, and opens the last
+// segment for appending.
+func (q *Disk) recover() error {
+	committed, err := q.readOffset()
+	if err != nil {
+		return err
+	}
+	q.committed, q.persisted, q.next = committed, committed, committed
+
+	if q.segments, err = q.listSegments(); err != nil {
+		return err
+	}
+	for i, seg := range q.segments {
+		last := i == len(q.segments)-1
+		// Segments are contiguous, and only removed once the offset has
+		// moved past them.
+		if (i == 0 && seg.base > q.committed) || (i > 0 && seg.base != q.next) {
+			return fmt.Errorf("queue segment %s: expected offset %d", seg.path, q.next)
+		}
+		q.next = seg.base
+		valid, err := q.readSegment(seg)
+		if err != nil && !last {
+			return err
+		}
+		if err != nil {
+			// Only the last segment can end in a torn write, from a crash
+			// in the middle of an append. Nothing after it was acknowledged.
+			slog.Warn("Truncating torn queue segment", "segment", seg.path, "error", err, "size", valid)
+			if err := os.Truncate(seg.path, valid); err != nil {
+				return err
+			}
+		}
+		if last {
+			q.activeSize = valid
+		}
+	}
+	if q.next < q.committed {
+		return fmt.Errorf("queue offset %d is past the end of the log at %d", q.committed, q.next)
+	}
+
+	if len(q.segments) == 0 {
+		return q.rotate()
+	}
+	q.active, err = os.OpenFile(q.segments[len(q.segments)-1].path, os.O_WRONLY|os.O_APPEND, 0o644)
+	return err
+}
+
+func (q *Disk) readOffset() (uint64, error) {
+	data, err := os.ReadFile(filepath.Join(q.dir, offsetFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	offset, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("queue offset file: %w", err)
+	}
+	return offset, nil
+}
+
+func (q *Disk) listSegments() ([]segment, error) {
+	names, err := filepath.Glob(filepath.Join(q.dir, "*"+segmentSuffix))
+	if err != nil {
+		return nil, err
+	}
+	segments := make([]segment, 0, len(names))
+	for _, name := range names {
+		base, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(name), segmentSuffix), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected file in queue directory: %s", name)
+		}
+		segments = append(segments, segment{base: base, path: name})
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].base < segments[j].base })
+	return segments, nil
+}
+
+// readSegment queues the unacknowledged messages of seg and advances next past
+// it. It returns the size of the valid prefix of the file, which is all of
+// it unless err is set.
+func (q *Disk) readSegment(seg segment) (valid int64, err error) {
+	file, err := os.Open(seg.path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+	for {
+		msg, size, err := readRecord(r)
+		if errors.Is(err, io.EOF) {
+			return valid, nil
+		}
+		if err != nil {
+			return valid, fmt.Errorf("queue segment %s at offset %d: %w", seg.path, q.next, err)
+		}
+		if q.next >= q.committed {
+			q.sched.push(q.next, msg)
+		}
+		q.next++
+		valid += size
+	}
+}
+
+// readRecord reads one record and returns io.EOF at a clean end of file.
+func readRecord(r io.Reader) (model.IncomingMessage, int64, error) {
+	var header [recordHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return model.IncomingMessage{}, 0, err
+	}
+	length := binary.BigEndian.Uint32(header[:4])
+	if length > maxRecordSize {
+		return model.IncomingMessage{}, 0, fmt.Errorf("record length %d exceeds %d", length, maxRecordSize)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return model.IncomingMessage{}, 0, io.ErrUnexpectedEOF
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:]) {
+		return model.IncomingMessage{}, 0, errors.New("record checksum mismatch")
+	}
+	var e entry
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return model.IncomingMessage{}, 0, err
+	}
+	msg := model.IncomingMessage{Metadata: e.Metadata, Message: e.Message, RequestID: e.RequestID, ReceiptID: e.ReceiptID}
+	return msg, recordHeaderSize + int64(length), nil
+}
+
+func encodeRecord(msg model.IncomingMessage) ([]byte, error) {
+	payload, err := json.Marshal(entry{Metadata: msg.Metadata, Message: msg.Message, RequestID: msg.RequestID, ReceiptID: msg.ReceiptID})
+	if err != nil {
+		return nil, err
+	}
+	record := make([]byte, recordHeaderSize, recordHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(record[:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:], crc32.ChecksumIEEE(payload))
+	return append(record, payload...), nil
+}
+
+// rotate starts a new segment at the next offset. It must be called with the
+// mutex held.
+func (q *Disk) rotate() error {
+	seg := segment{base: q.next, path: filepath.Join(q.dir, fmt.Sprintf("%020d%s", q.next, segmentSuffix))}
+	file, err := os.OpenFile(seg.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if q.active != nil {
+		q.active.Close()
+	}
+	q.segments = append(q.segments, seg)
+	q.active, q.activeSize = file, 0
+	return nil
+}
+
+// append writes msg to the log at the next offset. It must be called with the
+// mutex held.
+func (q *Disk) append(msg model.IncomingMessage) error {
+	record, err := encodeRecord(msg)
+	if err != nil {
+		return err
+	}
+	if q.activeSize > 0 && q.activeSize+int64(len(record)) > q.opts.SegmentSize {
+		if err := q.rotate(); err != nil {
+			return err
+		}
+	}
+	if _, err := q.active.Write(record); err != nil {
+		// Drop the partial record, so that later appends stay readable.
+		_ = q.active.Truncate(q.activeSize)
+		return fmt.Errorf("writing to queue: %w", err)
+	}
+	if q.opts.Sync {
+		if err := q.active.Sync(); err != nil {
+			return fmt.Errorf("syncing queue: %w", err)
+		}
+	}
+	q.activeSize += int64(len(record))
+	return nil
+}
+
+// ack records an acknowledgement and advances the consumer offset past every
+// message acknowledged so far without a gap. It must be called with the mutex
+// held.
+func (q *Disk) ack(offset uint64, processed bool) {
+	if !processed {
+		return
+	}
+	if offset < q.committed {
+		return
+	}
+	q.acked[offset] = true
+	for q.acked[q.committed] {
+		delete(q.acked, q.committed)
+		q.committed++
+	}
+}
+
+// deadLetter appends msg to the dead-letter file. When that fails the message
+// keeps holding the offset back, and is delivered again after a restart. It
+// must be called with the mutex held.
+func (q *Disk) deadLetter(msg model.IncomingMessage, attempts int) bool {
+	line, err := json.Marshal(entry{Metadata: msg.Metadata, Message: msg.Message, RequestID: msg.RequestID, ReceiptID: msg.ReceiptID})
+	if err == nil {
+		err = appendLine(filepath.Join(q.dir, DeadLetterFile), append(line, '\n'))
+	}
+	if err != nil {
+		slog.Error("Dead-lettering message failed", "dir", q.dir, "channel", msg.Metadata.Channel, "message_number", msg.Metadata.MessageNumber, "error", err)
+		return false
+	}
+	slog.Warn("Message dead-lettered", "dir", q.dir, "channel", msg.Metadata.Channel, "message_number", msg.Metadata.MessageNumber, "attempts", attempts)
+	return true
+}
+
+func appendLine(path string, line []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(line)
+	return errors.Join(err, file.Close())
+}
+
+func (q *Disk) commitLoop() {
+	defer q.wg.Done()
+	ticker := time.NewTicker(q.opts.CommitInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := q.commit(); err != nil {
+				slog.Error("Persisting queue offset failed", "dir", q.dir, "error", err)
+			}
+		case <-q.done:
+			return
+		}
+	}
+}
+
+// commit persists the consumer offset and deletes the segments that only hold
+// acknowledged messages. It is only called by one goroutine at a time.
+func (q *Disk) commit() error {
+	q.mutex.Lock()
+	committed := q.committed
+	q.mutex.Unlock()
+	if committed == q.persisted {
+		return nil
+	}
+
+	path := filepath.Join(q.dir, offsetFile)
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(file, "%d\n", committed)
+	if err == nil && q.opts.Sync {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		return err
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.persisted = committed
+	// A segment is done when the next one starts at or below the offset;
+	// the last segment is the one being appended to and is always kept.
+	for len(q.segments) > 1 && q.segments[1].base <= committed {
+		if err := os.Remove(q.segments[0].path); err != nil {
+			return err
+		}
+		q.segments = q.segments[1:]
+	}
+	return nil
+}
+
+// Close stops deliveries and persists the consumer offset. Messages that were
+// not acknowledged are delivered again when the queue is reopened.
+func (q *Disk) Close() error {
+	if !q.shut() {
+		return nil
+	}
+	q.wg.Wait()
+	err := q.commit()
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return errors.Join(err, q.active.Close())
+}
@@ -0,0 +1,211 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openTestDisk(t *testing.T, dir string, opts DiskOptions) *Disk {
+	t.Helper()
+	if opts.Capacity == 0 {
+		opts.Capacity = 100
+	}
+	q, err := OpenDisk(dir, opts)
+	require.NoError(t, err)
+	return q
+}
+
+func segmentFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	names, err := filepath.Glob(filepath.Join(dir, "*"+segmentSuffix))
+	require.NoError(t, err)
+	return names
+}
+
+// TestDisk_RedeliversUnacknowledged tests that everything from the consumer
+// offset on is delivered again, IDs included, after a restart, including a
+// message waiting to be retried.
+func TestDisk_RedeliversUnacknowledged(t *testing.T) {
+	dir := t.TempDir()
+	q := openTestDisk(t, dir, DiskOptions{RetryBackoff: time.Hour})
+	for n := 1; n <= 5; n++ {
+		msg := testMessage("rocket-a", n)
+		msg.ReceiptID = "receipt"
+		require.NoError(t, q.TryEnqueue(msg))
+	}
+	assert.Equal(t, 5, q.Len())
+
+	// The next message of a channel is delivered once the previous one
+	// is settled.
+	receive(t, q).Ack()
+	receive(t, q).Nack()
+	require.NoError(t, q.Close())
+	assert.ErrorIs(t, q.TryEnqueue(testMessage("rocket-a", 6)), ErrClosed)
+
+	q = openTestDisk(t, dir, DiskOptions{})
+	defer q.Close()
+	assert.Equal(t, 4, q.Len())
+	for _, n := range []int{2, 3, 4, 5} {
+		delivery := receive(t, q)
+		assert.Equal(t, testMessage("rocket-a", n).Metadata, delivery.Message.Metadata)
+		assert.JSONEq(t, `{"by":100}`, string(delivery.Message.Message))
+		assert.Equal(t, testMessage("rocket-a", n).RequestID, delivery.Message.RequestID)
+		assert.Equal(t, "receipt", delivery.Message.ReceiptID)
+		delivery.Ack()
+	}
+}
+
+// TestDisk_Retries tests that a nacked message is delivered again before the
+// later messages of its channel, and dead-lettered after MaxAttempts, so that
+// the offset moves on and the segments behind it are deleted.
+func TestDisk_Retries(t *testing.T) {
+	dir := t.TempDir()
+	q := openTestDisk(t, dir, DiskOptions{SegmentSize: 512, CommitInterval: time.Millisecond, MaxAttempts: 3, RetryBackoff: time.Millisecond})
+	defer q.Close()
+	require.NoError(t, q.TryEnqueue(testMessage("rocket-b", 1)))
+	require.NoError(t, q.TryEnqueue(testMessage("rocket-b", 2)))
+	for n := 1; n <= 20; n++ {
+		require.NoError(t, q.TryEnqueue(testMessage("rocket-a", n)))
+	}
+	require.Greater(t, len(segmentFiles(t, dir)), 2)
+
+	var rocketB []int
+	for range 24 {
+		delivery := receive(t, q)
+		if delivery.Message.Metadata.Channel != "rocket-b" {
+			delivery.Ack()
+			continue
+		}
+		rocketB = append(rocketB, delivery.Message.Metadata.MessageNumber)
+		if delivery.Message.Metadata.MessageNumber == 1 {
+			delivery.Nack()
+		} else {
+			delivery.Ack()
+		}
+	}
+	assert.Equal(t, []int{1, 1, 1, 2}, rocketB)
+	assert.Eventually(t, func() bool { return len(segmentFiles(t, dir)) == 1 }, time.Second, time.Millisecond)
+	assert.Zero(t, q.Len())
+	assert.Zero(t, q.InFlight())
+
+	data, err := os.ReadFile(filepath.Join(dir, DeadLetterFile))
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 1)
+	var dead model.IncomingMessage
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &dead))
+	assert.Equal(t, testMessage("rocket-b", 1).Metadata, dead.Metadata)
+}
+
+// TestDisk_Segments tests rolling over to new segments and deleting the ones
+// whose messages are all acknowledged.
+func TestDisk_Segments(t *testing.T) {
+	dir := t.TempDir()
+	q := openTestDisk(t, dir, DiskOptions{SegmentSize: 512, CommitInterval: time.Millisecond})
+	for n := 1; n <= 20; n++ {
+		require.NoError(t, q.TryEnqueue(testMessage("rocket-a", n)))
+	}
+	require.Greater(t, len(segmentFiles(t, dir)), 2)
+
+	for range 20 {
+		receive(t, q).Ack()
+	}
+	assert.Eventually(t, func() bool { return len(segmentFiles(t, dir)) == 1 }, time.Second, time.Millisecond)
+	require.NoError(t, q.Close())
+
+	// Offsets continue where they stopped.
+	q = openTestDisk(t, dir, DiskOptions{SegmentSize: 512})
+	assert.Zero(t, q.Len())
+	require.NoError(t, q.TryEnqueue(testMessage("rocket-a", 21)))
+	require.NoError(t, q.Close())
+	q = openTestDisk(t, dir, DiskOptions{SegmentSize: 512})
+	defer q.Close()
+	assert.Equal(t, 21, receive(t, q).Message.Metadata.MessageNumber)
+}
+
+// TestDisk_SegmentsAfterSlowMessage tests that a message settled after the
+// ones behind it, like one a worker rejects, does not keep their segments.
+func TestDisk_SegmentsAfterSlowMessage(t *testing.T) {
+	dir := t.TempDir()
+	q := openTestDisk(t, dir, DiskOptions{SegmentSize: 512, CommitInterval: time.Millisecond})
+	defer q.Close()
+	require.NoError(t, q.TryEnqueue(testMessage("rocket-b", 1)))
+	for n := 1; n <= 20; n++ {
+		require.NoError(t, q.TryEnqueue(testMessage("rocket-a", n)))
+	}
+	require.Greater(t, len(segmentFiles(t, dir)), 2)
+
+	var failed Delivery
+	for range 21 {
+		delivery := receive(t, q)
+		if delivery.Message.Metadata.Channel == "rocket-b" {
+			failed = delivery
+			continue
+		}
+		delivery.Ack()
+	}
+	require.Equal(t, "rocket-b", failed.Message.Metadata.Channel)
+	time.Sleep(10 * time.Millisecond)
+	assert.Greater(t, len(segmentFiles(t, dir)), 2, "the first message holds the offset")
+
+	failed.Ack()
+	assert.Eventually(t, func() bool { return len(segmentFiles(t, dir)) == 1 }, time.Second, time.Millisecond)
+	assert.Zero(t, q.Len())
+}
+
+// TestDisk_TornWrite tests recovering from a crash in the middle of an append.
+func TestDisk_TornWrite(t *testing.T) {
+	dir := t.TempDir()
+	q := openTestDisk(t, dir, DiskOptions{})
+	require.NoError(t, q.TryEnqueue(testMessage("rocket-a", 1)))
+	require.NoError(t, q.Close())
+
+	segments := segmentFiles(t, dir)
+	require.Len(t, segments, 1)
+	file, err := os.OpenFile(segments[0], os.O_WRONLY|os.O_APPEND, 0o644)
+	require.NoError(t, err)
+	_, err = file.Write([]byte{0, 0, 0, 42, 1, 2})
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	q = openTestDisk(t, dir, DiskOptions{})
+	assert.Equal(t, 1, q.Len())
+	require.NoError(t, q.TryEnqueue(testMessage("rocket-a", 2)))
+	require.NoError(t, q.Close())
+
+	q = openTestDisk(t, dir, DiskOptions{})
+	defer q.Close()
+	for _, n := range []int{1, 2} {
+		delivery := receive(t, q)
+		assert.Equal(t, n, delivery.Message.Metadata.MessageNumber)
+		delivery.Ack()
+	}
+}
+
+// TestDisk_Full tests the capacity bound on messages waiting for a worker.
+func TestDisk_Full(t *testing.T) {
+	q := openTestDisk(t, t.TempDir(), DiskOptions{Capacity: 1})
+	defer q.Close()
+	require.NoError(t, q.TryEnqueue(testMessage("rocket-a", 1)))
+	// The pump holds the first message until a worker takes it.
+	assert.ErrorIs(t, q.TryEnqueue(testMessage("rocket-a", 2)), model.ErrQueueFull)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, q.Enqueue(ctx, testMessage("rocket-a", 2)), model.ErrQueueFull)
+
+	enqueued := make(chan error)
+	go func() { enqueued <- q.Enqueue(context.Background(), testMessage("rocket-a", 2)) }()
+	receive(t, q).Ack()
+	require.NoError(t, <-enqueued)
+	assert.Equal(t, 2, receive(t, q).Message.Metadata.MessageNumber)
+}
@@ -0,0 +1,277 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+)
+
+// dispatcher holds the waiting messages of a queue and hands them to workers
+// in the order of its scheduler. Memory and Disk embed it.
+type dispatcher struct {
+	capacity int
+	// drainOnClose keeps delivering the waiting messages after Close, and
+	// closes deliveries once none are left.
+	drainOnClose bool
+	// store, if set, persists a message before it is queued at offset next.
+	// It is called with the mutex held.
+	store func(msg model.IncomingMessage) error
+	// settled, if set, is told when the message at offset is settled. It is
+	// called with the mutex held.
+	settled func(offset uint64, processed bool)
+	// retries is how often a message that was not processed is delivered
+	// again, retryBackoff apart and doubling up to maxRetryBackoff, before it
+	// is given up on. Its channel waits for it meanwhile.
+	retries      int
+	retryBackoff time.Duration
+	// giveUp, if set, takes a message that failed every delivery, and
+	// reports whether it may be forgotten. It is called with the mutex held.
+	giveUp func(msg model.IncomingMessage, attempts int) bool
+
+	mutex  sync.Mutex
+	closed bool
+	sched  *scheduler
+	next   uint64
+	// inFlight counts delivered messages not yet settled.
+	inFlight int
+	// space is closed and replaced whenever a message is handed out.
+	space chan struct{}
+
+	ready      chan struct{}
+	done       chan struct{}
+	deliveries chan Delivery
+}
+
+func (d *dispatcher) init(capacity int, lanes Lanes) {
+	d.capacity = capacity
+	d.sched = newScheduler(lanes)
+	d.space = make(chan struct{})
+	d.ready = make(chan struct{}, 1)
+	d.done = make(chan struct{})
+	d.deliveries = make(chan Delivery)
+}
+
+func (d *dispatcher) TryEnqueue(msg model.IncomingMessage) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.closed {
+		return ErrClosed
+	}
+	if d.sched.len() >= d.capacity {
+		return model.ErrQueueFull
+	}
+	return d.push(msg)
+}
+
+func (d *dispatcher) Enqueue(ctx context.Context, msg model.IncomingMessage) error {
+	for {
+		d.mutex.Lock()
+		if d.closed {
+			d.mutex.Unlock()
+			return ErrClosed
+		}
+		// A message is taken when there is room even if ctx is already done.
+		if d.sched.len() < d.capacity {
+			err := d.push(msg)
+			d.mutex.Unlock()
+			return err
+		}
+		space := d.space
+		d.mutex.Unlock()
+
+		select {
+		case <-space:
+		case <-ctx.Done():
+			return fullError(ctx)
+		case <-d.done:
+			return ErrClosed
+		}
+	}
+}
+
+func (d *dispatcher) Deliveries() <-chan Delivery {
+	return d.deliveries
+}
+
+func (d *dispatcher) Len() int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.sched.len()
+}
+
+func (d *dispatcher) InFlight() int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.inFlight
+}
+
+func (d *dispatcher) Cap() int {
+	return d.capacity
+}
+
+func (d *dispatcher) LaneLens() []int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.sched.laneLens()
+}
+
+func (d *dispatcher) Pause(channel string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.sched.pause(channel)
+	d.signal()
+}
+
+func (d *dispatcher) Resume(channel string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.sched.resume(channel)
+	d.signal()
+}
+
+// push stores and queues msg. It must be called with the mutex held.
+func (d *dispatcher) push(msg model.IncomingMessage) error {
+	if d.store != nil {
+		if err := d.store(msg); err != nil {
+			return err
+		}
+	}
+	d.sched.push(d.next, msg)
+	d.next++
+	d.signal()
+	return nil
+}
+
+// signal wakes the pump to look at the waiting messages again.
+func (d *dispatcher) signal() {
+	select {
+	case d.ready <- struct{}{}:
+	default:
+	}
+}
+
+// shut rejects further messages. It reports whether the queue was open.
+func (d *dispatcher) shut() bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.closed {
+		return false
+	}
+	d.closed = true
+	close(d.done)
+	d.signal()
+	return true
+}
+
+// pump hands waiting messages to workers until the queue is closed.
+func (d *dispatcher) pump() {
+	defer close(d.deliveries)
+	var stop <-chan struct{}
+	if !d.drainOnClose {
+		stop = d.done
+	}
+	for {
+		// The peek below sees whatever a pending wake-up was about.
+		select {
+		case <-d.ready:
+		default:
+		}
+		d.mutex.Lock()
+		next, ok := d.sched.peek()
+		drained := d.closed && d.sched.len() == 0
+		d.mutex.Unlock()
+		if drained && d.drainOnClose {
+			return
+		}
+		if !ok {
+			select {
+			case <-d.ready:
+				continue
+			case <-stop:
+				return
+			}
+		}
+
+		// Only the pump takes messages, so next stays deliverable while it
+		// waits for a worker. A new message or a settled one may change the
+		// pick, for example when an urgent message arrives.
+		delivery := Delivery{
+			Message: next.item.msg,
+			settle:  func(processed bool) { d.settle(next, processed) },
+		}
+		select {
+		case d.deliveries <- delivery:
+			d.mutex.Lock()
+			d.take(next)
+			d.mutex.Unlock()
+		case <-d.ready:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// take moves a delivered message from waiting to in flight. The worker can
+// settle the message before the pump gets to take it, so whichever comes
+// first takes it, and Len never counts a message already settled. It must be
+// called with the mutex held.
+func (d *dispatcher) take(p pick) {
+	if p.item.taken {
+		return
+	}
+	d.sched.take(p)
+	d.inFlight++
+	close(d.space)
+	d.space = make(chan struct{})
+}
+
+// maxRetryBackoff bounds the delay before a failed message is delivered
+// again.
+const maxRetryBackoff = time.Minute
+
+// settle releases the channel of a delivered message, so that its next
+// message can be handed out, or schedules the message to be delivered again.
+func (d *dispatcher) settle(p pick, processed bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	item := p.item
+	if item.settled {
+		return
+	}
+	d.take(p)
+	if !processed && !d.closed {
+		if item.failures < d.retries {
+			// The message stays in flight and its channel busy until it
+			// is back in the queue.
+			item.failures++
+			item.settled = true
+			delay := min(d.retryBackoff<<(item.failures-1), maxRetryBackoff)
+			time.AfterFunc(delay, func() { d.redeliver(item) })
+			return
+		}
+		if d.giveUp != nil {
+			processed = d.giveUp(item.msg, item.failures+1)
+		}
+	}
+	d.inFlight--
+	d.sched.settle(item)
+	if d.settled != nil {
+		d.settled(item.offset, processed)
+	}
+	d.signal()
+}
+
+// redeliver queues a failed message again, ahead of the later messages of its
+// channel. Once the queue is closed it is left to the next start.
+func (d *dispatcher) redeliver(item *waiting) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.inFlight--
+	if d.closed {
+		return
+	}
+	d.sched.requeue(item)
+	d.signal()
+}
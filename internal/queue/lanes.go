@@ -0,0 +1,306 @@
+package queue
+
+import (
+	"container/heap"
+	"container/list"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+)
+
+// Lanes configures the priority lanes of a queue. The zero value is a single
+// FIFO lane.
+type Lanes struct {
+	// Weights holds one weight per lane, from lane 0, the lowest priority,
+	// up. While several lanes have messages waiting, each gets deliveries in
+	// proportion to its weight, and ties go to the higher lane.
+	Weights []int
+	// Priorities maps message types to lanes. Other types go to lane 0.
+	Priorities map[model.MessageType]int
+}
+
+func (l Lanes) count() int {
+	return max(len(l.Weights), 1)
+}
+
+func (l Lanes) weight(lane int) int {
+	if lane < len(l.Weights) && l.Weights[lane] > 0 {
+		return l.Weights[lane]
+	}
+	return 1
+}
+
+func (l Lanes) lane(messageType model.MessageType) int {
+	return min(max(l.Priorities[messageType], 0), l.count()-1)
+}
+
+// waiting is a queued message, linked into its channel's lists.
+type waiting struct {
+	offset    uint64
+	msg       model.IncomingMessage
+	lane      int
+	inChannel *list.Element
+	inLane    *list.Element
+	// taken and settled track the message once it is handed out, and
+	// failures counts the deliveries that were not processed.
+	taken, settled bool
+	failures       int
+}
+
+type channelState struct {
+	name string
+	// waiting lists the channel's queued messages in arrival order, and
+	// lanes the same messages split by lane.
+	waiting *list.List
+	lanes   []*list.List
+	// slots holds the channel's index in each lane's heap, or -1.
+	slots []int
+	// busy is set while one of the channel's messages is with a worker.
+	busy   bool
+	paused bool
+}
+
+func (c *channelState) eligible() bool {
+	return !c.busy && !c.paused
+}
+
+// laneHeap orders the eligible channels with messages in a lane by their
+// oldest message in that lane.
+type laneHeap struct {
+	lane     int
+	channels []*channelState
+}
+
+func (h *laneHeap) Len() int { return len(h.channels) }
+
+func (h *laneHeap) Less(i, j int) bool {
+	return h.front(i).offset < h.front(j).offset
+}
+
+func (h *laneHeap) Swap(i, j int) {
+	h.channels[i], h.channels[j] = h.channels[j], h.channels[i]
+	h.channels[i].slots[h.lane] = i
+	h.channels[j].slots[h.lane] = j
+}
+
+func (h *laneHeap) Push(x any) {
+	channel := x.(*channelState)
+	channel.slots[h.lane] = len(h.channels)
+	h.channels = append(h.channels, channel)
+}
+
+func (h *laneHeap) Pop() any {
+	last := h.channels[len(h.channels)-1]
+	h.channels = h.channels[:len(h.channels)-1]
+	last.slots[h.lane] = -1
+	return last
+}
+
+func (h *laneHeap) front(i int) *waiting {
+	return h.channels[i].lanes[h.lane].Front().Value.(*waiting)
+}
+
+// pick is the scheduler's choice: the message to hand out and the lane that
+// is charged for it.
+type pick struct {
+	item *waiting
+	lane int
+}
+
+// scheduler orders waiting messages. Lanes are served by smooth weighted
+// round-robin. Messages of one channel are handed out in arrival order, one at
+// a time: a message only leaves once the previous one of its channel is
+// settled, and when a lane's next message has an older one of its channel
+// waiting in another lane, that older message goes first on the lane's turn.
+// Paused channels keep their messages until they are resumed.
+// It is not safe for concurrent use.
+type scheduler struct {
+	lanes    Lanes
+	heaps    []*laneHeap
+	lens     []int
+	credits  []int
+	channels map[string]*channelState
+	size     int
+	paused   bool
+}
+
+func newScheduler(lanes Lanes) *scheduler {
+	s := &scheduler{
+		lanes:    lanes,
+		heaps:    make([]*laneHeap, lanes.count()),
+		lens:     make([]int, lanes.count()),
+		credits:  make([]int, lanes.count()),
+		channels: make(map[string]*channelState),
+	}
+	for i := range s.heaps {
+		s.heaps[i] = &laneHeap{lane: i}
+	}
+	return s
+}
+
+func (s *scheduler) len() int {
+	return s.size
+}
+
+func (s *scheduler) laneLens() []int {
+	return append([]int(nil), s.lens...)
+}
+
+// channel returns the state of name, creating it if needed.
+func (s *scheduler) channel(name string) *channelState {
+	channel, exists := s.channels[name]
+	if !exists {
+		channel = &channelState{
+			name:    name,
+			waiting: list.New(),
+			lanes:   make([]*list.List, len(s.heaps)),
+			slots:   make([]int, len(s.heaps)),
+		}
+		for i := range channel.lanes {
+			channel.lanes[i] = list.New()
+			channel.slots[i] = -1
+		}
+		s.channels[name] = channel
+	}
+	return channel
+}
+
+// forget drops the state of a channel that has nothing left to remember.
+func (s *scheduler) forget(channel *channelState) {
+	if channel.waiting.Len() == 0 && channel.eligible() {
+		delete(s.channels, channel.name)
+	}
+}
+
+// schedule puts channel into the heap of every lane it has messages in.
+func (s *scheduler) schedule(channel *channelState) {
+	if !channel.eligible() {
+		return
+	}
+	for lane, messages := range channel.lanes {
+		if messages.Len() > 0 && channel.slots[lane] < 0 {
+			heap.Push(s.heaps[lane], channel)
+		}
+	}
+}
+
+// unschedule takes channel out of every lane heap.
+func (s *scheduler) unschedule(channel *channelState) {
+	for lane, slot := range channel.slots {
+		if slot >= 0 {
+			heap.Remove(s.heaps[lane], slot)
+		}
+	}
+}
+
+func (s *scheduler) push(offset uint64, msg model.IncomingMessage) {
+	channel := s.channel(msg.Metadata.Channel)
+	item := &waiting{offset: offset, msg: msg, lane: s.lanes.lane(msg.Metadata.MessageType)}
+	item.inChannel = channel.waiting.PushBack(item)
+	item.inLane = channel.lanes[item.lane].PushBack(item)
+	s.lens[item.lane]++
+	s.size++
+	// Offsets only grow, so the channel's place in a heap it is already in
+	// does not change.
+	s.schedule(channel)
+}
+
+// candidate returns the message lane would hand out now, or nil.
+func (s *scheduler) candidate(lane int) *waiting {
+	if s.heaps[lane].Len() == 0 {
+		return nil
+	}
+	return s.heaps[lane].channels[0].waiting.Front().Value.(*waiting)
+}
+
+// peek chooses the next message without taking it. It returns false when
+// delivery is paused or every waiting message is held back.
+func (s *scheduler) peek() (pick, bool) {
+	if s.paused {
+		return pick{}, false
+	}
+	var best pick
+	bestCredit := 0
+	for lane := len(s.heaps) - 1; lane >= 0; lane-- {
+		item := s.candidate(lane)
+		if item == nil {
+			continue
+		}
+		credit := s.credits[lane] + s.lanes.weight(lane)
+		if best.item == nil || credit > bestCredit {
+			best, bestCredit = pick{item: item, lane: lane}, credit
+		}
+	}
+	return best, best.item != nil
+}
+
+// take removes a message chosen by peek, marks its channel busy and charges
+// its lane.
+func (s *scheduler) take(p pick) {
+	total := 0
+	for lane, h := range s.heaps {
+		if h.Len() > 0 {
+			s.credits[lane] += s.lanes.weight(lane)
+			total += s.lanes.weight(lane)
+		}
+	}
+	s.credits[p.lane] -= total
+
+	item := p.item
+	item.taken = true
+	channel := s.channels[item.msg.Metadata.Channel]
+	s.unschedule(channel)
+	channel.waiting.Remove(item.inChannel)
+	channel.lanes[item.lane].Remove(item.inLane)
+	s.lens[item.lane]--
+	s.size--
+	channel.busy = true
+}
+
+// settle frees the channel of a message taken.
+func (s *scheduler) settle(item *waiting) {
+	item.settled = true
+	channel := s.channels[item.msg.Metadata.Channel]
+	channel.busy = false
+	s.schedule(channel)
+	s.forget(channel)
+}
+
+// requeue puts a message taken back in front of its channel and frees the
+// channel, so that the message is handed out again before the later ones.
+func (s *scheduler) requeue(item *waiting) {
+	item.taken, item.settled = false, false
+	channel := s.channels[item.msg.Metadata.Channel]
+	item.inChannel = channel.waiting.PushFront(item)
+	item.inLane = channel.lanes[item.lane].PushFront(item)
+	s.lens[item.lane]++
+	s.size++
+	channel.busy = false
+	s.schedule(channel)
+}
+
+// pause holds back the messages of channel, or of every channel if it is
+// empty. A message already handed out is not recalled.
+func (s *scheduler) pause(name string) {
+	if name == "" {
+		s.paused = true
+		return
+	}
+	channel := s.channel(name)
+	channel.paused = true
+	s.unschedule(channel)
+}
+
+// resume undoes pause.
+func (s *scheduler) resume(name string) {
+	if name == "" {
+		s.paused = false
+		return
+	}
+	channel, exists := s.channels[name]
+	if !exists {
+		return
+	}
+	channel.paused = false
+	s.schedule(channel)
+	s.forget(channel)
+}
@@ -0,0 +1,108 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testLanes = Lanes{
+	Weights:    []int{1, 4},
+	Priorities: map[model.MessageType]int{model.RocketExploded: 1},
+}
+
+func explodedMessage(channel string, number int) model.IncomingMessage {
+	msg := testMessage(channel, number)
+	msg.Metadata.MessageType = model.RocketExploded
+	return msg
+}
+
+// TestLanes_WeightedFairness tests that the high lane is preferred in
+// proportion to the weights without starving the low lane.
+func TestLanes_WeightedFairness(t *testing.T) {
+	q := NewMemory(100, testLanes)
+	defer q.Close()
+	for n := range 10 {
+		require.NoError(t, q.Enqueue(context.Background(), testMessage(fmt.Sprintf("low-%d", n), 1)))
+	}
+	for n := range 20 {
+		require.NoError(t, q.Enqueue(context.Background(), explodedMessage(fmt.Sprintf("high-%d", n), 1)))
+	}
+	assert.Equal(t, []int{10, 20}, q.LaneLens())
+
+	var types []model.MessageType
+	for range 10 {
+		delivery := receive(t, q)
+		types = append(types, delivery.Message.Metadata.MessageType)
+		delivery.Ack()
+	}
+	assert.Equal(t, model.RocketExploded, types[0], "the high lane goes first")
+	assert.Equal(t, 8, countType(types, model.RocketExploded))
+	assert.Equal(t, 2, countType(types, model.RocketSpeedIncreased), "the low lane gets its share")
+	assert.Equal(t, []int{8, 12}, q.LaneLens())
+}
+
+// TestLanes_ChannelOrder tests that a channel's messages keep their order
+// across lanes, and that a channel waiting for a worker does not hold up the
+// others.
+func TestLanes_ChannelOrder(t *testing.T) {
+	q := NewMemory(10, testLanes)
+	defer q.Close()
+	require.NoError(t, q.Enqueue(context.Background(), testMessage("rocket-b", 1)))
+	require.NoError(t, q.Enqueue(context.Background(), testMessage("rocket-a", 1)))
+	require.NoError(t, q.Enqueue(context.Background(), explodedMessage("rocket-a", 2)))
+
+	// The urgent message lends its turn to the older message of its channel.
+	first := receive(t, q)
+	assert.Equal(t, testMessage("rocket-a", 1).Metadata, first.Message.Metadata)
+	// Its successor waits for the Ack, other channels do not.
+	assert.Equal(t, testMessage("rocket-b", 1).Metadata, receive(t, q).Message.Metadata)
+	assertNoDelivery(t, q)
+	first.Ack()
+	assert.Equal(t, explodedMessage("rocket-a", 2).Metadata, receive(t, q).Message.Metadata)
+}
+
+// TestPause tests holding back one channel and the whole queue.
+func TestPause(t *testing.T) {
+	q := NewMemory(10, testLanes)
+	defer q.Close()
+	q.Pause("rocket-a")
+	require.NoError(t, q.Enqueue(context.Background(), explodedMessage("rocket-a", 1)))
+	require.NoError(t, q.Enqueue(context.Background(), testMessage("rocket-b", 1)))
+	receive(t, q).Ack()
+	assertNoDelivery(t, q)
+
+	q.Pause("")
+	q.Resume("rocket-a")
+	require.NoError(t, q.Enqueue(context.Background(), testMessage("rocket-b", 2)))
+	assertNoDelivery(t, q)
+	assert.Equal(t, 2, q.Len(), "enqueueing goes on while paused")
+
+	q.Resume("")
+	assert.Equal(t, explodedMessage("rocket-a", 1).Metadata, receive(t, q).Message.Metadata)
+	assert.Equal(t, testMessage("rocket-b", 2).Metadata, receive(t, q).Message.Metadata)
+}
+
+func assertNoDelivery(t *testing.T, q Queue) {
+	t.Helper()
+	select {
+	case delivery := <-q.Deliveries():
+		t.Fatalf("Unexpected delivery of %v", delivery.Message.Metadata)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func countType(types []model.MessageType, messageType model.MessageType) int {
+	count := 0
+	for _, t := range types {
+		if t == messageType {
+			count++
+		}
+	}
+	return count
+}
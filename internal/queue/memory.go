@@ -0,0 +1,24 @@
+package queue
+
+// Memory is a Queue held in memory. Queued messages are lost when the process
+// exits; acknowledging a message only releases its channel.
+type Memory struct {
+	dispatcher
+}
+
+// NewMemory returns a queue holding up to capacity messages in the given
+// lanes.
+func NewMemory(capacity int, lanes Lanes) *Memory {
+	q := &Memory{}
+	q.init(capacity, lanes)
+	q.drainOnClose = true
+	go q.pump()
+	return q
+}
+
+// Close rejects further messages. Messages already queued are still
+// delivered, after which Deliveries is closed.
+func (q *Memory) Close() error {
+	q.shut()
+	return nil
+}
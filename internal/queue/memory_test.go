@@ -0,0 +1,77 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testMessage(channel string, number int) model.IncomingMessage {
+	return model.IncomingMessage{
+		Metadata: model.Metadata{
+			Channel:       channel,
+			MessageNumber: number,
+			MessageTime:   time.Date(2022, 2, 2, 19, 39, 5, 0, time.UTC),
+			MessageType:   model.RocketSpeedIncreased,
+		},
+		Message:   json.RawMessage(`{"by":100}`),
+		RequestID: fmt.Sprintf("req-%s-%d", channel, number),
+	}
+}
+
+func receive(t *testing.T, q Queue) Delivery {
+	t.Helper()
+	select {
+	case delivery, ok := <-q.Deliveries():
+		require.True(t, ok, "deliveries closed")
+		return delivery
+	case <-time.After(2 * time.Second):
+		t.Fatal("No delivery within timeout")
+		return Delivery{}
+	}
+}
+
+// TestMemory tests the capacity bound, waiting for room and closing.
+func TestMemory(t *testing.T) {
+	q := NewMemory(2, Lanes{})
+	require.NoError(t, q.TryEnqueue(testMessage("rocket-a", 1)))
+	require.NoError(t, q.Enqueue(context.Background(), testMessage("rocket-a", 2)))
+	assert.Equal(t, 2, q.Len())
+	assert.Equal(t, 2, q.Cap())
+	assert.ErrorIs(t, q.TryEnqueue(testMessage("rocket-a", 3)), model.ErrQueueFull)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := q.Enqueue(ctx, testMessage("rocket-a", 3))
+	assert.ErrorIs(t, err, model.ErrQueueFull)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// A waiting Enqueue gets the room freed by a delivery.
+	enqueued := make(chan error)
+	go func() { enqueued <- q.Enqueue(context.Background(), testMessage("rocket-a", 3)) }()
+	delivery := receive(t, q)
+	assert.Eventually(t, func() bool { return q.InFlight() == 1 }, time.Second, time.Millisecond)
+	delivery.Ack()
+	assert.Zero(t, q.InFlight())
+	require.NoError(t, <-enqueued)
+
+	require.NoError(t, q.Close())
+	assert.ErrorIs(t, q.TryEnqueue(testMessage("rocket-a", 4)), ErrClosed)
+	assert.ErrorIs(t, ErrClosed, model.ErrQueueFull)
+
+	// Queued messages are still delivered after Close.
+	for _, n := range []int{2, 3} {
+		delivery := receive(t, q)
+		assert.Equal(t, n, delivery.Message.Metadata.MessageNumber)
+		delivery.Ack()
+	}
+	_, ok := <-q.Deliveries()
+	assert.False(t, ok)
+	require.NoError(t, q.Close())
+}
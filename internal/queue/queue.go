@@ -0,0 +1,77 @@
+// Package queue buffers accepted messages between the transports that receive
+// them (POST /messages, the radio listener) and the workers that process them.
+// The in-memory queue loses its messages on restart; the disk queue keeps them
+// in an append-only log until a worker acknowledges them.
+package queue
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+)
+
+// ErrClosed is returned when enqueueing into a closed queue. It wraps
+// model.ErrQueueFull, so clients are told to retry later.
+var ErrClosed = fmt.Errorf("queue closed: %w", model.ErrQueueFull)
+
+// Queue is a bounded queue of messages with acknowledged delivery. Messages
+// wait in priority lanes, and the messages of one channel are delivered in
+// the order they were queued, each only after the previous one is settled.
+type Queue interface {
+	// TryEnqueue adds msg if there is room and returns model.ErrQueueFull
+	// otherwise.
+	TryEnqueue(msg model.IncomingMessage) error
+	// Enqueue waits for room until ctx is done, and then returns an error
+	// wrapping both model.ErrQueueFull and ctx.Err().
+	Enqueue(ctx context.Context, msg model.IncomingMessage) error
+	// Deliveries hands out queued messages, one per receive. It is closed
+	// when the queue is closed.
+	Deliveries() <-chan Delivery
+	// Len is the number of messages waiting for a worker.
+	Len() int
+	// InFlight is the number of delivered messages not yet settled. The queue
+	// is idle when both are zero.
+	InFlight() int
+	// Cap is the number of waiting messages above which enqueueing fails.
+	Cap() int
+	// LaneLens is the number of messages waiting in each lane, from lane 0.
+	LaneLens() []int
+	// Pause holds back the messages of channel, or of every channel if it
+	// is empty, while enqueueing goes on. Messages already delivered, or
+	// being handed to a worker at that moment, are not recalled.
+	Pause(channel string)
+	// Resume undoes Pause for channel, or for the whole queue if it is
+	// empty. Channels paused on their own stay paused.
+	Resume(channel string)
+	// Close stops deliveries and releases the queue's resources.
+	Close() error
+}
+
+// Delivery is a message handed to a worker. The worker must call exactly one
+// of Ack or Nack once it is done with the message; until then, no other
+// message of its channel is delivered.
+type Delivery struct {
+	Message model.IncomingMessage
+	settle  func(processed bool)
+}
+
+// Ack reports that the message was processed and may be forgotten.
+func (d Delivery) Ack() {
+	if d.settle != nil {
+		d.settle(true)
+	}
+}
+
+// Nack reports that processing failed. A durable queue delivers the message
+// again, and sets it aside after too many attempts.
+func (d Delivery) Nack() {
+	if d.settle != nil {
+		d.settle(false)
+	}
+}
+
+// fullError is what Enqueue returns when ctx ends before there is room.
+func fullError(ctx context.Context) error {
+	return fmt.Errorf("%w: %w", model.ErrQueueFull, ctx.Err())
+}
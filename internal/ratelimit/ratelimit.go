@@ -0,0 +1,203 @@
+// Package ratelimit implements keyed token-bucket rate limiting.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+)
+
+// Limit is a sustained rate in requests per second and the burst allowed on
+// top of it. A zero Rate means unlimited.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+func (l Limit) unlimited() bool {
+	return l.Rate <= 0
+}
+
+// String formats the limit the way ParseLimit reads it.
+func (l Limit) String() string {
+	return strconv.FormatFloat(l.Rate, 'g', -1, 64) + ":" + strconv.Itoa(l.Burst)
+}
+
+// MarshalText and UnmarshalText let a Limit be used in YAML and JSON documents
+// and as a flag value.
+func (l Limit) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+func (l *Limit) UnmarshalText(text []byte) error {
+	limit, err := ParseLimit(string(text))
+	if err != nil {
+		return err
+	}
+	*l = limit
+	return nil
+}
+
+// ParseLimit reads "<rate>:<burst>", e.g. "20:50". The burst defaults to the
+// rate rounded up when omitted.
+func ParseLimit(s string) (Limit, error) {
+	rateText, burstText, hasBurst := strings.Cut(strings.TrimSpace(s), ":")
+	rate, err := strconv.ParseFloat(rateText, 64)
+	if err != nil || rate < 0 {
+		return Limit{}, fmt.Errorf("invalid rate %q", rateText)
+	}
+	limit := Limit{Rate: rate, Burst: int(math.Ceil(rate))}
+	if hasBurst {
+		if limit.Burst, err = strconv.Atoi(burstText); err != nil || limit.Burst < 1 {
+			return Limit{}, fmt.Errorf("invalid burst %q", burstText)
+		}
+	}
+	if limit.Rate > 0 && limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	return limit, nil
+}
+
+// ParseOverrides reads comma-separated "<key>=<rate>:<burst>" pairs.
+func ParseOverrides(s string) (map[string]Limit, error) {
+	overrides := make(map[string]Limit)
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("override %q: expected <key>=<rate>:<burst>", pair)
+		}
+		limit, err := ParseLimit(value)
+		if err != nil {
+			return nil, fmt.Errorf("override %q: %w", pair, err)
+		}
+		overrides[strings.TrimSpace(key)] = limit
+	}
+	return overrides, nil
+}
+
+// Error is returned when a request is throttled. It wraps model.ErrRateLimited
+// and tells the client when a token will be available.
+type Error struct {
+	Scope      string
+	Key        string
+	retryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s %s: %v, retry in %s", e.Scope, e.Key, model.ErrRateLimited, e.retryAfter.Round(time.Millisecond))
+}
+
+func (e *Error) Unwrap() error { return model.ErrRateLimited }
+
+// RetryAfter is how long the client should wait before retrying.
+func (e *Error) RetryAfter() time.Duration { return e.retryAfter }
+
+type bucket struct {
+	limit  Limit
+	tokens float64
+	last   time.Time
+}
+
+// pruneEvery is how many Allow calls pass between sweeps of idle buckets.
+const pruneEvery = 1024
+
+// Limiter holds one token bucket per key. Keys without an override share the
+// default limit. Buckets that have refilled completely are forgotten, so the
+// number of keys seen over time does not grow memory.
+type Limiter struct {
+	scope     string
+	limit     Limit
+	overrides map[string]Limit
+	now       func() time.Time
+
+	mutex   sync.Mutex
+	buckets map[string]*bucket
+	calls   int
+}
+
+// NewLimiter returns a limiter whose errors are labelled with scope, such as
+// "channel" or "client".
+func NewLimiter(scope string, limit Limit, overrides map[string]Limit) *Limiter {
+	return &Limiter{
+		scope:     scope,
+		limit:     limit,
+		overrides: overrides,
+		now:       time.Now,
+		buckets:   make(map[string]*bucket),
+	}
+}
+
+// SetClock replaces time.Now, so that tests can refill buckets without
+// waiting. It must be called before the first Allow.
+func (l *Limiter) SetClock(now func() time.Time) {
+	l.now = now
+}
+
+// Scope returns the label the limiter was created with.
+func (l *Limiter) Scope() string {
+	return l.scope
+}
+
+func (l *Limiter) limitFor(key string) Limit {
+	if limit, exists := l.overrides[key]; exists {
+		return limit
+	}
+	return l.limit
+}
+
+// Allow takes a token from key's bucket, or returns an *Error if there is none.
+func (l *Limiter) Allow(key string) error {
+	limit := l.limitFor(key)
+	if limit.unlimited() {
+		return nil
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	l.calls++
+	if l.calls%pruneEvery == 0 {
+		l.prune(now)
+	}
+
+	b, exists := l.buckets[key]
+	if !exists {
+		b = &bucket{limit: limit, tokens: float64(limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.refill(now)
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return nil
+	}
+	wait := time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+	return &Error{Scope: l.scope, Key: key, retryAfter: wait}
+}
+
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	b.last = now
+	if elapsed > 0 {
+		b.tokens = math.Min(float64(b.limit.Burst), b.tokens+elapsed*b.limit.Rate)
+	}
+}
+
+// prune drops buckets that are full again. It must be called with the mutex held.
+func (l *Limiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLimiter(limit Limit, overrides map[string]Limit) (*Limiter, *time.Time) {
+	now := time.Unix(1700000000, 0)
+	l := NewLimiter("channel", limit, overrides)
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+// TestAllow_Burst tests that a full bucket allows the burst, then refills at the rate.
+func TestAllow_Burst(t *testing.T) {
+	l, now := newTestLimiter(Limit{Rate: 2, Burst: 3}, nil)
+
+	for range 3 {
+		require.NoError(t, l.Allow("rocket-a"))
+	}
+	err := l.Allow("rocket-a")
+	require.ErrorIs(t, err, model.ErrRateLimited)
+
+	var limitErr *Error
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, "channel", limitErr.Scope)
+	assert.Equal(t, "rocket-a", limitErr.Key)
+	assert.Equal(t, 500*time.Millisecond, limitErr.RetryAfter())
+
+	// Other keys have their own bucket.
+	assert.NoError(t, l.Allow("rocket-b"))
+
+	*now = now.Add(500 * time.Millisecond)
+	assert.NoError(t, l.Allow("rocket-a"))
+	assert.Error(t, l.Allow("rocket-a"))
+
+	// The bucket never holds more than the burst.
+	*now = now.Add(time.Hour)
+	for range 3 {
+		require.NoError(t, l.Allow("rocket-a"))
+	}
+	assert.Error(t, l.Allow("rocket-a"))
+}
+
+// TestAllow_Overrides tests per-key overrides, including unlimited keys.
+func TestAllow_Overrides(t *testing.T) {
+	l, _ := newTestLimiter(Limit{Rate: 1, Burst: 1}, map[string]Limit{
+		"chatty":    {Rate: 10, Burst: 5},
+		"unlimited": {Rate: 0},
+	})
+
+	for range 5 {
+		require.NoError(t, l.Allow("chatty"))
+	}
+	assert.Error(t, l.Allow("chatty"))
+
+	for range 100 {
+		require.NoError(t, l.Allow("unlimited"))
+	}
+
+	require.NoError(t, l.Allow("quiet"))
+	assert.Error(t, l.Allow("quiet"))
+}
+
+// TestPrune tests that refilled buckets are forgotten.
+func TestPrune(t *testing.T) {
+	l, now := newTestLimiter(Limit{Rate: 1, Burst: 1}, nil)
+	for i := range pruneEvery - 1 {
+		_ = l.Allow(string(rune('a' + i%26)))
+	}
+	assert.Len(t, l.buckets, 26)
+
+	*now = now.Add(time.Minute)
+	_ = l.Allow("z")
+	assert.Len(t, l.buckets, 1)
+}
+
+// TestParseLimit tests the "<rate>:<burst>" syntax.
+func TestParseLimit(t *testing.T) {
+	limit, err := ParseLimit("20:50")
+	require.NoError(t, err)
+	assert.Equal(t, Limit{Rate: 20, Burst: 50}, limit)
+	assert.Equal(t, "20:50", limit.String())
+
+	limit, err = ParseLimit("0.5")
+	require.NoError(t, err)
+	assert.Equal(t, Limit{Rate: 0.5, Burst: 1}, limit)
+
+	limit, err = ParseLimit("0")
+	require.NoError(t, err)
+	assert.True(t, limit.unlimited())
+
+	for _, s := range []string{"", "fast", "-1", "5:0", "5:x"} {
+		_, err := ParseLimit(s)
+		assert.Error(t, err, s)
+	}
+
+	overrides, err := ParseOverrides("rocket-a=50:100, rocket-b=1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]Limit{"rocket-a": {50, 100}, "rocket-b": {1, 1}}, overrides)
+	_, err = ParseOverrides("rocket-a")
+	assert.Error(t, err)
+}
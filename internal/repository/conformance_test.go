@@ -0,0 +1,23 @@
+package repository_test
+
+import (
+	"testing"
+
+	"github.com/seansa/rocket-challenge/internal/repository"
+	"github.com/seansa/rocket-challenge/internal/repository/repositorytest"
+)
+
+// TestConformance runs the shared suite against the in-memory repository, on
+// its own and behind the metrics wrapper.
+func TestConformance(t *testing.T) {
+	t.Run("Memory", func(t *testing.T) {
+		repositorytest.RunConformance(t, func(*testing.T) repository.Repository[repositorytest.Item] {
+			return repository.NewRepository[repositorytest.Item]()
+		})
+	})
+	t.Run("WithMetrics", func(t *testing.T) {
+		repositorytest.RunConformance(t, func(*testing.T) repository.Repository[repositorytest.Item] {
+			return repository.WithMetrics(repository.NewRepository[repositorytest.Item]())
+		})
+	})
+}
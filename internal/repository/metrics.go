@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/seansa/rocket-challenge/internal/metrics"
+)
+
+var operationSeconds = metrics.Default.NewHistogramVec("rocket_repository_operation_seconds",
+	"Latency of repository operations.", metrics.DefaultBuckets, "op")
+
+// instrumented records the latency of every operation of the wrapped repository.
+type instrumented[T Storable] struct {
+	next Repository[T]
+}
+
+// WithMetrics wraps repo so that its operation latencies are exported at /metrics.
+func WithMetrics[T Storable](repo Repository[T]) Repository[T] {
+	return &instrumented[T]{next: repo}
+}
+
+func observe(op string, start time.Time) {
+	operationSeconds.WithLabelValues(op).ObserveDuration(start)
+}
+
+func (r *instrumented[T]) Get(key string) (T, error) {
+	defer observe("get", time.Now())
+	return r.next.Get(key)
+}
+
+func (r *instrumented[T]) GetAll() ([]T, error) {
+	defer observe("get_all", time.Now())
+	return r.next.GetAll()
+}
+
+// ForEach measures the whole iteration, including the time spent in fn.
+func (r *instrumented[T]) ForEach(fn func(item T) error) error {
+	defer observe("for_each", time.Now())
+	return r.next.ForEach(fn)
+}
+
+func (r *instrumented[T]) Save(item T) error {
+	defer observe("save", time.Now())
+	return r.next.Save(item)
+}
+
+func (r *instrumented[T]) Ping() error {
+	defer observe("ping", time.Now())
+	return r.next.Ping()
+}
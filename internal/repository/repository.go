@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"sort"
 	"sync"
+
+	"github.com/seansa/rocket-challenge/internal/model"
 )
 
 type Storable interface {
@@ -13,7 +15,13 @@ type Storable interface {
 type Repository[T Storable] interface {
 	Get(key string) (T, error)
 	GetAll() ([]T, error)
+	// ForEach calls fn for every item in key order, stopping at the first error
+	// fn returns. Items are read one at a time, so callers can stream large
+	// result sets without materializing them.
+	ForEach(fn func(item T) error) error
 	Save(item T) error
+	// Ping reports whether the underlying storage is reachable.
+	Ping() error
 }
 
 type repository[T Storable] struct {
@@ -34,7 +42,7 @@ func (r *repository[T]) Get(key string) (T, error) {
 	item, exists := r.db[key]
 	if !exists {
 		var zero T
-		return zero, fmt.Errorf("key %s not found", key)
+		return zero, fmt.Errorf("key %s %w", key, model.ErrNotFound)
 	}
 	return item, nil
 }
@@ -56,6 +64,31 @@ func (r *repository[T]) GetAll() ([]T, error) {
 	return items, nil
 }
 
+func (r *repository[T]) ForEach(fn func(item T) error) error {
+	r.mutex.RLock()
+	keys := make([]string, 0, len(r.db))
+	for key := range r.db {
+		keys = append(keys, key)
+	}
+	r.mutex.RUnlock()
+
+	sort.Strings(keys)
+
+	// The lock is not held while fn runs, so a slow consumer never blocks writers.
+	for _, key := range keys {
+		r.mutex.RLock()
+		item, exists := r.db[key]
+		r.mutex.RUnlock()
+		if !exists {
+			continue
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (r *repository[T]) Save(item T) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
@@ -63,3 +96,8 @@ func (r *repository[T]) Save(item T) error {
 	r.db[item.GetKey()] = item
 	return nil
 }
+
+// Ping always succeeds: the in-memory store is reachable as long as the process is.
+func (r *repository[T]) Ping() error {
+	return nil
+}
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -37,6 +38,7 @@ func TestGet_NotFound(t *testing.T) {
 	repo := NewRepository[model.Rocket]()
 	_, err := repo.Get("non-existent-item")
 	assert.Error(t, err)
+	assert.ErrorIs(t, err, model.ErrNotFound)
 	assert.Contains(t, err.Error(), "not found")
 }
 
@@ -93,3 +95,28 @@ func TestGetAll_Empty(t *testing.T) {
 	assert.Len(t, allItems, 0)
 	assert.NotNil(t, allItems)
 }
+
+// TestForEach tests iterating items in key order and stopping on error.
+func TestForEach(t *testing.T) {
+	repo := NewRepository[model.Rocket]()
+	for _, channel := range []string{"item-C", "item-A", "item-B"} {
+		_ = repo.Save(model.NewRocket(channel))
+	}
+
+	var keys []string
+	err := repo.ForEach(func(item model.Rocket) error {
+		keys = append(keys, item.GetKey())
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"item-A", "item-B", "item-C"}, keys)
+
+	stop := errors.New("stop")
+	visited := 0
+	err = repo.ForEach(func(item model.Rocket) error {
+		visited++
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, visited)
+}
@@ -0,0 +1,324 @@
+// Package repositorytest checks that an implementation of
+// repository.Repository behaves like the in-memory one. Every backend runs
+// RunConformance from its tests:
+//
+//	func TestConformance(t *testing.T) {
+//		repositorytest.RunConformance(t, func(t *testing.T) repository.Repository[repositorytest.Item] {
+//			return newBackend[repositorytest.Item](t)
+//		})
+//	}
+package repositorytest
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/seansa/rocket-challenge/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Concurrency of the randomized test.
+const (
+	workers         = 8
+	opsPerWorker    = 500
+	keysPerWorker   = 4
+	reentrantBudget = 5 * time.Second
+)
+
+// Item is what the suite stores. Its fields are exported and tagged, so that
+// backends that serialize items can store it.
+type Item struct {
+	Key     string `json:"key"`
+	Version int    `json:"version"`
+	// Check is derived from Key and Version, so that an item mixed from two
+	// writes is detected.
+	Check string `json:"check"`
+}
+
+func (i Item) GetKey() string {
+	return i.Key
+}
+
+// NewItem returns the item the suite writes for key at version.
+func NewItem(key string, version int) Item {
+	return Item{Key: key, Version: version, Check: fmt.Sprintf("%s@%d", key, version)}
+}
+
+// Factory returns an empty repository. It is called once per test, and can
+// register cleanup with t.Cleanup.
+type Factory func(t *testing.T) repository.Repository[Item]
+
+// RunConformance runs the whole suite against repositories from factory, each
+// check as a subtest with a fresh repository. Run it with -race.
+func RunConformance(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, repo repository.Repository[Item])
+	}{
+		{"Ping", testPing},
+		{"GetNotFound", testGetNotFound},
+		{"SaveAndGet", testSaveAndGet},
+		{"SaveOverwrites", testSaveOverwrites},
+		{"GetAllEmpty", testGetAllEmpty},
+		{"GetAllSorted", testGetAllSorted},
+		{"ForEachSorted", testForEachSorted},
+		{"ForEachStops", testForEachStops},
+		{"ForEachAllowsWrites", testForEachAllowsWrites},
+		{"Concurrent", testConcurrent},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.run(t, factory(t))
+		})
+	}
+}
+
+// sortKeys are saved out of order. Keys are compared as bytes, so upper case
+// sorts before lower case, whatever the backend's collation.
+var sortKeys = []string{"rocket-b", "Rocket-Z", "rocket-a", "rocket-a/1", "rocket-é", "rocket-10", "rocket-2", "0"}
+
+func sortedKeys() []string {
+	keys := append([]string(nil), sortKeys...)
+	sort.Strings(keys)
+	return keys
+}
+
+func testPing(t *testing.T, repo repository.Repository[Item]) {
+	assert.NoError(t, repo.Ping())
+}
+
+func testGetNotFound(t *testing.T, repo repository.Repository[Item]) {
+	_, err := repo.Get("missing")
+	assert.ErrorIs(t, err, model.ErrNotFound)
+
+	require.NoError(t, repo.Save(NewItem("present", 1)))
+	_, err = repo.Get("missing")
+	assert.ErrorIs(t, err, model.ErrNotFound)
+	_, err = repo.Get("")
+	assert.ErrorIs(t, err, model.ErrNotFound)
+}
+
+func testSaveAndGet(t *testing.T, repo repository.Repository[Item]) {
+	for _, key := range sortKeys {
+		require.NoError(t, repo.Save(NewItem(key, 1)))
+	}
+	for _, key := range sortKeys {
+		item, err := repo.Get(key)
+		require.NoError(t, err)
+		assert.Equal(t, NewItem(key, 1), item)
+	}
+}
+
+func testSaveOverwrites(t *testing.T, repo repository.Repository[Item]) {
+	require.NoError(t, repo.Save(NewItem("rocket-a", 1)))
+	require.NoError(t, repo.Save(NewItem("rocket-a", 2)))
+
+	item, err := repo.Get("rocket-a")
+	require.NoError(t, err)
+	assert.Equal(t, NewItem("rocket-a", 2), item)
+	items, err := repo.GetAll()
+	require.NoError(t, err)
+	assert.Equal(t, []Item{NewItem("rocket-a", 2)}, items)
+}
+
+func testGetAllEmpty(t *testing.T, repo repository.Repository[Item]) {
+	items, err := repo.GetAll()
+	require.NoError(t, err)
+	assert.NotNil(t, items, "GetAll returns an empty slice, which encodes as [] rather than null")
+	assert.Empty(t, items)
+}
+
+func testGetAllSorted(t *testing.T, repo repository.Repository[Item]) {
+	for _, key := range sortKeys {
+		require.NoError(t, repo.Save(NewItem(key, 1)))
+	}
+	items, err := repo.GetAll()
+	require.NoError(t, err)
+	assert.Equal(t, sortedKeys(), keysOf(items))
+}
+
+func testForEachSorted(t *testing.T, repo repository.Repository[Item]) {
+	require.NoError(t, repo.ForEach(func(item Item) error {
+		t.Errorf("ForEach visited %q in an empty repository", item.Key)
+		return nil
+	}))
+
+	for _, key := range sortKeys {
+		require.NoError(t, repo.Save(NewItem(key, 1)))
+	}
+	var items []Item
+	require.NoError(t, repo.ForEach(func(item Item) error {
+		items = append(items, item)
+		return nil
+	}))
+	assert.Equal(t, sortedKeys(), keysOf(items))
+	for _, item := range items {
+		assert.Equal(t, NewItem(item.Key, 1), item)
+	}
+}
+
+func testForEachStops(t *testing.T, repo repository.Repository[Item]) {
+	for _, key := range sortKeys {
+		require.NoError(t, repo.Save(NewItem(key, 1)))
+	}
+	stop := errors.New("stop")
+	visited := 0
+	err := repo.ForEach(func(Item) error {
+		visited++
+		if visited == 2 {
+			return stop
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 2, visited)
+}
+
+// testForEachAllowsWrites checks that fn may write to the repository, so that
+// a slow consumer of ForEach never blocks writers.
+func testForEachAllowsWrites(t *testing.T, repo repository.Repository[Item]) {
+	for _, key := range sortKeys {
+		require.NoError(t, repo.Save(NewItem(key, 1)))
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- repo.ForEach(func(item Item) error {
+			return repo.Save(NewItem(item.Key, item.Version+1))
+		})
+	}()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(reentrantBudget):
+		t.Fatal("Save from within ForEach did not return")
+	}
+	for _, key := range sortKeys {
+		item, err := repo.Get(key)
+		require.NoError(t, err)
+		assert.Equal(t, 2, item.Version)
+	}
+}
+
+// testConcurrent runs random operations from several goroutines and checks
+// them against a model. Each worker owns a few keys that only it writes, with
+// increasing versions, and reads every key:
+//   - reading its own key returns its last write;
+//   - reading any key returns an item that was written, and never one older
+//     than the worker saw before;
+//   - GetAll and ForEach return whole items, sorted, each key once.
+//
+// Afterwards, the repository holds exactly every worker's last writes.
+func testConcurrent(t *testing.T, repo repository.Repository[Item]) {
+	seed := rand.Uint64()
+	t.Logf("Seed %d", seed)
+
+	keys := make([]string, 0, workers*keysPerWorker)
+	for w := range workers {
+		for k := range keysPerWorker {
+			keys = append(keys, fmt.Sprintf("key-%02d-%d", w, k))
+		}
+	}
+
+	final := make([]map[string]int, workers)
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rng := rand.New(rand.NewPCG(seed, uint64(w)))
+			own := keys[w*keysPerWorker : (w+1)*keysPerWorker]
+			written := make(map[string]int)
+			seen := make(map[string]int)
+			check := func(op string, item Item) {
+				if item != NewItem(item.Key, item.Version) {
+					t.Errorf("%s returned a corrupt item %+v", op, item)
+				}
+				if item.Version < seen[item.Key] {
+					t.Errorf("%s returned %s at version %d after version %d", op, item.Key, item.Version, seen[item.Key])
+				}
+				seen[item.Key] = item.Version
+			}
+			checkAll := func(op string, items []Item) {
+				for i, item := range items {
+					if i > 0 && items[i-1].Key >= item.Key {
+						t.Errorf("%s returned %q after %q", op, item.Key, items[i-1].Key)
+					}
+					check(op, item)
+				}
+			}
+
+			for range opsPerWorker {
+				switch op := rng.IntN(10); {
+				case op < 4:
+					key := own[rng.IntN(len(own))]
+					written[key]++
+					if err := repo.Save(NewItem(key, written[key])); err != nil {
+						t.Errorf("Save(%s): %v", key, err)
+					}
+				case op < 8:
+					key := keys[rng.IntN(len(keys))]
+					item, err := repo.Get(key)
+					switch {
+					case errors.Is(err, model.ErrNotFound):
+						if seen[key] > 0 {
+							t.Errorf("Get(%s) found nothing after version %d", key, seen[key])
+						}
+					case err != nil:
+						t.Errorf("Get(%s): %v", key, err)
+					case item.Key != key:
+						t.Errorf("Get(%s) returned %q", key, item.Key)
+					default:
+						check("Get", item)
+						if version, mine := written[key]; mine && item.Version != version {
+							t.Errorf("Get(%s) returned version %d after writing %d", key, item.Version, version)
+						}
+					}
+				case op < 9:
+					items, err := repo.GetAll()
+					if err != nil {
+						t.Errorf("GetAll: %v", err)
+					}
+					checkAll("GetAll", items)
+				default:
+					var items []Item
+					err := repo.ForEach(func(item Item) error {
+						items = append(items, item)
+						return nil
+					})
+					if err != nil {
+						t.Errorf("ForEach: %v", err)
+					}
+					checkAll("ForEach", items)
+				}
+			}
+			final[w] = written
+		}()
+	}
+	wg.Wait()
+
+	want := []Item{}
+	for _, written := range final {
+		for key, version := range written {
+			want = append(want, NewItem(key, version))
+		}
+	}
+	sort.Slice(want, func(i, j int) bool { return want[i].Key < want[j].Key })
+	items, err := repo.GetAll()
+	require.NoError(t, err)
+	assert.Equal(t, want, items)
+}
+
+func keysOf(items []Item) []string {
+	keys := make([]string, len(items))
+	for i, item := range items {
+		keys[i] = item.Key
+	}
+	return keys
+}
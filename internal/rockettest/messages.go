@@ -0,0 +1,45 @@
+package rockettest
+
+import (
+	"encoding/json"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+)
+
+// Message returns a message of messageType for channel with body as its
+// message section. Its time is left zero, so that Stack.Post stamps it with
+// the clock.
+func Message(channel string, number int, messageType model.MessageType, body any) model.IncomingMessage {
+	data, err := json.Marshal(body)
+	if err != nil {
+		panic(err)
+	}
+	return model.IncomingMessage{
+		Metadata: model.Metadata{
+			Channel:       channel,
+			MessageNumber: number,
+			MessageType:   messageType,
+		},
+		Message: data,
+	}
+}
+
+func Launched(channel string, number int, rocketType string, speed int, mission string) model.IncomingMessage {
+	return Message(channel, number, model.RocketLaunched, model.LaunchedMessage{Type: rocketType, LaunchSpeed: speed, Mission: mission})
+}
+
+func SpeedIncreased(channel string, number, by int) model.IncomingMessage {
+	return Message(channel, number, model.RocketSpeedIncreased, model.SpeedChangedMessage{By: by})
+}
+
+func SpeedDecreased(channel string, number, by int) model.IncomingMessage {
+	return Message(channel, number, model.RocketSpeedDecreased, model.SpeedChangedMessage{By: by})
+}
+
+func Exploded(channel string, number int, reason string) model.IncomingMessage {
+	return Message(channel, number, model.RocketExploded, model.RocketExplodedMessage{Reason: reason})
+}
+
+func MissionChanged(channel string, number int, mission string) model.IncomingMessage {
+	return Message(channel, number, model.RocketMissionChanged, model.MissionChangedMessage{NewMission: mission})
+}
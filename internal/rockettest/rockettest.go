@@ -0,0 +1,272 @@
+// Package rockettest starts the whole service in-process for end-to-end
+// tests: messages posted to the HTTP handler go through the queue, a worker,
+// the service and the repository, and are read back over HTTP.
+//
+// By default no worker runs, and Drain processes the queued messages on the
+// test's goroutine, so that tests are deterministic and need no sleeps:
+//
+//	stack := rockettest.New(t, rockettest.Options{})
+//	stack.Send(rockettest.Launched("rocket-1", 1, "Falcon-9", 500, "ARTEMIS"))
+//	stack.Drain()
+//	stack.AssertRocket(model.Rocket{Channel: "rocket-1", Type: "Falcon-9", Speed: 500, Mission: "ARTEMIS"})
+package rockettest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seansa/rocket-challenge/internal/controller"
+	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/seansa/rocket-challenge/internal/queue"
+	"github.com/seansa/rocket-challenge/internal/ratelimit"
+	"github.com/seansa/rocket-challenge/internal/repository"
+	"github.com/seansa/rocket-challenge/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// DefaultQueueSize is the queue capacity when Options.QueueSize is 0.
+	DefaultQueueSize = 1000
+	// drainTimeout bounds Drain, so that a test holding messages back fails
+	// instead of hanging.
+	drainTimeout = 5 * time.Second
+	// drainPoll is how often Drain checks whether concurrent workers are
+	// idle.
+	drainPoll = time.Millisecond
+)
+
+// Epoch is where a Clock created by New starts.
+var Epoch = time.Date(2022, 2, 2, 19, 39, 5, 0, time.UTC)
+
+// Options configures a Stack. The zero value is a deterministic stack with
+// an in-memory repository and no rate limits.
+type Options struct {
+	// Workers is the number of workers processing messages concurrently. With
+	// 0, no worker runs and Drain processes messages one at a time.
+	Workers int
+	// QueueSize is the capacity of the message queue.
+	QueueSize int
+	Lanes     queue.Lanes
+	// Clock drives message times, receipt expiry, rate limits and contact
+	// tracking. A clock stopped at Epoch is used when it is nil.
+	Clock *Clock
+	// ChannelLimit and ClientLimit throttle POST /messages. Zero limits are
+	// disabled.
+	ChannelLimit ratelimit.Limit
+	ClientLimit  ratelimit.Limit
+	// ReceiptTTL is how long receipts are kept, by the clock.
+	ReceiptTTL time.Duration
+	// Repository stores the rockets. An in-memory repository is used when it
+	// is nil.
+	Repository repository.Repository[model.Rocket]
+}
+
+// Stack is a running service. Its parts are exported so that tests can reach
+// past the HTTP API when they need to.
+type Stack struct {
+	Clock      *Clock
+	Queue      queue.Queue
+	Repository repository.Repository[model.Rocket]
+	Service    service.Service
+	Pool       *service.WorkerPool
+	Receipts   *service.Receipts
+	Controller *controller.RocketController
+	Handler    http.Handler
+
+	t       testing.TB
+	workers int
+}
+
+// New starts a stack, which is stopped when the test ends.
+func New(t testing.TB, opts Options) *Stack {
+	t.Helper()
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = DefaultQueueSize
+	}
+	if opts.Clock == nil {
+		opts.Clock = NewClock(Epoch)
+	}
+	if opts.Repository == nil {
+		opts.Repository = repository.NewRepository[model.Rocket]()
+	}
+
+	s := &Stack{
+		Clock:      opts.Clock,
+		Queue:      queue.NewMemory(opts.QueueSize, opts.Lanes),
+		Repository: opts.Repository,
+		t:          t,
+		workers:    opts.Workers,
+	}
+	s.Service = service.NewRocketService(s.Repository, service.WithClock(s.Clock.Now))
+	s.Pool = service.NewWorkerPool(s.Queue, s.Service, service.NewHeartbeats(service.DefaultWorkerStallTimeout), max(opts.Workers, 1))
+	s.Receipts = service.NewReceipts(0, opts.ReceiptTTL)
+	s.Receipts.SetClock(s.Clock.Now)
+	s.Pool.SetReceipts(s.Receipts)
+
+	channelLimiter := ratelimit.NewLimiter("channel", opts.ChannelLimit, nil)
+	channelLimiter.SetClock(s.Clock.Now)
+	clientLimiter := ratelimit.NewLimiter("client", opts.ClientLimit, nil)
+	clientLimiter.SetClock(s.Clock.Now)
+	s.Controller = controller.NewRocketController(s.Service, s.Queue)
+	s.Controller.SetReceipts(s.Receipts)
+	s.Controller.SetRateLimiters(channelLimiter, clientLimiter)
+	s.Controller.SetBackpressure(0, s.Pool)
+	s.Handler = s.routes()
+
+	if opts.Workers > 0 {
+		require.NoError(t, s.Pool.Resize(opts.Workers))
+	}
+	t.Cleanup(func() {
+		s.Pool.Stop()
+		_ = s.Queue.Close()
+	})
+	return s
+}
+
+func (s *Stack) routes() http.Handler {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(controller.RequestID())
+	r.Use(gin.Recovery())
+	r.Use(controller.ErrorHandler())
+
+	r.POST("/messages", s.Controller.MessageHandler)
+	r.GET("/messages/:id", s.Controller.GetMessageReceiptHandler)
+	r.GET("/rockets", s.Controller.GetAllRocketsHandler)
+	r.GET("/rockets/:channel", s.Controller.GetRocketStateHandler)
+	r.GET("/stats", s.Controller.GetStatsHandler)
+	return r
+}
+
+// Drain returns once every queued message is processed and no worker is busy.
+// Without workers, it processes the messages itself. It fails the test if the
+// queue does not empty in time, for example while processing is paused.
+func (s *Stack) Drain() {
+	s.t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+
+	if s.workers == 0 {
+		if _, err := s.Pool.Work(ctx); err != nil {
+			s.t.Fatalf("Draining the queue: %v (%d messages left)", err, s.Queue.Len())
+		}
+		return
+	}
+
+	ticker := time.NewTicker(drainPoll)
+	defer ticker.Stop()
+	// A message leaves the queue and becomes in flight at once, so reading
+	// Len first never misses one.
+	for s.Queue.Len() > 0 || s.Queue.InFlight() > 0 {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			s.t.Fatalf("Draining the queue: %v (%d messages left, %d in flight)", ctx.Err(), s.Queue.Len(), s.Queue.InFlight())
+		}
+	}
+}
+
+// Do serves req and returns the response.
+func (s *Stack) Do(req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.Handler.ServeHTTP(w, req)
+	return w
+}
+
+// Get serves a GET request for path.
+func (s *Stack) Get(path string) *httptest.ResponseRecorder {
+	return s.Do(httptest.NewRequest(http.MethodGet, path, nil))
+}
+
+// Post posts msg to /messages and returns the response, whatever its status.
+// A zero message time is set to the clock's time.
+func (s *Stack) Post(msg model.IncomingMessage) *httptest.ResponseRecorder {
+	s.t.Helper()
+	if msg.Metadata.MessageTime.IsZero() {
+		msg.Metadata.MessageTime = s.Clock.Now()
+	}
+	body, err := json.Marshal(msg)
+	require.NoError(s.t, err)
+	req := httptest.NewRequest(http.MethodPost, "/messages", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return s.Do(req)
+}
+
+// Send posts msgs in order, requires each to be accepted and returns their
+// receipt IDs. The messages are processed on the next Drain.
+func (s *Stack) Send(msgs ...model.IncomingMessage) []string {
+	s.t.Helper()
+	ids := make([]string, len(msgs))
+	for i, msg := range msgs {
+		w := s.Post(msg)
+		require.Equal(s.t, http.StatusAccepted, w.Code, "POST /messages: %s", w.Body)
+		var accepted struct {
+			ReceiptID string `json:"receiptId"`
+		}
+		require.NoError(s.t, json.Unmarshal(w.Body.Bytes(), &accepted))
+		ids[i] = accepted.ReceiptID
+	}
+	return ids
+}
+
+// Rocket returns the state of the rocket on channel, read over HTTP. It fails
+// the test if there is none.
+func (s *Stack) Rocket(channel string) model.Rocket {
+	s.t.Helper()
+	var rocket model.Rocket
+	s.getJSON("/rockets/"+channel, &rocket)
+	return rocket
+}
+
+// Rockets returns every rocket, read over HTTP.
+func (s *Stack) Rockets() []model.Rocket {
+	s.t.Helper()
+	var rockets []model.Rocket
+	s.getJSON("/rockets", &rockets)
+	return rockets
+}
+
+// Receipt returns the receipt with id, read over HTTP.
+func (s *Stack) Receipt(id string) model.Receipt {
+	s.t.Helper()
+	var receipt model.Receipt
+	s.getJSON("/messages/"+id, &receipt)
+	return receipt
+}
+
+// Stats returns the fleet statistics, read over HTTP.
+func (s *Stack) Stats() model.FleetStats {
+	s.t.Helper()
+	var stats model.FleetStats
+	s.getJSON("/stats", &stats)
+	return stats
+}
+
+// AssertRocket checks that the rocket on want.Channel is in the state of want.
+// Fields not served by the API, such as MessageNumber, are not compared.
+func (s *Stack) AssertRocket(want model.Rocket) bool {
+	s.t.Helper()
+	want.MessageNumber, want.MessageTime, want.ReceivedAt = 0, time.Time{}, time.Time{}
+	return assert.Equal(s.t, want, s.Rocket(want.Channel))
+}
+
+// AssertNoRocket checks that no rocket has been recorded on channel.
+func (s *Stack) AssertNoRocket(channel string) bool {
+	s.t.Helper()
+	w := s.Get("/rockets/" + channel)
+	return assert.Equal(s.t, http.StatusNotFound, w.Code, "GET /rockets/%s: %s", channel, w.Body)
+}
+
+func (s *Stack) getJSON(path string, v any) {
+	s.t.Helper()
+	w := s.Get(path)
+	require.Equal(s.t, http.StatusOK, w.Code, "GET %s: %s", path, w.Body)
+	require.NoError(s.t, json.Unmarshal(w.Body.Bytes(), v))
+}
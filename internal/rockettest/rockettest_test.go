@@ -0,0 +1,145 @@
+package rockettest
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/seansa/rocket-challenge/internal/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestOrdering tests that messages arriving out of order are applied in
+// message number order, and that older ones are ignored.
+func TestOrdering(t *testing.T) {
+	stack := New(t, Options{})
+
+	ids := stack.Send(
+		Launched("rocket-1", 1, "Falcon-9", 500, "ARTEMIS"),
+		SpeedIncreased("rocket-1", 3, 300),
+		SpeedIncreased("rocket-1", 2, 1000),
+		MissionChanged("rocket-1", 4, "SHUTTLE_MIR"),
+	)
+	stack.Drain()
+
+	stack.AssertRocket(model.Rocket{Channel: "rocket-1", Type: "Falcon-9", Speed: 800, Mission: "SHUTTLE_MIR"})
+	assert.Equal(t, model.ReceiptProcessed, stack.Receipt(ids[1]).Status)
+	assert.Equal(t, model.ReceiptIgnoredOld, stack.Receipt(ids[2]).Status)
+
+	stack.Send(Exploded("rocket-1", 5, "PRESSURE_VESSEL_FAILURE"), SpeedDecreased("rocket-1", 4, 100))
+	stack.Drain()
+	stack.AssertRocket(model.Rocket{Channel: "rocket-1", Type: "Falcon-9", Mission: model.Aborted, Exploded: true, ExplosionReason: "PRESSURE_VESSEL_FAILURE"})
+}
+
+// TestDuplicates tests that a redelivered message is recorded as a duplicate
+// and leaves the rocket as it was.
+func TestDuplicates(t *testing.T) {
+	stack := New(t, Options{})
+
+	launch := Launched("rocket-1", 1, "Falcon-9", 500, "ARTEMIS")
+	ids := stack.Send(launch, launch)
+	stack.Drain()
+
+	stack.AssertRocket(model.Rocket{Channel: "rocket-1", Type: "Falcon-9", Speed: 500, Mission: "ARTEMIS"})
+	assert.Equal(t, model.ReceiptProcessed, stack.Receipt(ids[0]).Status)
+	assert.Equal(t, model.ReceiptDuplicate, stack.Receipt(ids[1]).Status)
+	assert.Len(t, stack.Rockets(), 1)
+}
+
+// TestChannels tests that channels are kept apart and that nothing is
+// processed before Drain.
+func TestChannels(t *testing.T) {
+	stack := New(t, Options{})
+
+	stack.Send(
+		Launched("rocket-1", 1, "Falcon-9", 500, "ARTEMIS"),
+		Launched("rocket-2", 1, "Falcon-Heavy", 1000, "GEMINI"),
+		SpeedDecreased("rocket-2", 2, 250),
+	)
+	stack.AssertNoRocket("rocket-1")
+	assert.Equal(t, 3, stack.Queue.Len())
+
+	stack.Drain()
+	stack.AssertRocket(model.Rocket{Channel: "rocket-1", Type: "Falcon-9", Speed: 500, Mission: "ARTEMIS"})
+	stack.AssertRocket(model.Rocket{Channel: "rocket-2", Type: "Falcon-Heavy", Speed: 750, Mission: "GEMINI"})
+	assert.Equal(t, 0, stack.Queue.Len())
+}
+
+// TestClock tests that the clock stamps messages and drives receipt expiry
+// and rate limits.
+func TestClock(t *testing.T) {
+	stack := New(t, Options{
+		ReceiptTTL:   time.Minute,
+		ChannelLimit: ratelimit.Limit{Rate: 1, Burst: 1},
+	})
+
+	ids := stack.Send(Launched("rocket-1", 1, "Falcon-9", 500, "ARTEMIS"))
+	assert.Equal(t, http.StatusTooManyRequests, stack.Post(SpeedIncreased("rocket-1", 2, 100)).Code)
+	stack.Clock.Advance(time.Second)
+	stack.Send(SpeedIncreased("rocket-1", 2, 100))
+	stack.Drain()
+
+	receipt := stack.Receipt(ids[0])
+	assert.Equal(t, Epoch, receipt.QueuedAt)
+	require.NotNil(t, receipt.CompletedAt)
+	assert.Equal(t, Epoch.Add(time.Second), *receipt.CompletedAt)
+	rocket, err := stack.Repository.Get("rocket-1")
+	require.NoError(t, err)
+	assert.Equal(t, Epoch.Add(time.Second), rocket.MessageTime)
+
+	stack.Clock.Advance(2 * time.Minute)
+	assert.Equal(t, http.StatusNotFound, stack.Get("/messages/"+ids[0]).Code)
+}
+
+// TestContactLost tests that silent rockets are listed by
+// GET /rockets?contactLost=true until they are heard from again.
+func TestContactLost(t *testing.T) {
+	stack := New(t, Options{})
+	lostRockets := func() []model.Rocket {
+		var rockets []model.Rocket
+		stack.getJSON("/rockets?contactLost=true", &rockets)
+		return rockets
+	}
+
+	stack.Send(Launched("rocket-1", 1, "Falcon-9", 500, "ARTEMIS"), Launched("rocket-2", 1, "Saturn-V", 1000, "APOLLO"))
+	stack.Drain()
+	stack.Clock.Advance(4 * time.Minute)
+	stack.Send(SpeedIncreased("rocket-2", 2, 100))
+	stack.Drain()
+	stack.Clock.Advance(2 * time.Minute)
+
+	lost, err := stack.Service.CheckContact(5 * time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 1, lost)
+	since := Epoch.Add(5 * time.Minute)
+	assert.Equal(t, []model.Rocket{{Channel: "rocket-1", Type: "Falcon-9", Speed: 500, Mission: "ARTEMIS", ContactLost: true, ContactLostSince: &since}}, lostRockets())
+
+	stack.Send(SpeedDecreased("rocket-1", 2, 100))
+	stack.Drain()
+	assert.Empty(t, lostRockets())
+	stack.AssertRocket(model.Rocket{Channel: "rocket-1", Type: "Falcon-9", Speed: 400, Mission: "ARTEMIS"})
+}
+
+// TestConcurrentWorkers tests that Drain waits for concurrent workers, and
+// that they keep each channel in order.
+func TestConcurrentWorkers(t *testing.T) {
+	stack := New(t, Options{Workers: 4})
+
+	channels := []string{"rocket-1", "rocket-2", "rocket-3", "rocket-4", "rocket-5"}
+	for _, channel := range channels {
+		stack.Send(Launched(channel, 1, "Falcon-9", 0, "ARTEMIS"))
+	}
+	for number := 2; number <= 20; number++ {
+		for _, channel := range channels {
+			stack.Send(SpeedIncreased(channel, number, number))
+		}
+	}
+	stack.Drain()
+
+	for _, channel := range channels {
+		stack.AssertRocket(model.Rocket{Channel: channel, Type: "Falcon-9", Speed: 209, Mission: "ARTEMIS"})
+	}
+	assert.Equal(t, 0, stack.Queue.InFlight())
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// AutoscaleOptions configure WorkerPool.Autoscale.
+type AutoscaleOptions struct {
+	// Min and Max bound the pool size the autoscaler picks.
+	Min int
+	Max int
+	// HighWater is the queue depth above which the pool grows.
+	HighWater int
+	// Interval is how often the queue depth is sampled.
+	Interval time.Duration
+	// ScaleUpAfter is how long the depth must stay above HighWater before the
+	// pool grows by half its size.
+	ScaleUpAfter time.Duration
+	// ScaleDownAfter is how long the queue must stay empty before the pool
+	// shrinks by one worker.
+	ScaleDownAfter time.Duration
+}
+
+// autoscaler turns queue depth samples into pool size changes.
+type autoscaler struct {
+	pool       *WorkerPool
+	opts       AutoscaleOptions
+	upAfter    int
+	downAfter  int
+	aboveCount int
+	idleCount  int
+}
+
+func samplesFor(d, interval time.Duration) int {
+	return max(1, int((d+interval-1)/interval))
+}
+
+// Autoscale adjusts the pool size to the queue depth until ctx is done or the
+// pool is stopped. A manual Resize is kept until the queue conditions call for
+// a change. Cancel ctx before Stop so that the loop is gone on shutdown.
+func (p *WorkerPool) Autoscale(ctx context.Context, opts AutoscaleOptions) {
+	opts.Min = max(opts.Min, 1)
+	opts.Max = min(max(opts.Max, opts.Min), p.max)
+
+	p.mutex.Lock()
+	p.autoscale = &opts
+	p.mutex.Unlock()
+
+	a := &autoscaler{
+		pool:      p,
+		opts:      opts,
+		upAfter:   samplesFor(opts.ScaleUpAfter, opts.Interval),
+		downAfter: samplesFor(opts.ScaleDownAfter, opts.Interval),
+	}
+	slog.Info("Autoscaling workers", "min", opts.Min, "max", opts.Max, "high_water", opts.HighWater)
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// A paused pool lets the queue fill on purpose; more workers
+			// would not drain it.
+			if !p.Paused() && !a.sample(p.queue.Len()) {
+				return
+			}
+		}
+	}
+}
+
+// sample records a queue depth and resizes the pool if it calls for it. The
+// size is read and changed under the pool mutex, so that a manual Resize in
+// between is not undone. It returns false once the pool is stopped.
+func (a *autoscaler) sample(depth int) bool {
+	if depth > a.opts.HighWater {
+		a.aboveCount++
+	} else {
+		a.aboveCount = 0
+	}
+	if depth == 0 {
+		a.idleCount++
+	} else {
+		a.idleCount = 0
+	}
+
+	a.pool.mutex.Lock()
+	defer a.pool.mutex.Unlock()
+	if a.pool.stopped {
+		return false
+	}
+	size := len(a.pool.active)
+	switch {
+	case a.aboveCount >= a.upAfter && size < a.opts.Max:
+		a.aboveCount = 0
+		target := min(size+max(1, size/2), a.opts.Max)
+		slog.Info("Queue depth above high water, growing workers", "depth", depth, "high_water", a.opts.HighWater, "workers", target)
+		a.pool.resize(target)
+	case a.idleCount >= a.downAfter && size > a.opts.Min:
+		a.idleCount = 0
+		a.pool.resize(size - 1)
+	case size < a.opts.Min:
+		a.pool.resize(a.opts.Min)
+	}
+	return true
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+)
+
+// MonitorContact runs CheckContact every interval until ctx is done.
+func MonitorContact(ctx context.Context, svc Service, lostAfter, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := svc.CheckContact(lostAfter); err != nil {
+				slog.Error("Checking rocket contact failed", "error", err)
+			}
+		}
+	}
+}
+
+func (s *service) CheckContact(lostAfter time.Duration) (int, error) {
+	cutoff := s.now().Add(-lostAfter)
+	var silent []string
+	err := s.repo.ForEach(func(rocket model.Rocket) error {
+		if isSilent(rocket, cutoff) {
+			silent = append(silent, rocket.Channel)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error listing rockets: %w", err)
+	}
+
+	lost := 0
+	for _, channel := range silent {
+		flagged, err := s.markContactLost(channel, lostAfter, cutoff)
+		if err != nil {
+			return lost, err
+		}
+		if flagged {
+			lost++
+		}
+	}
+	return lost, nil
+}
+
+// markContactLost flags the rocket on channel unless a message arrived since
+// it was found silent. Contact is lost from the moment the rocket went past
+// lostAfter, however long after that the check runs.
+func (s *service) markContactLost(channel string, lostAfter time.Duration, cutoff time.Time) (bool, error) {
+	unlock := s.locks.lock(channel)
+	defer unlock()
+
+	rocket, err := s.repo.Get(channel)
+	if err != nil {
+		return false, fmt.Errorf("error loading rocket state %s: %w", channel, err)
+	}
+	if !isSilent(rocket, cutoff) {
+		return false, nil
+	}
+
+	previous := rocket
+	since := rocket.ReceivedAt.Add(lostAfter)
+	rocket.ContactLost, rocket.ContactLostSince = true, &since
+	if err := s.repo.Save(rocket); err != nil {
+		return false, fmt.Errorf("error saving rocket state %s: %w", channel, err)
+	}
+	s.stats.apply(&previous, rocket)
+	s.events.PublishKind(model.EventContactLost, rocket, nil)
+	contactLostRockets.Inc()
+	slog.Warn("Contact lost", "channel", channel, "last_received_at", rocket.ReceivedAt, "silent_for", cutoff.Sub(rocket.ReceivedAt)+lostAfter)
+	return true, nil
+}
+
+// isSilent reports whether rocket, not yet flagged, was last heard from before
+// cutoff. Rockets never heard from are left alone.
+func isSilent(rocket model.Rocket, cutoff time.Time) bool {
+	return !rocket.ContactLost && !rocket.ReceivedAt.IsZero() && rocket.ReceivedAt.Before(cutoff)
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/seansa/rocket-challenge/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func speedMessage(channel string, number int) *model.IncomingMessage {
+	return &model.IncomingMessage{
+		Metadata: model.Metadata{Channel: channel, MessageNumber: number, MessageTime: time.Now(), MessageType: model.RocketSpeedIncreased},
+		Message:  json.RawMessage(`{"by": 100}`),
+	}
+}
+
+// TestCheckContact tests that silent rockets are flagged from the moment they
+// went past the threshold, that any message clears the flag, and that both
+// are published.
+func TestCheckContact(t *testing.T) {
+	start := time.Date(2022, 2, 2, 19, 39, 5, 0, time.UTC)
+	now := start
+	svc := NewRocketService(repository.NewRepository[model.Rocket](), WithClock(func() time.Time { return now }))
+	sub := svc.Events().Subscribe(nil)
+	defer sub.Close()
+
+	_, err := svc.ProcessMessage(speedMessage("rocket-a", 2))
+	require.NoError(t, err)
+	_, err = svc.ProcessMessage(speedMessage("rocket-b", 1))
+	require.NoError(t, err)
+	now = now.Add(3 * time.Minute)
+	_, err = svc.ProcessMessage(speedMessage("rocket-b", 2))
+	require.NoError(t, err)
+	now = now.Add(time.Minute)
+	status, err := svc.ProcessMessage(speedMessage("rocket-b", 1))
+	require.NoError(t, err)
+	assert.Equal(t, StatusIgnoredOld, status)
+	rocket, err := svc.GetRocketState("rocket-b")
+	require.NoError(t, err)
+	assert.Equal(t, now, rocket.ReceivedAt, "an ignored message counts as contact")
+
+	lost, err := svc.CheckContact(5 * time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 0, lost)
+
+	now = now.Add(2 * time.Minute)
+	lost, err = svc.CheckContact(5 * time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 1, lost)
+	rocket, err = svc.GetRocketState("rocket-a")
+	require.NoError(t, err)
+	assert.True(t, rocket.ContactLost)
+	require.NotNil(t, rocket.ContactLostSince)
+	assert.Equal(t, start.Add(5*time.Minute), *rocket.ContactLostSince)
+	rocket, err = svc.GetRocketState("rocket-b")
+	require.NoError(t, err)
+	assert.False(t, rocket.ContactLost)
+
+	lost, err = svc.CheckContact(5 * time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 0, lost, "rockets are flagged once")
+	events := drain(sub)
+	require.Len(t, events, 4)
+	assert.Equal(t, model.EventContactLost, events[3].Kind)
+	assert.Equal(t, "rocket-a", events[3].Rocket.Channel)
+	assert.True(t, events[3].Rocket.ContactLost)
+	assert.Nil(t, events[3].Message)
+
+	// Even an ignored message restores contact.
+	status, err = svc.ProcessMessage(speedMessage("rocket-a", 1))
+	require.NoError(t, err)
+	assert.Equal(t, StatusIgnoredOld, status)
+	rocket, err = svc.GetRocketState("rocket-a")
+	require.NoError(t, err)
+	assert.False(t, rocket.ContactLost)
+	assert.Nil(t, rocket.ContactLostSince)
+	assert.Equal(t, now, rocket.ReceivedAt)
+	assert.Equal(t, 100, rocket.Speed)
+	events = drain(sub)
+	require.Len(t, events, 1, "an ignored message publishes no update")
+	assert.Equal(t, model.EventContactRestored, events[0].Kind)
+	assert.False(t, events[0].Rocket.ContactLost)
+	require.NotNil(t, events[0].Message)
+	assert.Equal(t, 1, events[0].Message.Metadata.MessageNumber)
+}
+
+// TestMonitorContact tests that the monitor checks contact until stopped.
+func TestMonitorContact(t *testing.T) {
+	var mutex sync.Mutex
+	now := time.Date(2022, 2, 2, 19, 39, 5, 0, time.UTC)
+	svc := NewRocketService(repository.NewRepository[model.Rocket](), WithClock(func() time.Time {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return now
+	}))
+	_, err := svc.ProcessMessage(speedMessage("rocket-a", 1))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		MonitorContact(ctx, svc, time.Minute, time.Millisecond)
+		close(done)
+	}()
+
+	mutex.Lock()
+	now = now.Add(2 * time.Minute)
+	mutex.Unlock()
+	assert.Eventually(t, func() bool {
+		rocket, err := svc.GetRocketState("rocket-a")
+		return err == nil && rocket.ContactLost
+	}, 2*time.Second, time.Millisecond)
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultDrainWindow is the period DrainMeter averages over.
+const DefaultDrainWindow = 10 * time.Second
+
+// DrainMeter measures how fast the workers take messages off the queue. It
+// counts messages in one-second buckets over a sliding window.
+type DrainMeter struct {
+	now func() time.Time
+
+	mutex   sync.Mutex
+	counts  []int
+	seconds []int64
+}
+
+func NewDrainMeter(window time.Duration) *DrainMeter {
+	if window < time.Second {
+		window = DefaultDrainWindow
+	}
+	size := int(window / time.Second)
+	return &DrainMeter{
+		now:     time.Now,
+		counts:  make([]int, size),
+		seconds: make([]int64, size),
+	}
+}
+
+// mark counts one message taken off the queue.
+func (m *DrainMeter) mark() {
+	second := m.now().Unix()
+	i := int(second % int64(len(m.counts)))
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.seconds[i] != second {
+		m.seconds[i], m.counts[i] = second, 0
+	}
+	m.counts[i]++
+}
+
+// Rate returns the messages per second taken off the queue over the window.
+func (m *DrainMeter) Rate() float64 {
+	now := m.now().Unix()
+	window := int64(len(m.counts))
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	total := 0
+	for i, second := range m.seconds {
+		if now-second < window {
+			total += m.counts[i]
+		}
+	}
+	return float64(total) / float64(window)
+}
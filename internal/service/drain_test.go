@@ -0,0 +1,34 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDrainMeter tests that the rate averages over the window and forgets
+// seconds that left it.
+func TestDrainMeter(t *testing.T) {
+	now := time.Date(2022, 2, 2, 19, 39, 5, 0, time.UTC)
+	meter := NewDrainMeter(4 * time.Second)
+	meter.now = func() time.Time { return now }
+	assert.Zero(t, meter.Rate())
+
+	for range 6 {
+		meter.mark()
+	}
+	now = now.Add(time.Second)
+	for range 2 {
+		meter.mark()
+	}
+	assert.Equal(t, 2.0, meter.Rate(), "8 messages over 4s")
+
+	now = now.Add(3 * time.Second)
+	assert.Equal(t, 0.5, meter.Rate(), "the first second left the window")
+	meter.mark()
+	assert.Equal(t, 0.75, meter.Rate(), "its bucket is reused")
+
+	now = now.Add(time.Minute)
+	assert.Zero(t, meter.Rate())
+}
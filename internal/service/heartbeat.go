@@ -0,0 +1,91 @@
+package service
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+)
+
+// workerHeartbeatInterval is how often an idle worker reports that it is alive.
+const workerHeartbeatInterval = time.Second
+
+// DefaultWorkerStallTimeout is how long a worker may go without a heartbeat,
+// typically because it is stuck in ProcessMessage, before it counts as wedged.
+const DefaultWorkerStallTimeout = 30 * time.Second
+
+type workerBeat struct {
+	state     string
+	last      time.Time
+	busySince time.Time
+}
+
+// Heartbeats records the liveness of the message processing workers. Workers
+// beat before and after every message and on a ticker while idle, so a worker
+// whose last heartbeat is older than the stall timeout is wedged.
+type Heartbeats struct {
+	mutex        sync.Mutex
+	workers      map[int]*workerBeat
+	stallTimeout time.Duration
+	now          func() time.Time
+}
+
+func NewHeartbeats(stallTimeout time.Duration) *Heartbeats {
+	if stallTimeout <= 0 {
+		stallTimeout = DefaultWorkerStallTimeout
+	}
+	return &Heartbeats{
+		workers:      make(map[int]*workerBeat),
+		stallTimeout: stallTimeout,
+		now:          time.Now,
+	}
+}
+
+func (h *Heartbeats) beat(id int, state string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	now := h.now()
+	w, exists := h.workers[id]
+	if !exists {
+		w = &workerBeat{}
+		h.workers[id] = w
+	}
+	if state == model.WorkerBusy && w.state != model.WorkerBusy {
+		w.busySince = now
+	}
+	w.state = state
+	w.last = now
+}
+
+// Workers returns the last heartbeat of every worker, sorted by id.
+func (h *Heartbeats) Workers() []model.WorkerHealth {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	now := h.now()
+	workers := make([]model.WorkerHealth, 0, len(h.workers))
+	for id, w := range h.workers {
+		health := model.WorkerHealth{
+			ID:            id,
+			State:         w.state,
+			LastHeartbeat: w.last,
+			Stalled:       w.state != model.WorkerStopped && now.Sub(w.last) > h.stallTimeout,
+		}
+		if w.state == model.WorkerBusy {
+			busySince := w.busySince
+			health.BusySince = &busySince
+		}
+		workers = append(workers, health)
+	}
+	sort.Slice(workers, func(i, j int) bool { return workers[i].ID < workers[j].ID })
+	return workers
+}
+
+// remove forgets a worker that left the pool.
+func (h *Heartbeats) remove(id int) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	delete(h.workers, id)
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/seansa/rocket-challenge/internal/queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingService processes messages only when released.
+type blockingService struct {
+	Service
+	release chan struct{}
+}
+
+func (s *blockingService) ProcessMessage(msg *model.IncomingMessage) (string, error) {
+	<-s.release
+	return StatusProcessed, nil
+}
+
+// TestHeartbeats_StalledWorker tests that a worker stuck in ProcessMessage is
+// reported as wedged once the stall timeout has passed, and recovers afterwards.
+func TestHeartbeats_StalledWorker(t *testing.T) {
+	heartbeats := NewHeartbeats(time.Minute)
+	now := time.Now()
+	heartbeats.now = func() time.Time { return now }
+
+	q := queue.NewMemory(1, queue.Lanes{})
+	svc := &blockingService{release: make(chan struct{})}
+	require.NoError(t, NewWorkerPool(q, svc, heartbeats, 0).Resize(2))
+
+	require.Eventually(t, func() bool { return len(heartbeats.Workers()) == 2 }, time.Second, time.Millisecond)
+	require.NoError(t, q.Enqueue(context.Background(), model.IncomingMessage{Metadata: model.Metadata{Channel: "stuck"}}))
+
+	var busy model.WorkerHealth
+	require.Eventually(t, func() bool {
+		for _, w := range heartbeats.Workers() {
+			if w.State == model.WorkerBusy {
+				busy = w
+				return true
+			}
+		}
+		return false
+	}, time.Second, time.Millisecond)
+	require.NotNil(t, busy.BusySince)
+	assert.False(t, busy.Stalled)
+
+	// The idle worker keeps beating on its ticker; the busy one cannot.
+	heartbeats.mutex.Lock()
+	now = now.Add(2 * time.Minute)
+	heartbeats.mutex.Unlock()
+	for _, w := range heartbeats.Workers() {
+		if w.ID == busy.ID {
+			assert.True(t, w.Stalled)
+		}
+	}
+
+	close(svc.release)
+	require.Eventually(t, func() bool {
+		for _, w := range heartbeats.Workers() {
+			if w.ID == busy.ID {
+				return w.State == model.WorkerIdle && !w.Stalled
+			}
+		}
+		return false
+	}, time.Second, time.Millisecond)
+
+	q.Close()
+	require.Eventually(t, func() bool {
+		for _, w := range heartbeats.Workers() {
+			if w.State != model.WorkerStopped || w.Stalled {
+				return false
+			}
+		}
+		return true
+	}, time.Second, time.Millisecond)
+}
@@ -0,0 +1,23 @@
+package service
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+const channelLockStripes = 64
+
+// channelLocks serializes work per rocket channel using a fixed set of striped
+// mutexes, so unrelated channels rarely contend and memory stays bounded.
+type channelLocks struct {
+	stripes [channelLockStripes]sync.Mutex
+}
+
+// lock acquires the mutex guarding channel and returns its release function.
+func (l *channelLocks) lock(channel string) func() {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(channel))
+	m := &l.stripes[h.Sum32()%channelLockStripes]
+	m.Lock()
+	return m.Unlock
+}
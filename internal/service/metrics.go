@@ -0,0 +1,24 @@
+package service
+
+import "github.com/seansa/rocket-challenge/internal/metrics"
+
+// statusError labels ProcessMessage calls that returned an error.
+const statusError = "error"
+
+var (
+	processedMessages = metrics.Default.NewCounterVec("rocket_messages_processed_total",
+		"Messages handled by ProcessMessage, by outcome.", "status")
+	processingSeconds = metrics.Default.NewHistogramVec("rocket_message_processing_seconds",
+		"Time spent in ProcessMessage, including waiting for the channel lock, by outcome.", metrics.DefaultBuckets, "status")
+	workerBusySeconds = metrics.Default.NewCounterVec("rocket_worker_busy_seconds_total",
+		"Time each worker spent processing messages.", "worker")
+	contactLostRockets = metrics.Default.NewCounter("rocket_contact_lost_total",
+		"Times a rocket was flagged contactLost after staying silent.")
+)
+
+func outcomeLabel(status string, err error) string {
+	if err != nil {
+		return statusError
+	}
+	return status
+}
@@ -1,27 +1,299 @@
 package service
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
 
+	"github.com/seansa/rocket-challenge/internal/logging"
+	"github.com/seansa/rocket-challenge/internal/metrics"
 	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/seansa/rocket-challenge/internal/queue"
 )
 
-func processMessageWorker(id int, messageChannel <-chan model.IncomingMessage, svc Service) {
-	log.Printf("Worker %d started.", id)
-	for msg := range messageChannel {
-		log.Printf("Worker %d received message for channel %s (msg #%d).", id, msg.Metadata.Channel, msg.Metadata.MessageNumber)
-		status, err := svc.ProcessMessage(&msg)
-		if err != nil {
-			log.Printf("Worker %d ERROR processing message for channel %s (msg #%d): %v", id, msg.Metadata.Channel, msg.Metadata.MessageNumber, err)
-		} else {
-			log.Printf("Worker %d successfully processed message for channel %s (msg #%d): Status: %s", id, msg.Metadata.Channel, msg.Metadata.MessageNumber, status)
+// DefaultMaxWorkers bounds the pool when no explicit maximum is configured.
+const DefaultMaxWorkers = 64
+
+// ErrPoolStopped is returned by WorkerPool.Resize after Stop.
+var ErrPoolStopped = errors.New("worker pool stopped")
+
+// WorkerPool runs the message processing workers and lets their number change
+// at runtime. Workers leave the pool between messages, so resizing never
+// interrupts a message being processed.
+type WorkerPool struct {
+	queue      queue.Queue
+	svc        Service
+	heartbeats *Heartbeats
+	receipts   *Receipts
+	drain      *DrainMeter
+	max        int
+
+	mutex     sync.Mutex
+	active    map[int]chan struct{}
+	stopping  map[int]bool
+	stopped   bool
+	autoscale *AutoscaleOptions
+	// pausedSince is set while processing is paused as a whole.
+	pausedSince    time.Time
+	pausedChannels map[string]time.Time
+	wg             sync.WaitGroup
+}
+
+func NewWorkerPool(q queue.Queue, svc Service, heartbeats *Heartbeats, max int) *WorkerPool {
+	if max <= 0 {
+		max = DefaultMaxWorkers
+	}
+	return &WorkerPool{
+		queue:          q,
+		svc:            svc,
+		heartbeats:     heartbeats,
+		drain:          NewDrainMeter(DefaultDrainWindow),
+		max:            max,
+		active:         make(map[int]chan struct{}),
+		stopping:       make(map[int]bool),
+		pausedChannels: make(map[string]time.Time),
+	}
+}
+
+// SetReceipts makes the workers record the lifecycle of every message that
+// has a receipt. It must be called before the first Resize.
+func (p *WorkerPool) SetReceipts(receipts *Receipts) {
+	p.receipts = receipts
+}
+
+// DrainRate returns how many messages per second the workers have recently
+// taken off the queue.
+func (p *WorkerPool) DrainRate() float64 {
+	return p.drain.Rate()
+}
+
+// Size returns the number of workers currently in the pool.
+func (p *WorkerPool) Size() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return len(p.active)
+}
+
+// Resize starts or stops workers until the pool has n of them. It fails with
+// ErrPoolStopped once Stop was called.
+func (p *WorkerPool) Resize(n int) error {
+	if n < 1 || n > p.max {
+		return fmt.Errorf("worker count %d outside [1, %d]: %w", n, p.max, model.ErrInvalidPayload)
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.stopped {
+		return ErrPoolStopped
+	}
+	p.resize(n)
+	return nil
+}
+
+// resize must be called with the mutex held.
+func (p *WorkerPool) resize(n int) {
+	previous := len(p.active)
+
+	// Worker ids are reused so that per-worker metrics stay bounded, but only
+	// once the previous worker with that id has exited.
+	for id := 1; len(p.active) < n; id++ {
+		if _, busy := p.active[id]; busy || p.stopping[id] {
+			continue
+		}
+		stop := make(chan struct{})
+		p.active[id] = stop
+		p.wg.Add(1)
+		go p.run(id, stop)
+	}
+
+	// The highest ids leave first.
+	if len(p.active) > n {
+		ids := make([]int, 0, len(p.active))
+		for id := range p.active {
+			ids = append(ids, id)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(ids)))
+		for _, id := range ids[:len(ids)-n] {
+			close(p.active[id])
+			delete(p.active, id)
+			p.stopping[id] = true
 		}
 	}
-	log.Printf("Worker %d stopped.", id)
+
+	if previous != len(p.active) {
+		slog.Info("Worker pool resized", "from", previous, "to", len(p.active))
+	}
 }
 
-func StartMessageProcessor(messageChannel <-chan model.IncomingMessage, svc Service, numWorkers int) {
-	for i := range numWorkers {
-		go processMessageWorker(i+1, messageChannel, svc)
+// Stop stops every worker and waits for them to finish their current message.
+// The pool cannot be resized afterwards.
+func (p *WorkerPool) Stop() {
+	p.mutex.Lock()
+	p.stopped = true
+	p.resize(0)
+	p.mutex.Unlock()
+	p.wg.Wait()
+}
+
+// Status describes the pool for the admin API.
+func (p *WorkerPool) Status() model.WorkerPoolStatus {
+	p.mutex.Lock()
+	status := model.WorkerPoolStatus{Count: len(p.active), Max: p.max}
+	if p.autoscale != nil {
+		status.Autoscale = &model.AutoscaleStatus{
+			Min:       p.autoscale.Min,
+			Max:       p.autoscale.Max,
+			HighWater: p.autoscale.HighWater,
+		}
+	}
+	p.mutex.Unlock()
+
+	status.Workers = p.heartbeats.Workers()
+	return status
+}
+
+// Pause stops the workers from taking the messages of channel, or any message
+// if channel is empty, off the queue. The queue keeps accepting messages until
+// it is full. Messages being processed are finished.
+func (p *WorkerPool) Pause(channel string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if channel == "" {
+		if p.pausedSince.IsZero() {
+			p.pausedSince = time.Now()
+			slog.Info("Message processing paused", "queued", p.queue.Len())
+		}
+	} else if _, paused := p.pausedChannels[channel]; !paused {
+		p.pausedChannels[channel] = time.Now()
+		slog.Info("Message processing paused", "channel", channel)
+	}
+	p.queue.Pause(channel)
+}
+
+// Resume undoes Pause for channel, or for the pool as a whole if channel is
+// empty. Channels paused on their own stay paused.
+func (p *WorkerPool) Resume(channel string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if channel == "" {
+		if !p.pausedSince.IsZero() {
+			slog.Info("Message processing resumed", "paused_for", time.Since(p.pausedSince).Round(time.Millisecond), "queued", p.queue.Len())
+			p.pausedSince = time.Time{}
+		}
+	} else if since, paused := p.pausedChannels[channel]; paused {
+		slog.Info("Message processing resumed", "channel", channel, "paused_for", time.Since(since).Round(time.Millisecond))
+		delete(p.pausedChannels, channel)
+	}
+	p.queue.Resume(channel)
+}
+
+// Paused reports whether processing is paused as a whole.
+func (p *WorkerPool) Paused() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return !p.pausedSince.IsZero()
+}
+
+// Processing describes the pause state for the admin API.
+func (p *WorkerPool) Processing() model.ProcessingStatus {
+	p.mutex.Lock()
+	status := model.ProcessingStatus{Status: model.ProcessingRunning, PausedChannels: []model.ChannelPause{}}
+	if !p.pausedSince.IsZero() {
+		since := p.pausedSince
+		status.Status, status.PausedSince = model.ProcessingPaused, &since
+	}
+	for channel, since := range p.pausedChannels {
+		status.PausedChannels = append(status.PausedChannels, model.ChannelPause{Channel: channel, PausedSince: since})
+	}
+	p.mutex.Unlock()
+
+	sort.Slice(status.PausedChannels, func(i, j int) bool { return status.PausedChannels[i].Channel < status.PausedChannels[j].Channel })
+	status.Queue = model.QueueStatus{Depth: p.queue.Len(), Capacity: p.queue.Cap(), DrainRate: p.DrainRate()}
+	return status
+}
+
+// Work processes waiting messages on the calling goroutine until the queue is
+// empty, and returns how many it processed. It lets tests run a pool without
+// workers deterministically. It gives up with ctx.Err() when ctx is done
+// first, for example while messages are held back by a pause.
+func (p *WorkerPool) Work(ctx context.Context) (int, error) {
+	busy := workerBusySeconds.WithLabelValues("0")
+	processed := 0
+	for p.queue.Len() > 0 {
+		select {
+		case delivery, ok := <-p.queue.Deliveries():
+			if !ok {
+				return processed, queue.ErrClosed
+			}
+			p.process(0, delivery, busy)
+			processed++
+		case <-ctx.Done():
+			return processed, ctx.Err()
+		}
+	}
+	return processed, nil
+}
+
+// process runs one message through the service and settles its delivery.
+func (p *WorkerPool) process(id int, delivery queue.Delivery, busy *metrics.Counter) {
+	msg := delivery.Message
+	p.drain.mark()
+	msgLogger := logging.ForMessage(&msg).With("worker", id)
+	msgLogger.Debug("Worker received message")
+	p.receipts.start(msg.ReceiptID)
+	start := time.Now()
+	status, err := p.svc.ProcessMessage(&msg)
+	busy.Add(time.Since(start).Seconds())
+	p.receipts.finish(msg.ReceiptID, status, err)
+	// A message that can never be applied is acknowledged too, or it would
+	// hold a durable queue's offset back and be rejected again after every
+	// restart. Only transient failures are left for redelivery.
+	switch {
+	case errors.Is(err, model.ErrInvalidPayload):
+		delivery.Ack()
+		msgLogger.Warn("Message rejected", "error", err)
+	case err != nil:
+		delivery.Nack()
+		msgLogger.Error("Processing message failed", "error", err)
+	default:
+		delivery.Ack()
+		msgLogger.Info("Message processed", "status", status, "duration", time.Since(start))
+	}
+}
+
+func (p *WorkerPool) run(id int, stop <-chan struct{}) {
+	defer p.wg.Done()
+	logger := slog.With("worker", id)
+	logger.Info("Worker started")
+	busy := workerBusySeconds.WithLabelValues(strconv.Itoa(id))
+	ticker := time.NewTicker(workerHeartbeatInterval)
+	defer ticker.Stop()
+
+	p.heartbeats.beat(id, model.WorkerIdle)
+	for {
+		select {
+		case <-stop:
+			p.mutex.Lock()
+			delete(p.stopping, id)
+			p.heartbeats.remove(id)
+			p.mutex.Unlock()
+			logger.Info("Worker left the pool")
+			return
+		case delivery, ok := <-p.queue.Deliveries():
+			if !ok {
+				p.heartbeats.beat(id, model.WorkerStopped)
+				logger.Info("Worker stopped")
+				return
+			}
+			p.heartbeats.beat(id, model.WorkerBusy)
+			p.process(id, delivery, busy)
+			p.heartbeats.beat(id, model.WorkerIdle)
+		case <-ticker.C:
+			p.heartbeats.beat(id, model.WorkerIdle)
+		}
 	}
 }
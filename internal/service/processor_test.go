@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/seansa/rocket-challenge/internal/queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingService counts processed messages and can hold them until released.
+// Messages for the failing channel fail with a transient error, and messages
+// for the rejected channel with an invalid payload.
+type countingService struct {
+	Service
+	mutex     sync.Mutex
+	processed int
+	hold      chan struct{}
+	failing   string
+	rejected  string
+}
+
+func (s *countingService) ProcessMessage(msg *model.IncomingMessage) (string, error) {
+	if s.hold != nil {
+		<-s.hold
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.processed++
+	switch msg.Metadata.Channel {
+	case "":
+	case s.failing:
+		return "", fmt.Errorf("rocket %s: repository unreachable", msg.Metadata.Channel)
+	case s.rejected:
+		return "", fmt.Errorf("rocket %s: %w", msg.Metadata.Channel, model.ErrInvalidPayload)
+	}
+	return StatusProcessed, nil
+}
+
+func (s *countingService) count() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.processed
+}
+
+func workerIDs(h *Heartbeats) []int {
+	var ids []int
+	for _, w := range h.Workers() {
+		ids = append(ids, w.ID)
+	}
+	return ids
+}
+
+// TestWorkerPool_Resize tests growing and shrinking the pool, and that ids are
+// reused lowest first.
+func TestWorkerPool_Resize(t *testing.T) {
+	q := queue.NewMemory(10, queue.Lanes{})
+	defer q.Close()
+	svc := &countingService{}
+	heartbeats := NewHeartbeats(time.Minute)
+	pool := NewWorkerPool(q, svc, heartbeats, 4)
+
+	require.NoError(t, pool.Resize(3))
+	assert.Equal(t, 3, pool.Size())
+	require.Eventually(t, func() bool { return len(workerIDs(heartbeats)) == 3 }, time.Second, time.Millisecond)
+
+	require.NoError(t, pool.Resize(1))
+	assert.Equal(t, 1, pool.Size())
+	require.Eventually(t, func() bool { return assert.ObjectsAreEqual([]int{1}, workerIDs(heartbeats)) }, time.Second, time.Millisecond)
+
+	require.NoError(t, pool.Resize(2))
+	require.Eventually(t, func() bool { return assert.ObjectsAreEqual([]int{1, 2}, workerIDs(heartbeats)) }, time.Second, time.Millisecond)
+
+	assert.ErrorIs(t, pool.Resize(0), model.ErrInvalidPayload)
+	assert.ErrorIs(t, pool.Resize(5), model.ErrInvalidPayload)
+	assert.Equal(t, 2, pool.Size())
+
+	for range 10 {
+		require.NoError(t, q.Enqueue(context.Background(), model.IncomingMessage{}))
+	}
+	assert.Eventually(t, func() bool { return svc.count() == 10 }, time.Second, time.Millisecond)
+
+	status := pool.Status()
+	assert.Equal(t, 2, status.Count)
+	assert.Equal(t, 4, status.Max)
+	assert.Nil(t, status.Autoscale)
+	assert.Len(t, status.Workers, 2)
+}
+
+// TestWorkerPool_StopWaitsForInFlight tests that shrinking never interrupts a
+// message and Stop waits for it.
+func TestWorkerPool_StopWaitsForInFlight(t *testing.T) {
+	q := queue.NewMemory(1, queue.Lanes{})
+	svc := &countingService{hold: make(chan struct{})}
+	pool := NewWorkerPool(q, svc, NewHeartbeats(time.Minute), 0)
+	require.NoError(t, pool.Resize(1))
+
+	require.NoError(t, q.Enqueue(context.Background(), model.IncomingMessage{}))
+	require.Eventually(t, func() bool { return q.Len() == 0 }, time.Second, time.Millisecond)
+
+	stopped := make(chan struct{})
+	go func() {
+		pool.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		t.Fatal("Stop returned while a message was being processed")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(svc.hold)
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop did not return")
+	}
+	assert.Equal(t, 1, svc.count())
+	assert.Equal(t, 0, pool.Size())
+}
+
+// TestWorkerPool_AcksProcessed tests that processed and rejected messages are
+// acknowledged, and only a transient failure is delivered again by a reopened
+// disk queue.
+func TestWorkerPool_AcksProcessed(t *testing.T) {
+	dir := t.TempDir()
+	q, err := queue.OpenDisk(dir, queue.DiskOptions{Capacity: 10, RetryBackoff: time.Hour})
+	require.NoError(t, err)
+	svc := &countingService{failing: "rocket-b", rejected: "rocket-c"}
+	pool := NewWorkerPool(q, svc, NewHeartbeats(time.Minute), 0)
+	require.NoError(t, pool.Resize(1))
+
+	for _, channel := range []string{"rocket-c", "rocket-a", "rocket-b"} {
+		require.NoError(t, q.TryEnqueue(model.IncomingMessage{Metadata: model.Metadata{Channel: channel}}))
+	}
+	require.Eventually(t, func() bool { return svc.count() == 3 }, time.Second, time.Millisecond)
+	pool.Stop()
+	require.NoError(t, q.Close())
+
+	q, err = queue.OpenDisk(dir, queue.DiskOptions{Capacity: 10})
+	require.NoError(t, err)
+	defer q.Close()
+	assert.Equal(t, 1, q.Len())
+	assert.Equal(t, "rocket-b", (<-q.Deliveries()).Message.Metadata.Channel)
+}
+
+// TestAutoscaler tests growing after a sustained high queue depth and
+// shrinking after a sustained empty queue, within the bounds.
+func TestAutoscaler(t *testing.T) {
+	q := queue.NewMemory(1, queue.Lanes{})
+	defer q.Close()
+	pool := NewWorkerPool(q, &countingService{}, NewHeartbeats(time.Minute), 0)
+	defer pool.Stop()
+	require.NoError(t, pool.Resize(2))
+
+	a := &autoscaler{
+		pool:      pool,
+		opts:      AutoscaleOptions{Min: 1, Max: 4, HighWater: 10},
+		upAfter:   samplesFor(3*time.Second, time.Second),
+		downAfter: samplesFor(2*time.Second, time.Second),
+	}
+
+	// A spike that does not last does nothing.
+	a.sample(50)
+	a.sample(50)
+	a.sample(5)
+	assert.Equal(t, 2, pool.Size())
+
+	for range 3 {
+		a.sample(50)
+	}
+	assert.Equal(t, 3, pool.Size(), "grows by half, at least one")
+	for range 6 {
+		a.sample(50)
+	}
+	assert.Equal(t, 4, pool.Size(), "never above Max")
+
+	for range 2 {
+		a.sample(0)
+	}
+	assert.Equal(t, 3, pool.Size())
+	for range 10 {
+		a.sample(0)
+	}
+	assert.Equal(t, 1, pool.Size(), "never below Min")
+
+	pool.Stop()
+	assert.False(t, a.sample(0), "a stopped pool ends autoscaling")
+	assert.Equal(t, 0, pool.Size(), "a stopped pool is not grown back to Min")
+	assert.ErrorIs(t, pool.Resize(2), ErrPoolStopped)
+}
+
+// TestAutoscale_StopsWithPool tests that the autoscaler returns when its
+// context is done or the pool is stopped.
+func TestAutoscale_StopsWithPool(t *testing.T) {
+	q := queue.NewMemory(1, queue.Lanes{})
+	defer q.Close()
+	opts := AutoscaleOptions{Min: 1, Max: 2, HighWater: 0, Interval: time.Millisecond, ScaleUpAfter: time.Millisecond, ScaleDownAfter: time.Millisecond}
+
+	for _, stop := range []string{"context", "pool"} {
+		pool := NewWorkerPool(q, &countingService{}, NewHeartbeats(time.Minute), 0)
+		require.NoError(t, pool.Resize(1))
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			pool.Autoscale(ctx, opts)
+			close(done)
+		}()
+
+		if stop == "context" {
+			cancel()
+		}
+		pool.Stop()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("Autoscale did not return after stopping the %s", stop)
+		}
+		cancel()
+		assert.Equal(t, 0, pool.Size(), stop)
+	}
+}
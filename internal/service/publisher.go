@@ -0,0 +1,188 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+)
+
+const (
+	defaultEventHistorySize = 1024
+	defaultEventBufferSize  = 64
+)
+
+// EventFilter selects the events a subscription receives. A nil filter accepts
+// every event. Resync markers are always delivered.
+type EventFilter func(event model.RocketEvent) bool
+
+// Publisher fans rocket state changes out to any number of subscribers.
+//
+// Publishing never blocks: each subscriber has a bounded buffer, and when it is
+// full the event is dropped for that subscriber and a resync marker is queued
+// ahead of the next event it does receive. The last historySize events are
+// retained so that subscribers can resume after a reconnect.
+type Publisher struct {
+	mutex       sync.Mutex
+	lastID      uint64
+	history     []model.RocketEvent // ring buffer, oldest event at historyHead
+	historyHead int
+	historySize int
+	bufferSize  int
+	subscribers map[*Subscription]struct{}
+}
+
+// Subscription is a live feed of events from a Publisher.
+type Subscription struct {
+	events    chan model.RocketEvent
+	filter    EventFilter
+	lagged    bool
+	publisher *Publisher
+	closeOnce sync.Once
+}
+
+func NewPublisher(historySize, bufferSize int) *Publisher {
+	return &Publisher{
+		historySize: historySize,
+		bufferSize:  bufferSize,
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish records a state change of rocket caused by msg and delivers it to
+// every matching subscriber.
+func (p *Publisher) Publish(rocket model.Rocket, msg model.IncomingMessage) model.RocketEvent {
+	return p.PublishKind(model.EventRocketUpdated, rocket, &msg)
+}
+
+// PublishKind is Publish for events of any kind but resync. msg is nil for
+// events that no message caused.
+func (p *Publisher) PublishKind(kind model.EventKind, rocket model.Rocket, msg *model.IncomingMessage) model.RocketEvent {
+	if msg != nil {
+		copied := *msg
+		msg = &copied
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.lastID++
+	event := model.RocketEvent{
+		ID:      p.lastID,
+		Kind:    kind,
+		Time:    time.Now(),
+		Rocket:  &rocket,
+		Message: msg,
+	}
+
+	switch {
+	case p.historySize <= 0:
+	case len(p.history) < p.historySize:
+		p.history = append(p.history, event)
+	default:
+		p.history[p.historyHead] = event
+		p.historyHead = (p.historyHead + 1) % p.historySize
+	}
+
+	for sub := range p.subscribers {
+		sub.deliver(event)
+	}
+	return event
+}
+
+// Subscribe returns a subscription to events published from now on.
+func (p *Publisher) Subscribe(filter EventFilter) *Subscription {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.subscribe(filter)
+}
+
+// SubscribeFrom returns a subscription that first replays the retained events
+// published after lastEventID. If some of those events are no longer retained
+// the subscription starts with a resync marker instead.
+func (p *Publisher) SubscribeFrom(filter EventFilter, lastEventID uint64) *Subscription {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	sub := p.subscribe(filter)
+
+	oldestRetained := p.lastID + 1
+	if len(p.history) > 0 {
+		oldestRetained = p.history[p.historyHead].ID
+	}
+	if lastEventID+1 < oldestRetained || lastEventID > p.lastID {
+		sub.lagged = true
+	}
+
+	for i := range p.history {
+		event := p.history[(p.historyHead+i)%len(p.history)]
+		if event.ID > lastEventID {
+			sub.deliver(event)
+		}
+	}
+	if sub.lagged {
+		sub.flushResync()
+	}
+	return sub
+}
+
+// LastEventID returns the ID of the most recently published event.
+func (p *Publisher) LastEventID() uint64 {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.lastID
+}
+
+func (p *Publisher) subscribe(filter EventFilter) *Subscription {
+	sub := &Subscription{
+		events:    make(chan model.RocketEvent, p.bufferSize),
+		filter:    filter,
+		publisher: p,
+	}
+	p.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// Events returns the channel events are delivered on. It is closed by Close.
+func (s *Subscription) Events() <-chan model.RocketEvent {
+	return s.events
+}
+
+// Close unsubscribes and closes the events channel. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.closeOnce.Do(func() {
+		s.publisher.mutex.Lock()
+		defer s.publisher.mutex.Unlock()
+
+		delete(s.publisher.subscribers, s)
+		close(s.events)
+	})
+}
+
+// deliver must be called with the publisher mutex held.
+func (s *Subscription) deliver(event model.RocketEvent) {
+	if s.filter != nil && !s.filter(event) {
+		return
+	}
+	if s.lagged && !s.flushResync() {
+		return
+	}
+	select {
+	case s.events <- event:
+	default:
+		s.lagged = true
+	}
+}
+
+// flushResync tries to queue the resync marker owed to a lagging subscriber.
+func (s *Subscription) flushResync() bool {
+	select {
+	case s.events <- model.RocketEvent{Kind: model.EventResync, Time: time.Now()}:
+		s.lagged = false
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func drain(sub *Subscription) []model.RocketEvent {
+	var events []model.RocketEvent
+	for {
+		select {
+		case event := <-sub.Events():
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+// TestPublisher_FanOutWithFilter tests that every subscriber gets the events matching its filter.
+func TestPublisher_FanOutWithFilter(t *testing.T) {
+	publisher := NewPublisher(8, 8)
+	all := publisher.Subscribe(nil)
+	onlyB := publisher.Subscribe(func(event model.RocketEvent) bool { return event.Rocket.Channel == "b" })
+	defer all.Close()
+	defer onlyB.Close()
+
+	publisher.Publish(model.NewRocket("a"), model.IncomingMessage{})
+	publisher.Publish(model.NewRocket("b"), model.IncomingMessage{})
+
+	assert.Len(t, drain(all), 2)
+	events := drain(onlyB)
+	require.Len(t, events, 1)
+	assert.Equal(t, uint64(2), events[0].ID)
+	assert.Equal(t, "b", events[0].Rocket.Channel)
+}
+
+// TestPublisher_SlowSubscriberGetsResync tests that a full buffer drops events and queues a resync marker.
+func TestPublisher_SlowSubscriberGetsResync(t *testing.T) {
+	publisher := NewPublisher(8, 2)
+	sub := publisher.Subscribe(nil)
+	defer sub.Close()
+
+	for range 4 {
+		publisher.Publish(model.NewRocket("a"), model.IncomingMessage{})
+	}
+	events := drain(sub)
+	require.Len(t, events, 2)
+	assert.Equal(t, uint64(1), events[0].ID)
+	assert.Equal(t, uint64(2), events[1].ID)
+
+	publisher.Publish(model.NewRocket("a"), model.IncomingMessage{})
+	events = drain(sub)
+	require.Len(t, events, 2)
+	assert.Equal(t, model.EventResync, events[0].Kind)
+	assert.Equal(t, uint64(5), events[1].ID)
+}
+
+// TestPublisher_SubscribeFrom tests resumption from retained history.
+func TestPublisher_SubscribeFrom(t *testing.T) {
+	publisher := NewPublisher(3, 8)
+	for range 5 {
+		publisher.Publish(model.NewRocket("a"), model.IncomingMessage{})
+	}
+	assert.Equal(t, uint64(5), publisher.LastEventID())
+
+	resumed := publisher.SubscribeFrom(nil, 3)
+	events := drain(resumed)
+	require.Len(t, events, 2)
+	assert.Equal(t, uint64(4), events[0].ID)
+	assert.Equal(t, uint64(5), events[1].ID)
+	resumed.Close()
+
+	tooOld := publisher.SubscribeFrom(nil, 1)
+	events = drain(tooOld)
+	require.Len(t, events, 4)
+	assert.Equal(t, model.EventResync, events[0].Kind)
+	assert.Equal(t, uint64(3), events[1].ID)
+	tooOld.Close()
+
+	fromTheFuture := publisher.SubscribeFrom(nil, 42)
+	events = drain(fromTheFuture)
+	require.Len(t, events, 1)
+	assert.Equal(t, model.EventResync, events[0].Kind)
+	fromTheFuture.Close()
+	fromTheFuture.Close()
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"container/list"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+)
+
+// Receipt store defaults.
+const (
+	DefaultReceiptCapacity = 100_000
+	DefaultReceiptTTL      = time.Hour
+)
+
+// receiptStatuses maps ProcessMessage outcomes to receipt states.
+var receiptStatuses = map[string]string{
+	StatusProcessed:  model.ReceiptProcessed,
+	StatusIgnoredOld: model.ReceiptIgnoredOld,
+	StatusDuplicate:  model.ReceiptDuplicate,
+}
+
+// Receipts keeps the lifecycle of recently accepted messages. It holds at most
+// capacity receipts, evicting the oldest first, and forgets receipts once they
+// are older than the TTL. A nil *Receipts records nothing.
+type Receipts struct {
+	capacity int
+	ttl      time.Duration
+	now      func() time.Time
+
+	mutex    sync.Mutex
+	receipts map[string]*list.Element
+	// order lists receipts from oldest to newest, which is also expiry order.
+	order *list.List
+}
+
+func NewReceipts(capacity int, ttl time.Duration) *Receipts {
+	if capacity <= 0 {
+		capacity = DefaultReceiptCapacity
+	}
+	if ttl <= 0 {
+		ttl = DefaultReceiptTTL
+	}
+	return &Receipts{
+		capacity: capacity,
+		ttl:      ttl,
+		now:      time.Now,
+		receipts: make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// SetClock replaces time.Now, so that tests can expire receipts without
+// waiting. It must be called before the first Issue.
+func (r *Receipts) SetClock(now func() time.Time) {
+	r.now = now
+}
+
+// Issue records msg as queued and sets its ReceiptID.
+func (r *Receipts) Issue(msg *model.IncomingMessage) string {
+	if r == nil {
+		return ""
+	}
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+	msg.ReceiptID = hex.EncodeToString(id[:])
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := r.now()
+	r.prune(now)
+	for r.order.Len() >= r.capacity {
+		r.remove(r.order.Front())
+	}
+	r.receipts[msg.ReceiptID] = r.order.PushBack(&model.Receipt{
+		ID:            msg.ReceiptID,
+		RequestID:     msg.RequestID,
+		Channel:       msg.Metadata.Channel,
+		MessageNumber: msg.Metadata.MessageNumber,
+		Status:        model.ReceiptQueued,
+		QueuedAt:      now,
+	})
+	return msg.ReceiptID
+}
+
+// Discard forgets the receipt of a message that was not queued after all.
+func (r *Receipts) Discard(id string) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if element, exists := r.receipts[id]; exists {
+		r.remove(element)
+	}
+}
+
+// Get returns the receipt with the given id, or an error wrapping
+// model.ErrNotFound once it has expired or been evicted.
+func (r *Receipts) Get(id string) (model.Receipt, error) {
+	if r != nil {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		r.prune(r.now())
+		if element, exists := r.receipts[id]; exists {
+			return *element.Value.(*model.Receipt), nil
+		}
+	}
+	return model.Receipt{}, fmt.Errorf("receipt %s: %w", id, model.ErrNotFound)
+}
+
+// start marks the receipt as being processed.
+func (r *Receipts) start(id string) {
+	r.update(id, func(receipt *model.Receipt, now time.Time) {
+		receipt.Status = model.ReceiptProcessing
+		receipt.ProcessingAt = &now
+	})
+}
+
+// finish records the outcome of ProcessMessage.
+func (r *Receipts) finish(id, status string, err error) {
+	r.update(id, func(receipt *model.Receipt, now time.Time) {
+		receipt.CompletedAt = &now
+		if err != nil {
+			receipt.Status = model.ReceiptFailed
+			receipt.Error = err.Error()
+			return
+		}
+		receipt.Status = receiptStatuses[status]
+	})
+}
+
+func (r *Receipts) update(id string, fn func(receipt *model.Receipt, now time.Time)) {
+	if r == nil || id == "" {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if element, exists := r.receipts[id]; exists {
+		fn(element.Value.(*model.Receipt), r.now())
+	}
+}
+
+// prune drops expired receipts. It must be called with the mutex held.
+func (r *Receipts) prune(now time.Time) {
+	for element := r.order.Front(); element != nil; element = r.order.Front() {
+		if now.Sub(element.Value.(*model.Receipt).QueuedAt) < r.ttl {
+			return
+		}
+		r.remove(element)
+	}
+}
+
+// remove must be called with the mutex held.
+func (r *Receipts) remove(element *list.Element) {
+	delete(r.receipts, element.Value.(*model.Receipt).ID)
+	r.order.Remove(element)
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/seansa/rocket-challenge/internal/queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func receiptMessage(channel string, number int) *model.IncomingMessage {
+	return &model.IncomingMessage{
+		Metadata:  model.Metadata{Channel: channel, MessageNumber: number},
+		RequestID: "req-" + channel,
+	}
+}
+
+// TestReceipts_Lifecycle tests the states a receipt goes through.
+func TestReceipts_Lifecycle(t *testing.T) {
+	now := time.Date(2022, 2, 2, 19, 39, 5, 0, time.UTC)
+	receipts := NewReceipts(10, time.Hour)
+	receipts.now = func() time.Time { return now }
+
+	msg := receiptMessage("rocket-a", 1)
+	id := receipts.Issue(msg)
+	assert.Len(t, id, 32)
+	assert.Equal(t, id, msg.ReceiptID)
+
+	receipt, err := receipts.Get(id)
+	require.NoError(t, err)
+	assert.Equal(t, model.Receipt{ID: id, RequestID: "req-rocket-a", Channel: "rocket-a", MessageNumber: 1, Status: model.ReceiptQueued, QueuedAt: now}, receipt)
+
+	now = now.Add(time.Second)
+	receipts.start(id)
+	receipt, _ = receipts.Get(id)
+	assert.Equal(t, model.ReceiptProcessing, receipt.Status)
+	assert.Equal(t, now, *receipt.ProcessingAt)
+	assert.Nil(t, receipt.CompletedAt)
+
+	now = now.Add(time.Second)
+	receipts.finish(id, StatusIgnoredOld, nil)
+	receipt, _ = receipts.Get(id)
+	assert.Equal(t, model.ReceiptIgnoredOld, receipt.Status)
+	assert.Equal(t, now, *receipt.CompletedAt)
+
+	for status, expected := range map[string]string{StatusProcessed: model.ReceiptProcessed, StatusDuplicate: model.ReceiptDuplicate} {
+		id := receipts.Issue(receiptMessage("rocket-b", 1))
+		receipts.finish(id, status, nil)
+		receipt, _ := receipts.Get(id)
+		assert.Equal(t, expected, receipt.Status)
+	}
+
+	id = receipts.Issue(receiptMessage("rocket-c", 1))
+	receipts.finish(id, "", errors.New("repository unavailable"))
+	receipt, _ = receipts.Get(id)
+	assert.Equal(t, model.ReceiptFailed, receipt.Status)
+	assert.Equal(t, "repository unavailable", receipt.Error)
+
+	receipts.Discard(id)
+	_, err = receipts.Get(id)
+	assert.ErrorIs(t, err, model.ErrNotFound)
+}
+
+// TestReceipts_Bounds tests eviction of the oldest receipts and expiry.
+func TestReceipts_Bounds(t *testing.T) {
+	now := time.Now()
+	receipts := NewReceipts(3, time.Minute)
+	receipts.now = func() time.Time { return now }
+
+	var ids []string
+	for i := 1; i <= 4; i++ {
+		ids = append(ids, receipts.Issue(receiptMessage("rocket-a", i)))
+		now = now.Add(10 * time.Second)
+	}
+	_, err := receipts.Get(ids[0])
+	assert.ErrorIs(t, err, model.ErrNotFound, "the oldest receipt is evicted")
+	for _, id := range ids[1:] {
+		_, err := receipts.Get(id)
+		assert.NoError(t, err)
+	}
+
+	// ids[1] was issued 30s ago, ids[3] 10s ago.
+	now = now.Add(35 * time.Second)
+	_, err = receipts.Get(ids[1])
+	assert.ErrorIs(t, err, model.ErrNotFound, "expired")
+	_, err = receipts.Get(ids[3])
+	assert.NoError(t, err)
+
+	receipts.start(ids[1])
+	assert.Equal(t, 2, receipts.order.Len(), "updates to forgotten receipts are ignored")
+}
+
+// TestReceipts_Nil tests that a nil store records nothing.
+func TestReceipts_Nil(t *testing.T) {
+	var receipts *Receipts
+	msg := receiptMessage("rocket-a", 1)
+	assert.Empty(t, receipts.Issue(msg))
+	assert.Empty(t, msg.ReceiptID)
+	receipts.start("x")
+	receipts.finish("x", StatusProcessed, nil)
+	receipts.Discard("x")
+	_, err := receipts.Get("x")
+	assert.ErrorIs(t, err, model.ErrNotFound)
+}
+
+// TestWorkerPool_Receipts tests that workers record the outcome of messages.
+func TestWorkerPool_Receipts(t *testing.T) {
+	q := queue.NewMemory(1, queue.Lanes{})
+	receipts := NewReceipts(10, time.Hour)
+	pool := NewWorkerPool(q, &countingService{}, NewHeartbeats(time.Minute), 0)
+	pool.SetReceipts(receipts)
+	require.NoError(t, pool.Resize(1))
+	defer pool.Stop()
+
+	msg := receiptMessage("rocket-a", 1)
+	id := receipts.Issue(msg)
+	require.NoError(t, q.Enqueue(context.Background(), *msg))
+
+	assert.Eventually(t, func() bool {
+		receipt, err := receipts.Get(id)
+		return err == nil && receipt.Status == model.ReceiptProcessed && receipt.ProcessingAt != nil
+	}, time.Second, time.Millisecond)
+}
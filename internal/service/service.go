@@ -1,43 +1,107 @@
 package service
 
 import (
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"time"
 
+	"github.com/seansa/rocket-challenge/internal/logging"
 	"github.com/seansa/rocket-challenge/internal/model"
 	"github.com/seansa/rocket-challenge/internal/repository"
 )
 
+// Outcomes returned by ProcessMessage when no error occurs.
+const (
+	StatusProcessed  = "processed"
+	StatusIgnoredOld = "ignoring_old_message"
+	StatusDuplicate  = "re-processed_duplicate"
+)
+
 type Service interface {
 	ProcessMessage(msg *model.IncomingMessage) (string, error)
 	GetRocketState(channel string) (model.Rocket, error)
 	GetAllRocketStates() ([]model.Rocket, error)
+	ForEachRocketState(fn func(model.Rocket) error) error
+	GetStats() model.FleetStats
+	Events() *Publisher
+	// Ping reports whether the repository is reachable.
+	Ping() error
+	// CheckContact flags the rockets silent for longer than lostAfter as
+	// contactLost, and returns how many it flagged.
+	CheckContact(lostAfter time.Duration) (int, error)
 }
 
 type service struct {
-	repo repository.Repository[model.Rocket]
+	repo   repository.Repository[model.Rocket]
+	stats  *fleetStats
+	events *Publisher
+	locks  channelLocks
+	now    func() time.Time
+}
+
+// Option configures the service returned by NewRocketService.
+type Option func(*service)
+
+// WithClock replaces time.Now for contact tracking, so that tests can
+// control it.
+func WithClock(now func() time.Time) Option {
+	return func(s *service) {
+		s.now = now
+	}
 }
 
-func NewRocketService(repo repository.Repository[model.Rocket]) Service {
-	return &service{
-		repo: repo,
+func NewRocketService(repo repository.Repository[model.Rocket], opts ...Option) Service {
+	s := &service{
+		repo:   repo,
+		stats:  newFleetStats(),
+		events: NewPublisher(defaultEventHistorySize, defaultEventBufferSize),
+		now:    time.Now,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *service) ProcessMessage(msg *model.IncomingMessage) (string, error) {
+	start := time.Now()
+
+	// Updates for the same rocket are serialized so that the read-modify-write
+	// below and the incremental statistics never race with each other.
+	unlock := s.locks.lock(msg.Metadata.Channel)
+	defer unlock()
+
+	status, err := s.processMessage(msg)
+	s.stats.recordOutcome(status, err)
+
+	outcome := outcomeLabel(status, err)
+	processedMessages.WithLabelValues(outcome).Inc()
+	processingSeconds.WithLabelValues(outcome).ObserveDuration(start)
+	return status, err
+}
+
+func (s *service) processMessage(msg *model.IncomingMessage) (string, error) {
 	channel := msg.Metadata.Channel
 	incomingMessageNumber := msg.Metadata.MessageNumber
 	incomingMessageType := msg.Metadata.MessageType
 	incomingMessageData := msg.Message
+	logger := logging.ForMessage(msg)
 
+	isNew := false
 	savedRocket, err := s.repo.Get(channel)
 	if err != nil {
+		if !errors.Is(err, model.ErrNotFound) {
+			return "", fmt.Errorf("error loading rocket state %s: %w", channel, err)
+		}
 		// If the rocket is not found, assume it's a new rocket.
 		savedRocket = model.NewRocket(channel)
-		log.Printf("New rocket registered in service: %s", channel)
+		isNew = true
+		logger.Info("New rocket registered")
 	}
+	previousRocket := savedRocket
 
-	statusMsg := "ignoring_old_message"
+	statusMsg := StatusIgnoredOld
 	stateChanged := false
 
 	// Primary logic for handling out-of-order and duplicate messages:
@@ -47,31 +111,50 @@ func (s *service) ProcessMessage(msg *model.IncomingMessage) (string, error) {
 	// If it's an older message (lower messageNumber), we ignore it.
 	if incomingMessageNumber > savedRocket.MessageNumber {
 		if err := savedRocket.UpdateState(incomingMessageType, incomingMessageData); err != nil {
-			return "", fmt.Errorf("error updating rocket state %s: %w", channel, err)
+			return "", fmt.Errorf("error updating rocket state %s: %w: %w", channel, model.ErrInvalidPayload, err)
 		}
 		savedRocket.MessageNumber = incomingMessageNumber
 		savedRocket.MessageTime = msg.Metadata.MessageTime
-		statusMsg = "processed"
+		statusMsg = StatusProcessed
 		stateChanged = true
 	} else if incomingMessageNumber == savedRocket.MessageNumber {
 		// If a duplicate of the current latest message arrives, re-process it.
 		// This ensures idempotency for at-least-once delivery.
 		// or we can ignore it if we want to avoid re-processing because we already processed it.
 		// Here we assume that re-processing is safe and ensure at-least-once delivery
-		log.Printf("Re-processing duplicate message %d for channel %s.", incomingMessageNumber, channel)
+		logger.Info("Re-processing duplicate message")
 		if err := savedRocket.UpdateState(incomingMessageType, incomingMessageData); err != nil {
-			return "", fmt.Errorf("error re-processing rocket state %s: %w", channel, err)
+			return "", fmt.Errorf("error re-processing rocket state %s: %w: %w", channel, model.ErrInvalidPayload, err)
 		}
-		statusMsg = "re-processed_duplicate"
+		statusMsg = StatusDuplicate
 		stateChanged = true // We can change to false if we want to avoid re-processing
 	} else {
-		log.Printf("Ignoring old message %d for channel %s (current last: %d).", incomingMessageNumber, channel, savedRocket.MessageNumber)
+		logger.Info("Ignoring old message", "last_message_number", savedRocket.MessageNumber)
+	}
+
+	// Any message counts as contact, even one that is ignored, so a rocket
+	// only sending stale messages is not flagged as lost. The rocket is
+	// therefore saved for every message.
+	contactRestored := savedRocket.ContactLost
+	if contactRestored {
+		logger.Info("Contact restored", "lost_since", *savedRocket.ContactLostSince)
+		savedRocket.ContactLost, savedRocket.ContactLostSince = false, nil
 	}
+	savedRocket.ReceivedAt = s.now()
 
+	if err := s.repo.Save(savedRocket); err != nil {
+		return "", fmt.Errorf("error saving rocket state %s: %w", channel, err)
+	}
+	if isNew {
+		s.stats.apply(nil, savedRocket)
+	} else {
+		s.stats.apply(&previousRocket, savedRocket)
+	}
+	if contactRestored {
+		s.events.PublishKind(model.EventContactRestored, savedRocket, msg)
+	}
 	if stateChanged {
-		if err := s.repo.Save(savedRocket); err != nil {
-			return "", fmt.Errorf("error saving rocket state %s: %w", channel, err)
-		}
+		s.events.Publish(savedRocket, *msg)
 	}
 
 	return statusMsg, nil
@@ -80,17 +163,38 @@ func (s *service) ProcessMessage(msg *model.IncomingMessage) (string, error) {
 func (s *service) GetRocketState(channel string) (model.Rocket, error) {
 	rocket, err := s.repo.Get(channel)
 	if err != nil {
-		return model.Rocket{}, err
+		return model.Rocket{}, fmt.Errorf("rocket %s: %w", channel, err)
 	}
-	log.Printf("Returning state for rocket %s.", channel)
+	slog.Debug("Returning rocket state", "channel", channel)
 	return rocket, nil
 }
 
 func (s *service) GetAllRocketStates() ([]model.Rocket, error) {
 	rockets, err := s.repo.GetAll()
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("error listing rockets: %w", err)
 	}
-	log.Printf("Returning list of %d rockets.", len(rockets))
+	slog.Debug("Returning rocket states", "count", len(rockets))
 	return rockets, nil
 }
+
+// ForEachRocketState streams every rocket, sorted by channel, to fn.
+func (s *service) ForEachRocketState(fn func(model.Rocket) error) error {
+	return s.repo.ForEach(fn)
+}
+
+func (s *service) GetStats() model.FleetStats {
+	return s.stats.snapshot()
+}
+
+// Events returns the publisher of rocket state changes.
+func (s *service) Events() *Publisher {
+	return s.events
+}
+
+func (s *service) Ping() error {
+	if err := s.repo.Ping(); err != nil {
+		return fmt.Errorf("repository unreachable: %w", err)
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 
@@ -34,11 +35,28 @@ func (m *MockRocketRepository[T]) GetAll() ([]T, error) {
 	return args.Get(0).([]T), args.Error(1)
 }
 
+func (m *MockRocketRepository[T]) ForEach(fn func(item T) error) error {
+	args := m.Called(fn)
+	if items, ok := args.Get(0).([]T); ok {
+		for _, item := range items {
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(1)
+}
+
 func (m *MockRocketRepository[T]) Save(rocket T) error {
 	args := m.Called(rocket)
 	return args.Error(0)
 }
 
+func (m *MockRocketRepository[T]) Ping() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
 // === END MOCKS === //
 
 func TestNewRocketService(t *testing.T) {
@@ -62,7 +80,7 @@ func TestProcessMessage_NewRocket(t *testing.T) {
 		Message: json.RawMessage(`{"type": "Falcon-9", "launchSpeed": 100, "mission": "ARTEMIS"}`),
 	}
 
-	mockRepo.On("Get", "193270a9-c9cf-404a-8f83-838e71d9ae67").Return(nil, errors.New("not found"))
+	mockRepo.On("Get", "193270a9-c9cf-404a-8f83-838e71d9ae67").Return(nil, fmt.Errorf("key 193270a9-c9cf-404a-8f83-838e71d9ae67 %w", model.ErrNotFound))
 	mockRepo.On("Save", mock.AnythingOfType("model.Rocket")).Return(nil).Run(func(args mock.Arguments) {
 		rocket := args.Get(0).(model.Rocket)
 		assert.Equal(t, "193270a9-c9cf-404a-8f83-838e71d9ae67", rocket.Channel)
@@ -110,6 +128,7 @@ func TestProcessMessage_ExistingRocket_DuplicateMessage(t *testing.T) {
 }
 
 // TestProcessMessage_ExistingRocket_OldMessage tests ignoring an old message.
+// Only the time the rocket was last heard from is saved.
 func TestProcessMessage_ExistingRocket_OldMessage(t *testing.T) {
 	mockRepo := new(MockRocketRepository[model.Rocket])
 	svc := NewRocketService(mockRepo)
@@ -129,7 +148,9 @@ func TestProcessMessage_ExistingRocket_OldMessage(t *testing.T) {
 	}
 
 	mockRepo.On("Get", "existing-channel-3").Return(existingRocket, nil)
-	mockRepo.AssertNotCalled(t, "Save")
+	mockRepo.On("Save", mock.MatchedBy(func(rocket model.Rocket) bool {
+		return rocket.MessageNumber == 10 && rocket.Speed == 1000 && !rocket.ReceivedAt.IsZero()
+	})).Return(nil)
 
 	status, err := svc.ProcessMessage(testMessage)
 	assert.NoError(t, err)
@@ -153,11 +174,12 @@ func TestProcessMessage_RepoGetError(t *testing.T) {
 	}
 
 	mockRepo.On("Get", "error-channel").Return(nil, errors.New("simulated repo error"))
-	mockRepo.On("Save", mock.AnythingOfType("model.Rocket")).Return(nil)
 
 	status, err := svc.ProcessMessage(testMessage)
-	assert.NoError(t, err)
-	assert.Equal(t, "processed", status)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "error loading rocket state")
+	assert.Empty(t, status)
+	mockRepo.AssertNotCalled(t, "Save", mock.Anything)
 	mockRepo.AssertExpectations(t)
 }
 
@@ -189,6 +211,32 @@ func TestProcessMessage_RepoSaveError(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+// TestProcessMessage_InvalidPayload tests that malformed message bodies surface ErrInvalidPayload.
+func TestProcessMessage_InvalidPayload(t *testing.T) {
+	mockRepo := new(MockRocketRepository[model.Rocket])
+	svc := NewRocketService(mockRepo)
+
+	existingRocket := model.NewRocket("invalid-payload-channel")
+
+	testMessage := &model.IncomingMessage{
+		Metadata: model.Metadata{
+			Channel:       "invalid-payload-channel",
+			MessageNumber: 1,
+			MessageTime:   time.Now(),
+			MessageType:   "RocketSpeedIncreased",
+		},
+		Message: json.RawMessage(`{"by": "fast"}`),
+	}
+
+	mockRepo.On("Get", "invalid-payload-channel").Return(existingRocket, nil)
+
+	status, err := svc.ProcessMessage(testMessage)
+	assert.ErrorIs(t, err, model.ErrInvalidPayload)
+	assert.Empty(t, status)
+	mockRepo.AssertNotCalled(t, "Save", mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
 // TestProcessMessage_ExistingRocket_NewMessage tests processing a new message for an existing rocket.
 func TestProcessMessage_ExistingRocket_NewMessage(t *testing.T) {
 	mockRepo := new(MockRocketRepository[model.Rocket])
@@ -242,10 +290,11 @@ func TestGetRocketState_NotFound(t *testing.T) {
 	mockRepo := new(MockRocketRepository[model.Rocket])
 	svc := NewRocketService(mockRepo)
 
-	mockRepo.On("Get", "non-existent").Return(nil, errors.New("not found"))
+	mockRepo.On("Get", "non-existent").Return(nil, fmt.Errorf("key non-existent %w", model.ErrNotFound))
 
 	rocket, err := svc.GetRocketState("non-existent")
 	assert.Error(t, err)
+	assert.ErrorIs(t, err, model.ErrNotFound)
 	assert.Zero(t, rocket)
 	assert.Contains(t, err.Error(), "not found")
 	mockRepo.AssertExpectations(t)
@@ -282,3 +331,92 @@ func TestGetAllRocketStates_RepoError(t *testing.T) {
 	assert.Contains(t, err.Error(), "repo error")
 	mockRepo.AssertExpectations(t)
 }
+
+// TestGetStats_TracksStateChanges tests that fleet statistics follow each applied state change.
+func TestGetStats_TracksStateChanges(t *testing.T) {
+	svc := NewRocketService(repository.NewRepository[model.Rocket]())
+
+	send := func(channel string, number int, messageType model.MessageType, body string) {
+		_, _ = svc.ProcessMessage(&model.IncomingMessage{
+			Metadata: model.Metadata{
+				Channel:       channel,
+				MessageNumber: number,
+				MessageTime:   time.Now(),
+				MessageType:   messageType,
+			},
+			Message: json.RawMessage(body),
+		})
+	}
+
+	send("rocket-a", 1, model.RocketLaunched, `{"type": "Falcon-9", "launchSpeed": 500, "mission": "ARTEMIS"}`)
+	send("rocket-b", 1, model.RocketLaunched, `{"type": "Falcon-9", "launchSpeed": 300, "mission": "APOLLO"}`)
+	send("rocket-c", 1, model.RocketLaunched, `{"type": "Falcon-Heavy", "launchSpeed": 100, "mission": "ARTEMIS"}`)
+	send("rocket-a", 2, model.RocketExploded, `{"reason": "PRESSURE_VESSEL_FAILURE"}`)
+	send("rocket-b", 2, model.RocketSpeedIncreased, `{"by": 100}`)
+	send("rocket-b", 2, model.RocketSpeedIncreased, `{"by": 100}`) // duplicate
+	send("rocket-c", 1, model.RocketMissionChanged, `{"newMission": "GEMINI"}`)
+	send("rocket-c", 2, model.RocketSpeedIncreased, `{"by": "bad"}`)
+
+	stats := svc.GetStats()
+	assert.Equal(t, 3, stats.TotalRockets)
+	assert.Equal(t, map[string]int{model.StatusInFlight: 2, model.StatusExploded: 1}, stats.ByStatus)
+	assert.Equal(t, map[string]int{"Falcon-9": 2, "Falcon-Heavy": 1}, stats.ByType)
+	assert.Equal(t, map[string]int{model.Aborted: 1, "APOLLO": 1, "GEMINI": 1}, stats.ByMission)
+	assert.Equal(t, map[string]int{"PRESSURE_VESSEL_FAILURE": 1}, stats.ExplosionReasons)
+	assert.Equal(t, 500, stats.MaxSpeed) // rocket-b: 300 + 100 + 100 (re-processed duplicate)
+	assert.InDelta(t, 200.0, stats.AverageSpeed, 0.001)
+	assert.Equal(t, model.IngestionStats{Processed: 5, IgnoredOld: 0, Duplicates: 2, Errors: 1}, stats.Ingestion)
+}
+
+// TestGetStats_Empty tests the statistics of an empty fleet.
+func TestGetStats_Empty(t *testing.T) {
+	mockRepo := new(MockRocketRepository[model.Rocket])
+	svc := NewRocketService(mockRepo)
+
+	stats := svc.GetStats()
+	assert.Zero(t, stats.TotalRockets)
+	assert.Zero(t, stats.MaxSpeed)
+	assert.Empty(t, stats.ByStatus)
+	mockRepo.AssertNotCalled(t, "GetAll")
+}
+
+// TestProcessMessage_PublishesStateChanges tests that applied messages are published and ignored ones are not.
+func TestProcessMessage_PublishesStateChanges(t *testing.T) {
+	svc := NewRocketService(repository.NewRepository[model.Rocket]())
+	sub := svc.Events().Subscribe(nil)
+	defer sub.Close()
+
+	launch := &model.IncomingMessage{
+		Metadata: model.Metadata{Channel: "rocket-a", MessageNumber: 2, MessageTime: time.Now(), MessageType: model.RocketLaunched},
+		Message:  json.RawMessage(`{"type": "Falcon-9", "launchSpeed": 500, "mission": "ARTEMIS"}`),
+	}
+	old := &model.IncomingMessage{
+		Metadata: model.Metadata{Channel: "rocket-a", MessageNumber: 1, MessageTime: time.Now(), MessageType: model.RocketSpeedIncreased},
+		Message:  json.RawMessage(`{"by": 100}`),
+	}
+	_, _ = svc.ProcessMessage(launch)
+	_, _ = svc.ProcessMessage(old)
+
+	events := drain(sub)
+	assert.Len(t, events, 1)
+	assert.Equal(t, 500, events[0].Rocket.Speed)
+	assert.Equal(t, 2, events[0].Message.Metadata.MessageNumber)
+}
+
+// TestForEachRocketState tests that rockets are streamed from the repository.
+func TestForEachRocketState(t *testing.T) {
+	mockRepo := new(MockRocketRepository[model.Rocket])
+	svc := NewRocketService(mockRepo)
+
+	expectedRockets := []model.Rocket{model.NewRocket("rocket-a"), model.NewRocket("rocket-b")}
+	mockRepo.On("ForEach", mock.Anything).Return(expectedRockets, nil)
+
+	var rockets []model.Rocket
+	err := svc.ForEachRocketState(func(rocket model.Rocket) error {
+		rockets = append(rockets, rocket)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, expectedRockets, rockets)
+	mockRepo.AssertExpectations(t)
+}
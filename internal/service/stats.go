@@ -0,0 +1,134 @@
+package service
+
+import (
+	"sync"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+)
+
+const unspecifiedReason = "unspecified"
+
+// fleetStats keeps the aggregates behind GET /stats up to date incrementally:
+// every applied state change removes the previous rocket's contribution and
+// adds the new one, so reads never scan the repository.
+type fleetStats struct {
+	mutex     sync.Mutex
+	total     int
+	speedSum  int
+	byStatus  map[string]int
+	byType    map[string]int
+	byMission map[string]int
+	byReason  map[string]int
+	bySpeed   map[int]int
+	maxSpeed  int
+	ingestion model.IngestionStats
+}
+
+func newFleetStats() *fleetStats {
+	return &fleetStats{
+		byStatus:  make(map[string]int),
+		byType:    make(map[string]int),
+		byMission: make(map[string]int),
+		byReason:  make(map[string]int),
+		bySpeed:   make(map[int]int),
+	}
+}
+
+// apply replaces the contribution of previous (nil for a new rocket) with next.
+func (f *fleetStats) apply(previous *model.Rocket, next model.Rocket) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if previous != nil {
+		f.add(*previous, -1)
+	}
+	f.add(next, 1)
+}
+
+func (f *fleetStats) add(rocket model.Rocket, delta int) {
+	f.total += delta
+	f.speedSum += rocket.Speed * delta
+	adjust(f.byStatus, rocket.Status(), delta)
+	if rocket.Type != "" {
+		adjust(f.byType, rocket.Type, delta)
+	}
+	if rocket.Mission != "" {
+		adjust(f.byMission, rocket.Mission, delta)
+	}
+	if rocket.Exploded {
+		reason := rocket.ExplosionReason
+		if reason == "" {
+			reason = unspecifiedReason
+		}
+		adjust(f.byReason, reason, delta)
+	}
+
+	adjust(f.bySpeed, rocket.Speed, delta)
+	switch {
+	case delta > 0 && (f.total == 1 || rocket.Speed > f.maxSpeed):
+		f.maxSpeed = rocket.Speed
+	case delta < 0 && rocket.Speed == f.maxSpeed && f.bySpeed[rocket.Speed] == 0:
+		// The last rocket at the maximum speed changed; find the next one.
+		f.maxSpeed = 0
+		first := true
+		for speed := range f.bySpeed {
+			if first || speed > f.maxSpeed {
+				f.maxSpeed = speed
+				first = false
+			}
+		}
+	}
+}
+
+// recordOutcome counts the result of a ProcessMessage call.
+func (f *fleetStats) recordOutcome(status string, err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	switch {
+	case err != nil:
+		f.ingestion.Errors++
+	case status == StatusProcessed:
+		f.ingestion.Processed++
+	case status == StatusIgnoredOld:
+		f.ingestion.IgnoredOld++
+	case status == StatusDuplicate:
+		f.ingestion.Duplicates++
+	}
+}
+
+func (f *fleetStats) snapshot() model.FleetStats {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	stats := model.FleetStats{
+		TotalRockets:     f.total,
+		ByStatus:         copyCounts(f.byStatus),
+		ByType:           copyCounts(f.byType),
+		ByMission:        copyCounts(f.byMission),
+		ExplosionReasons: copyCounts(f.byReason),
+		Ingestion:        f.ingestion,
+	}
+	if f.total > 0 {
+		stats.AverageSpeed = float64(f.speedSum) / float64(f.total)
+		stats.MaxSpeed = f.maxSpeed
+	}
+	return stats
+}
+
+// adjust adds delta to counts[key], dropping keys that reach zero so that
+// snapshots only report values currently present in the fleet.
+func adjust[K comparable](counts map[K]int, key K, delta int) {
+	counts[key] += delta
+	if counts[key] <= 0 {
+		delete(counts, key)
+	}
+}
+
+func copyCounts(counts map[string]int) map[string]int {
+	out := make(map[string]int, len(counts))
+	for k, v := range counts {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,40 @@
+package signature
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSignAndVerify tests that Verify accepts what Sign produces.
+func TestSignAndVerify(t *testing.T) {
+	secret, body := []byte("secret"), []byte(`{"a":1}`)
+	now := time.Unix(1700000000, 0)
+
+	header := Sign(secret, now, body)
+	timestamp, err := Verify(secret, header, body, now.Add(30*time.Second), time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, now.Unix(), timestamp)
+
+	_, err = Verify(secret, header, []byte(`{"a":2}`), now, time.Minute)
+	assert.ErrorIs(t, err, ErrMismatch)
+	_, err = Verify(secret, header, body, now.Add(-2*time.Minute), time.Minute)
+	assert.ErrorIs(t, err, ErrExpired, "timestamps in the future are rejected too")
+}
+
+// TestParse tests header parsing, including extra signatures for key rotation.
+func TestParse(t *testing.T) {
+	timestamp, signatures, err := Parse("t=12, v1=aa,v0=ignored,v1=bb")
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), timestamp)
+	assert.Equal(t, []string{"aa", "bb"}, signatures)
+
+	for _, header := range []string{"t=12", "v1=aa", "t=x,v1=aa", "garbage"} {
+		_, _, err := Parse(header)
+		assert.ErrorIs(t, err, ErrMalformed, header)
+	}
+	_, _, err = Parse("")
+	assert.ErrorIs(t, err, ErrMissing)
+}
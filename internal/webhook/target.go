@@ -0,0 +1,89 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+)
+
+// targetGuard keeps webhooks off loopback, link-local and private addresses,
+// so that whoever registers a webhook cannot make the server call services
+// that are only reachable from inside. Allowed hosts, addresses and networks
+// are exempt.
+type targetGuard struct {
+	hosts    map[string]bool
+	networks []*net.IPNet
+}
+
+// newTargetGuard reads allowed entries as CIDR networks, IP addresses or
+// host names.
+func newTargetGuard(allowed []string) *targetGuard {
+	g := &targetGuard{hosts: make(map[string]bool)}
+	for _, entry := range allowed {
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			g.networks = append(g.networks, network)
+		} else if ip := net.ParseIP(entry); ip != nil {
+			g.networks = append(g.networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+		} else {
+			g.hosts[strings.ToLower(entry)] = true
+		}
+	}
+	return g
+}
+
+// allowsHost reports whether host, the host of a webhook URL, may be
+// registered. Host names are only checked once resolved, when dialing.
+func (g *targetGuard) allowsHost(host string) bool {
+	host = strings.ToLower(host)
+	if g.hosts[host] {
+		return true
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return g.allowsIP(ip)
+	}
+	return host != "localhost" && !strings.HasSuffix(host, ".localhost")
+}
+
+func (g *targetGuard) allowsIP(ip net.IP) bool {
+	if !isInternal(ip) {
+		return true
+	}
+	for _, network := range g.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func isInternal(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast()
+}
+
+// dialContext returns a DialContext for deliveries that checks the address
+// every connection is actually made to, after name resolution and on
+// redirects, so that a public name resolving to an internal address is
+// refused as well. Allowed host names are dialed unchecked.
+func (g *targetGuard) dialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	guarded := *dialer
+	guarded.Control = func(_, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		if ip := net.ParseIP(host); ip == nil || !g.allowsIP(ip) {
+			return fmt.Errorf("webhook target %s is a loopback, link-local or private address", host)
+		}
+		return nil
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if host, _, err := net.SplitHostPort(addr); err == nil && g.hosts[strings.ToLower(host)] {
+			return dialer.DialContext(ctx, network, addr)
+		}
+		return guarded.DialContext(ctx, network, addr)
+	}
+}
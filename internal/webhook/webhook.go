@@ -0,0 +1,455 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/seansa/rocket-challenge/internal/logging"
+	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/seansa/rocket-challenge/internal/service"
+	"github.com/seansa/rocket-challenge/internal/signature"
+)
+
+// Headers sent with every delivery besides signature.Header.
+const (
+	EventHeader    = "X-Rocket-Event"
+	DeliveryHeader = "X-Rocket-Delivery"
+)
+
+// Registry manages webhook registrations.
+type Registry interface {
+	Register(req model.WebhookRequest) (model.Webhook, error)
+	List() []model.Webhook
+	Get(id string) (model.Webhook, error)
+	Delete(id string) error
+	Enable(id string) (model.Webhook, error)
+	Deliveries(id string) ([]model.WebhookDelivery, error)
+}
+
+// Options tunes delivery behaviour.
+type Options struct {
+	// MaxAttempts is the number of tries per event before the delivery fails.
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry; it doubles on every
+	// following retry up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// DisableAfter consecutive failed deliveries disable the webhook.
+	DisableAfter int
+	// QueueSize bounds the events waiting for delivery per webhook.
+	QueueSize int
+	// LogSize is the number of delivery attempts kept per webhook.
+	LogSize int
+	Timeout time.Duration
+	// AllowedHosts are the host names, IP addresses and CIDR networks that
+	// webhooks may target although they are loopback, link-local or private.
+	// Public addresses are always allowed.
+	AllowedHosts []string
+}
+
+func DefaultOptions() Options {
+	return Options{
+		MaxAttempts:    5,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     30 * time.Second,
+		DisableAfter:   10,
+		QueueSize:      256,
+		LogSize:        100,
+		Timeout:        5 * time.Second,
+	}
+}
+
+// Dispatcher keeps the registered webhooks and delivers matching rocket state
+// changes to them. Each webhook has its own queue and delivery goroutine, so a
+// slow or failing endpoint never delays the others, and events reach a given
+// endpoint in the order they happened.
+type Dispatcher struct {
+	opts      Options
+	guard     *targetGuard
+	client    *http.Client
+	mutex     sync.RWMutex
+	endpoints map[string]*endpoint
+	// delivering tracks the delivery goroutines.
+	delivering sync.WaitGroup
+}
+
+type endpoint struct {
+	mutex      sync.Mutex
+	hook       model.Webhook
+	queue      chan model.RocketEvent
+	stop       chan struct{}
+	deliveries []model.WebhookDelivery
+}
+
+func NewDispatcher(opts Options) *Dispatcher {
+	guard := newTargetGuard(opts.AllowedHosts)
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// Deliveries connect directly, so that the guard checks the target and
+	// not a proxy.
+	transport.Proxy = nil
+	transport.DialContext = guard.dialContext(&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second})
+	return &Dispatcher{
+		opts:      opts,
+		guard:     guard,
+		client:    &http.Client{Timeout: opts.Timeout, Transport: transport},
+		endpoints: make(map[string]*endpoint),
+	}
+}
+
+// Run feeds the dispatcher from a publisher until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context, events *service.Publisher) {
+	sub := events.Subscribe(nil)
+	defer sub.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			d.Dispatch(event)
+		}
+	}
+}
+
+// Dispatch queues event for every enabled webhook whose filters match it.
+// It never blocks: when a webhook's queue is full the event is dropped for it
+// and the drop is recorded in its delivery log.
+func (d *Dispatcher) Dispatch(event model.RocketEvent) {
+	if event.Kind == model.EventResync {
+		slog.Warn("Webhook dispatcher fell behind; some state changes were not delivered")
+		return
+	}
+	name := model.WebhookEventOf(event)
+
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	for _, ep := range d.endpoints {
+		ep.mutex.Lock()
+		matches := ep.hook.Enabled && ep.matches(name, event.Rocket.Channel)
+		ep.mutex.Unlock()
+		if !matches {
+			continue
+		}
+
+		select {
+		case ep.queue <- event:
+		default:
+			ep.record(d.opts.LogSize, model.WebhookDelivery{
+				ID:      newID(),
+				EventID: event.ID,
+				Event:   name,
+				Error:   "delivery queue full, event dropped",
+				Time:    time.Now(),
+			})
+		}
+	}
+}
+
+// Register validates and adds a webhook, starting its delivery goroutine.
+func (d *Dispatcher) Register(req model.WebhookRequest) (model.Webhook, error) {
+	target, err := url.Parse(req.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return model.Webhook{}, fmt.Errorf("webhook url %q must be an absolute http(s) URL: %w", req.URL, model.ErrInvalidPayload)
+	}
+	if !d.guard.allowsHost(target.Hostname()) {
+		return model.Webhook{}, fmt.Errorf("webhook url %q targets a loopback, link-local or private address that is not allowed: %w", req.URL, model.ErrInvalidPayload)
+	}
+	if len(req.Events) == 0 {
+		return model.Webhook{}, fmt.Errorf("webhook needs at least one event: %w", model.ErrInvalidPayload)
+	}
+	for _, event := range req.Events {
+		switch event {
+		case model.WebhookEventLaunched, model.WebhookEventSpeedChanged, model.WebhookEventExploded,
+			model.WebhookEventMissionChanged, model.WebhookEventContactLost, model.WebhookEventContactRestored,
+			model.WebhookEventAll:
+		default:
+			return model.Webhook{}, fmt.Errorf("unknown webhook event %q: %w", event, model.ErrInvalidPayload)
+		}
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		secret = newID() + newID()
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for _, ep := range d.endpoints {
+		if ep.hook.URL == req.URL {
+			return model.Webhook{}, fmt.Errorf("webhook for %s already registered as %s: %w", req.URL, ep.hook.ID, model.ErrConflict)
+		}
+	}
+
+	hook := model.Webhook{
+		ID:        newID(),
+		URL:       req.URL,
+		Events:    slices.Clone(req.Events),
+		Channels:  slices.Clone(req.Channels),
+		Secret:    secret,
+		Enabled:   true,
+		CreatedAt: time.Now(),
+	}
+	ep := &endpoint{
+		hook:  hook,
+		queue: make(chan model.RocketEvent, d.opts.QueueSize),
+		stop:  make(chan struct{}),
+	}
+	d.endpoints[hook.ID] = ep
+	d.delivering.Add(1)
+	go func() {
+		defer d.delivering.Done()
+		d.deliverLoop(ep)
+	}()
+
+	slog.Info("Webhook registered", "webhook", hook.ID, "url", hook.URL, "events", hook.Events)
+	return hook, nil
+}
+
+// List returns every webhook, oldest first, without secrets.
+func (d *Dispatcher) List() []model.Webhook {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	hooks := make([]model.Webhook, 0, len(d.endpoints))
+	for _, ep := range d.endpoints {
+		hooks = append(hooks, ep.snapshot())
+	}
+	sort.Slice(hooks, func(i, j int) bool {
+		if hooks[i].CreatedAt.Equal(hooks[j].CreatedAt) {
+			return hooks[i].ID < hooks[j].ID
+		}
+		return hooks[i].CreatedAt.Before(hooks[j].CreatedAt)
+	})
+	return hooks
+}
+
+// Get returns a webhook without its secret.
+func (d *Dispatcher) Get(id string) (model.Webhook, error) {
+	ep, err := d.endpoint(id)
+	if err != nil {
+		return model.Webhook{}, err
+	}
+	return ep.snapshot(), nil
+}
+
+// Delete removes a webhook and stops its deliveries.
+func (d *Dispatcher) Delete(id string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	ep, exists := d.endpoints[id]
+	if !exists {
+		return fmt.Errorf("webhook %s %w", id, model.ErrNotFound)
+	}
+	delete(d.endpoints, id)
+	close(ep.stop)
+	return nil
+}
+
+// Enable re-enables a webhook that was disabled after repeated failures.
+func (d *Dispatcher) Enable(id string) (model.Webhook, error) {
+	ep, err := d.endpoint(id)
+	if err != nil {
+		return model.Webhook{}, err
+	}
+
+	ep.mutex.Lock()
+	ep.hook.Enabled = true
+	ep.hook.DisabledAt = nil
+	ep.hook.ConsecutiveFailures = 0
+	ep.mutex.Unlock()
+	return ep.snapshot(), nil
+}
+
+// Deliveries returns the delivery log of a webhook, most recent first.
+func (d *Dispatcher) Deliveries(id string) ([]model.WebhookDelivery, error) {
+	ep, err := d.endpoint(id)
+	if err != nil {
+		return nil, err
+	}
+
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+
+	deliveries := slices.Clone(ep.deliveries)
+	slices.Reverse(deliveries)
+	return deliveries, nil
+}
+
+// Close stops every delivery goroutine and waits for the attempts in progress
+// to finish. Queued events and pending retries are dropped.
+func (d *Dispatcher) Close() {
+	d.mutex.Lock()
+	for id, ep := range d.endpoints {
+		close(ep.stop)
+		delete(d.endpoints, id)
+	}
+	d.mutex.Unlock()
+	d.delivering.Wait()
+}
+
+func (d *Dispatcher) endpoint(id string) (*endpoint, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	ep, exists := d.endpoints[id]
+	if !exists {
+		return nil, fmt.Errorf("webhook %s %w", id, model.ErrNotFound)
+	}
+	return ep, nil
+}
+
+func (d *Dispatcher) deliverLoop(ep *endpoint) {
+	for {
+		select {
+		case <-ep.stop:
+			return
+		case event := <-ep.queue:
+			d.deliver(ep, event)
+		}
+	}
+}
+
+// deliver sends event to the endpoint, retrying with exponential backoff, and
+// disables the endpoint after too many consecutive failed deliveries.
+func (d *Dispatcher) deliver(ep *endpoint, event model.RocketEvent) {
+	ep.mutex.Lock()
+	hook := ep.hook
+	ep.mutex.Unlock()
+	if !hook.Enabled {
+		return
+	}
+
+	name := model.WebhookEventOf(event)
+	body, err := json.Marshal(model.WebhookPayload{Event: name, Data: event})
+	if err != nil {
+		eventLogger(event).Error("Cannot encode webhook event", "webhook", hook.ID, "event", event.ID, "error", err)
+		return
+	}
+	deliveryID := newID()
+
+	backoff := d.opts.InitialBackoff
+	for attempt := 1; attempt <= d.opts.MaxAttempts; attempt++ {
+		delivery := d.attempt(hook, deliveryID, name, body)
+		delivery.EventID = event.ID
+		delivery.Attempt = attempt
+		ep.record(d.opts.LogSize, delivery)
+
+		if delivery.Success {
+			ep.mutex.Lock()
+			ep.hook.ConsecutiveFailures = 0
+			ep.mutex.Unlock()
+			return
+		}
+		if attempt == d.opts.MaxAttempts {
+			break
+		}
+
+		select {
+		case <-ep.stop:
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, d.opts.MaxBackoff)
+	}
+
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+	ep.hook.ConsecutiveFailures++
+	eventLogger(event).Warn("Webhook delivery failed", "webhook", hook.ID, "event", event.ID, "attempts", d.opts.MaxAttempts)
+	if ep.hook.ConsecutiveFailures >= d.opts.DisableAfter && ep.hook.Enabled {
+		now := time.Now()
+		ep.hook.Enabled = false
+		ep.hook.DisabledAt = &now
+		slog.Warn("Webhook disabled", "webhook", hook.ID, "consecutive_failures", ep.hook.ConsecutiveFailures)
+	}
+}
+
+func (d *Dispatcher) attempt(hook model.Webhook, deliveryID, name string, body []byte) model.WebhookDelivery {
+	start := time.Now()
+	delivery := model.WebhookDelivery{ID: deliveryID, Event: name, Time: start}
+
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "rocket-challenge-webhooks/1.0")
+	req.Header.Set(EventHeader, name)
+	req.Header.Set(DeliveryHeader, deliveryID)
+	req.Header.Set(signature.Header, signature.Sign([]byte(hook.Secret), start, body))
+
+	resp, err := d.client.Do(req)
+	delivery.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	resp.Body.Close()
+
+	delivery.StatusCode = resp.StatusCode
+	delivery.Success = resp.StatusCode >= 200 && resp.StatusCode < 300
+	if !delivery.Success {
+		delivery.Error = "unexpected status " + resp.Status
+	}
+	return delivery
+}
+
+// eventLogger returns a logger for event, which may carry no message.
+func eventLogger(event model.RocketEvent) *slog.Logger {
+	if event.Message != nil {
+		return logging.ForMessage(event.Message)
+	}
+	return slog.With("channel", event.Rocket.Channel)
+}
+
+func (ep *endpoint) matches(event, channel string) bool {
+	if !slices.Contains(ep.hook.Events, model.WebhookEventAll) && !slices.Contains(ep.hook.Events, event) {
+		return false
+	}
+	return len(ep.hook.Channels) == 0 || slices.Contains(ep.hook.Channels, channel)
+}
+
+func (ep *endpoint) record(logSize int, delivery model.WebhookDelivery) {
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+
+	ep.deliveries = append(ep.deliveries, delivery)
+	if len(ep.deliveries) > logSize {
+		ep.deliveries = ep.deliveries[len(ep.deliveries)-logSize:]
+	}
+}
+
+// snapshot returns a copy of the webhook without its secret.
+func (ep *endpoint) snapshot() model.Webhook {
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+
+	hook := ep.hook
+	hook.Secret = ""
+	return hook
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
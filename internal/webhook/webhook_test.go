@@ -0,0 +1,270 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seansa/rocket-challenge/internal/model"
+	"github.com/seansa/rocket-challenge/internal/signature"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testOptions() Options {
+	opts := DefaultOptions()
+	opts.InitialBackoff = time.Millisecond
+	opts.MaxBackoff = 4 * time.Millisecond
+	opts.MaxAttempts = 3
+	opts.DisableAfter = 2
+	// The test receivers listen on the loopback interface.
+	opts.AllowedHosts = []string{"127.0.0.1"}
+	return opts
+}
+
+func testEvent(id uint64, channel string, messageType model.MessageType) model.RocketEvent {
+	return model.RocketEvent{
+		ID:      id,
+		Kind:    model.EventRocketUpdated,
+		Rocket:  &model.Rocket{Channel: channel, Exploded: messageType == model.RocketExploded},
+		Message: &model.IncomingMessage{Metadata: model.Metadata{Channel: channel, MessageNumber: int(id), MessageType: messageType}},
+	}
+}
+
+// receiver is a webhook endpoint that records the requests it gets and fails the first failures of them.
+type receiver struct {
+	mutex    sync.Mutex
+	failures int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	if r.failures > 0 {
+		r.failures--
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (r *receiver) count() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.requests)
+}
+
+// TestDispatcher_DeliversSignedPayload tests filtering and the HMAC signature of delivered payloads.
+func TestDispatcher_DeliversSignedPayload(t *testing.T) {
+	rcv := &receiver{}
+	server := httptest.NewServer(rcv)
+	defer server.Close()
+
+	dispatcher := NewDispatcher(testOptions())
+	defer dispatcher.Close()
+	hook, err := dispatcher.Register(model.WebhookRequest{URL: server.URL, Events: []string{model.WebhookEventExploded}, Channels: []string{"rocket-a"}, Secret: "s3cret"})
+	require.NoError(t, err)
+
+	dispatcher.Dispatch(testEvent(1, "rocket-a", model.RocketSpeedIncreased))
+	dispatcher.Dispatch(testEvent(2, "rocket-b", model.RocketExploded))
+	dispatcher.Dispatch(testEvent(3, "rocket-a", model.RocketExploded))
+
+	require.Eventually(t, func() bool { return rcv.count() == 1 }, 2*time.Second, 5*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, 1, rcv.count())
+
+	rcv.mutex.Lock()
+	req, body := rcv.requests[0], rcv.bodies[0]
+	rcv.mutex.Unlock()
+	assert.Equal(t, model.WebhookEventExploded, req.Header.Get(EventHeader))
+	assert.NotEmpty(t, req.Header.Get(DeliveryHeader))
+
+	parts := strings.Split(req.Header.Get(signature.Header), ",")
+	require.Len(t, parts, 2)
+	timestamp, err := strconv.ParseInt(strings.TrimPrefix(parts[0], "t="), 10, 64)
+	require.NoError(t, err)
+	assert.Equal(t, "v1="+signature.Compute([]byte("s3cret"), timestamp, body), parts[1])
+
+	var payload model.WebhookPayload
+	require.NoError(t, json.Unmarshal(body, &payload))
+	assert.Equal(t, model.WebhookEventExploded, payload.Event)
+	assert.Equal(t, uint64(3), payload.Data.ID)
+
+	deliveries, err := dispatcher.Deliveries(hook.ID)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.True(t, deliveries[0].Success)
+	assert.Equal(t, http.StatusNoContent, deliveries[0].StatusCode)
+}
+
+// TestDispatcher_ContactEvents tests delivering events that no message caused.
+func TestDispatcher_ContactEvents(t *testing.T) {
+	rcv := &receiver{}
+	server := httptest.NewServer(rcv)
+	defer server.Close()
+
+	dispatcher := NewDispatcher(testOptions())
+	defer dispatcher.Close()
+	_, err := dispatcher.Register(model.WebhookRequest{URL: server.URL, Events: []string{model.WebhookEventContactLost}})
+	require.NoError(t, err)
+
+	dispatcher.Dispatch(testEvent(1, "rocket-a", model.RocketLaunched))
+	dispatcher.Dispatch(model.RocketEvent{ID: 2, Kind: model.EventContactLost, Rocket: &model.Rocket{Channel: "rocket-a", ContactLost: true}})
+
+	require.Eventually(t, func() bool { return rcv.count() == 1 }, 2*time.Second, 5*time.Millisecond)
+	rcv.mutex.Lock()
+	req, body := rcv.requests[0], rcv.bodies[0]
+	rcv.mutex.Unlock()
+	assert.Equal(t, model.WebhookEventContactLost, req.Header.Get(EventHeader))
+	var payload model.WebhookPayload
+	require.NoError(t, json.Unmarshal(body, &payload))
+	assert.Equal(t, uint64(2), payload.Data.ID)
+	assert.Nil(t, payload.Data.Message)
+}
+
+// TestDispatcher_RetriesThenDisables tests exponential-backoff retries and automatic disabling.
+func TestDispatcher_RetriesThenDisables(t *testing.T) {
+	rcv := &receiver{failures: 2}
+	server := httptest.NewServer(rcv)
+	defer server.Close()
+
+	dispatcher := NewDispatcher(testOptions())
+	defer dispatcher.Close()
+	hook, err := dispatcher.Register(model.WebhookRequest{URL: server.URL, Events: []string{model.WebhookEventAll}})
+	require.NoError(t, err)
+	assert.NotEmpty(t, hook.Secret)
+
+	// Two failures then a success: a single successful delivery after retries.
+	dispatcher.Dispatch(testEvent(1, "rocket-a", model.RocketLaunched))
+	require.Eventually(t, func() bool { return rcv.count() == 3 }, 2*time.Second, 5*time.Millisecond)
+	require.Eventually(t, func() bool {
+		deliveries, _ := dispatcher.Deliveries(hook.ID)
+		return len(deliveries) == 3 && deliveries[0].Success
+	}, 2*time.Second, 5*time.Millisecond)
+
+	// Two fully failed deliveries in a row disable the webhook.
+	rcv.mutex.Lock()
+	rcv.failures = 100
+	rcv.mutex.Unlock()
+	dispatcher.Dispatch(testEvent(2, "rocket-a", model.RocketLaunched))
+	dispatcher.Dispatch(testEvent(3, "rocket-a", model.RocketLaunched))
+	require.Eventually(t, func() bool {
+		current, _ := dispatcher.Get(hook.ID)
+		return !current.Enabled
+	}, 2*time.Second, 5*time.Millisecond)
+
+	current, err := dispatcher.Get(hook.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, current.ConsecutiveFailures)
+	assert.NotNil(t, current.DisabledAt)
+	assert.Empty(t, current.Secret)
+
+	sent := rcv.count()
+	dispatcher.Dispatch(testEvent(4, "rocket-a", model.RocketLaunched))
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, sent, rcv.count())
+
+	enabled, err := dispatcher.Enable(hook.ID)
+	require.NoError(t, err)
+	assert.True(t, enabled.Enabled)
+	assert.Zero(t, enabled.ConsecutiveFailures)
+}
+
+// TestDispatcher_CloseWaitsForDelivery tests that Close returns only after the delivery in progress.
+func TestDispatcher_CloseWaitsForDelivery(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	dispatcher := NewDispatcher(testOptions())
+	_, err := dispatcher.Register(model.WebhookRequest{URL: server.URL, Events: []string{model.WebhookEventExploded}})
+	require.NoError(t, err)
+	dispatcher.Dispatch(testEvent(1, "rocket-a", model.RocketExploded))
+	<-started
+
+	closed := make(chan struct{})
+	go func() {
+		dispatcher.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("Close returned during a delivery")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close did not return after the delivery")
+	}
+}
+
+// TestDispatcher_RegisterValidation tests registration errors.
+func TestDispatcher_RegisterValidation(t *testing.T) {
+	dispatcher := NewDispatcher(testOptions())
+	defer dispatcher.Close()
+
+	_, err := dispatcher.Register(model.WebhookRequest{URL: "not a url", Events: []string{model.WebhookEventExploded}})
+	assert.ErrorIs(t, err, model.ErrInvalidPayload)
+	_, err = dispatcher.Register(model.WebhookRequest{URL: "http://example.com/hook", Events: []string{"landed"}})
+	assert.ErrorIs(t, err, model.ErrInvalidPayload)
+
+	hook, err := dispatcher.Register(model.WebhookRequest{URL: "http://example.com/hook", Events: []string{model.WebhookEventExploded}})
+	require.NoError(t, err)
+	_, err = dispatcher.Register(model.WebhookRequest{URL: "http://example.com/hook", Events: []string{model.WebhookEventLaunched}})
+	assert.ErrorIs(t, err, model.ErrConflict)
+
+	assert.Len(t, dispatcher.List(), 1)
+	require.NoError(t, dispatcher.Delete(hook.ID))
+	assert.ErrorIs(t, dispatcher.Delete(hook.ID), model.ErrNotFound)
+	_, err = dispatcher.Deliveries(hook.ID)
+	assert.ErrorIs(t, err, model.ErrNotFound)
+}
+
+// TestDispatcher_RefusesInternalTargets tests that webhooks cannot target
+// loopback, link-local or private addresses unless they are allowed.
+func TestDispatcher_RefusesInternalTargets(t *testing.T) {
+	dispatcher := NewDispatcher(DefaultOptions())
+	defer dispatcher.Close()
+	for _, url := range []string{
+		"http://127.0.0.1:8088/admin", "http://169.254.169.254/latest/meta-data", "http://10.0.0.7/hook",
+		"http://192.168.1.1/hook", "http://[::1]:8080/hook", "http://0.0.0.0/hook", "http://localhost/hook", "http://api.LOCALHOST/hook",
+	} {
+		_, err := dispatcher.Register(model.WebhookRequest{URL: url, Events: []string{model.WebhookEventAll}})
+		assert.ErrorIs(t, err, model.ErrInvalidPayload, url)
+	}
+
+	// Names are checked once resolved, when the delivery connects.
+	server := httptest.NewServer(&receiver{})
+	defer server.Close()
+	_, err := dispatcher.client.Get(server.URL)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "loopback, link-local or private address")
+
+	opts := DefaultOptions()
+	opts.AllowedHosts = []string{"10.0.0.0/8", "hooks.internal"}
+	allowing := NewDispatcher(opts)
+	defer allowing.Close()
+	for _, url := range []string{"http://10.0.0.7/hook", "http://hooks.internal/hook"} {
+		_, err := allowing.Register(model.WebhookRequest{URL: url, Events: []string{model.WebhookEventAll}})
+		assert.NoError(t, err, url)
+	}
+	_, err = allowing.Register(model.WebhookRequest{URL: "http://192.168.1.1/hook", Events: []string{model.WebhookEventAll}})
+	assert.ErrorIs(t, err, model.ErrInvalidPayload)
+}
@@ -8,6 +8,10 @@ import "github.com/seansa/rocket-challenge/cmd"
 // @host localhost:8088
 // @BasePath /
 // @Schemes http
+// @securityDefinitions.apikey AdminToken
+// @in header
+// @name Authorization
+// @description "Bearer <token>" configured with ADMIN_TOKEN; required by /admin and /webhooks endpoints, which are disabled without it.
 func main() {
 	cmd.Run()
 }